@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	gopdp "github.com/kebohan1/go-pdp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+/* recorders hands each result to every recorder in turn. */
+type recorders []gopdp.PDP_audit_recorder
+
+func (list recorders) RecordAudit(result *gopdp.PDP_audit_result) error {
+	var first error
+
+	for _, recorder := range list {
+		if err := recorder.RecordAudit(result); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+/* stdout_recorder prints one line per audit. */
+type stdout_recorder struct{}
+
+func (stdout_recorder) RecordAudit(result *gopdp.PDP_audit_result) error {
+	status := "PASS"
+	if !result.Passed {
+		status = "FAIL " + result.Err.Error()
+	}
+	_, err := fmt.Printf("%s %s %s %d/%d %v %s\n", result.Time.Format(time.RFC3339), result.FileID, result.Endpoint,
+		result.Challenged, result.NumFileBlocks, result.Latency.Round(time.Millisecond), status)
+	return err
+}
+
+func auditor(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("auditor", flag.ExitOnError)
+	certFile := flags.String("cert", "", "client certificate (PEM) for mutual TLS")
+	keyFile := flags.String("key", "", "client private key (PEM)")
+	serverCA := flags.String("ca", "", "CA bundle (PEM) that server certificates must chain to")
+	timeout := flags.Duration("timeout", gopdp.PDP_AUDIT_TIMEOUT, "time limit per audit")
+	dbPath := flags.String("db", "", "audit history database to record results in")
+	metricsAddr := flags.String("metrics", "", "listen address for Prometheus /metrics")
+	keysDir := flags.String("keys", "", "directory of public file keys, <file-id>.pub, for files audited publicly")
+	blsKey := flags.String("bls", "", "BLS public key file from go-pdp keygen to audit with instead of the RSA key pair")
+	macKey := flags.String("mac", "", "MAC key file from go-pdp keygen -scheme mac to audit with instead of the RSA key pair")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: go-pdp auditor [options] registry\n\n")
+		fmt.Fprintf(os.Stderr, "Each registry line is: file-id endpoint blocks interval [jitter] [name=hex], e.g.\n")
+		fmt.Fprintf(os.Stderr, "  photos https://store1.example.com:8443 25600 1h 10m\n")
+		fmt.Fprintf(os.Stderr, "Endpoints are http://, https://, grpc:// or grpcs:// URLs.  blocks may be @path of the\n")
+		fmt.Fprintf(os.Stderr, "index table of a file changed with go-pdp block.  With -keys, a file whose public key is in\n")
+		fmt.Fprintf(os.Stderr, "the directory is audited publicly, and no PDP key pair is needed if every file has one.\n")
+		fmt.Fprintf(os.Stderr, "A BLS or MAC file needs the name=hex go-pdp tag printed for it.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	key, err := load_key(*blsKey, *macKey, false)
+	if err != nil && (*blsKey != "" || *macKey != "" || *keysDir == "") {
+		return fmt.Errorf("auditor: %v", err)
+	}
+	var tlsConfig *tls.Config
+	if *certFile != "" {
+		if tlsConfig, err = gopdp.NewPDPClientTLSConfig(*certFile, *keyFile, *serverCA, ""); err != nil {
+			return fmt.Errorf("auditor: %v", err)
+		}
+	}
+
+	recorder := recorders{stdout_recorder{}}
+	if *dbPath != "" {
+		history, err := gopdp.OpenPDPHistory(*dbPath)
+		if err != nil {
+			return fmt.Errorf("auditor: %v", err)
+		}
+		defer history.Close()
+		recorder = append(recorder, history)
+	}
+
+	auditor := gopdp.NewPDPAuditor(key, recorder, &gopdp.PDP_auditor_options{
+		Timeout: *timeout,
+		OnRecordError: func(result *gopdp.PDP_audit_result, err error) {
+			fmt.Fprintf(os.Stderr, "ERROR: recording audit of %s: %v\n", result.FileID, err)
+		},
+	})
+	if err := load_registry(auditor, flags.Arg(0), key, *keysDir, tlsConfig); err != nil {
+		return fmt.Errorf("auditor: %v", err)
+	}
+
+	if *metricsAddr == "" {
+		return auditor.Run(ctx)
+	}
+
+	errs := make(chan error, 1)
+	defer serve_metrics(*metricsAddr, errs).Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case err := <-errs:
+			fmt.Fprintf(os.Stderr, "ERROR: metrics: %v\n", err)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return auditor.Run(ctx)
+}
+
+/* load_registry: Adds every file listed in the registry at path to auditor, with its public key if
+ * keysDir has one.
+ */
+func load_registry(auditor *gopdp.PDPAuditor, path string, key *gopdp.PDP_key, keysDir string, tlsConfig *tls.Config) error {
+	var line_number int = 0
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry_name []byte
+
+		line_number++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if n := len(fields); n > 4 && strings.HasPrefix(fields[n-1], "name=") {
+			if entry_name, err = hex.DecodeString(strings.TrimPrefix(fields[n-1], "name=")); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, line_number, err)
+			}
+			fields = fields[:n-1]
+		}
+		if len(fields) < 4 || len(fields) > 5 {
+			return fmt.Errorf("%s:%d: expected file-id endpoint blocks interval [jitter] [name=hex]", path, line_number)
+		}
+
+		entry := gopdp.PDP_audit_file{FileID: fields[0], Endpoint: fields[1], Name: entry_name}
+		if strings.HasPrefix(fields[2], "@") {
+			entry.Table, err = gopdp.LoadPDPIndexTable(fields[2][1:])
+		} else {
+			entry.NumBlocks, err = strconv.ParseUint(fields[2], 10, 64)
+			if err == nil && entry.NumBlocks == 0 {
+				err = fmt.Errorf("the block count must be the file's, not 0")
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line_number, err)
+		}
+		if entry.Interval, err = time.ParseDuration(fields[3]); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line_number, err)
+		}
+		if len(fields) == 5 {
+			if entry.Jitter, err = time.ParseDuration(fields[4]); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, line_number, err)
+			}
+		}
+		remote_key := key
+		if keysDir != "" {
+			if entry.PublicKey, err = load_public_key(keysDir, entry.FileID); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, line_number, err)
+			}
+			if entry.PublicKey != nil {
+				remote_key = entry.PublicKey
+			}
+		}
+		if remote_key == nil {
+			return fmt.Errorf("%s:%d: no PDP key pair or public key for %s", path, line_number, entry.FileID)
+		}
+		if entry.Remote, err = dial_remote(entry.Endpoint, remote_key, tlsConfig); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line_number, err)
+		}
+		if err = auditor.AddFile(entry); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line_number, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+/* load_public_key: Returns the public key of fileID in dir, or nil if there is none. */
+func load_public_key(dir string, fileID string) (*gopdp.PDP_key, error) {
+
+	key, keyID, err := gopdp.LoadPublicFileKey(filepath.Join(dir, fileID+gopdp.PDP_PUBLIC_KEY_SUFFIX))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if keyID != fileID {
+		return nil, fmt.Errorf("public key is for %s, not %s", keyID, fileID)
+	}
+
+	return key, nil
+}
+
+/* dial_remote: Returns a client for an endpoint URL.  gRPC connections are made lazily. */
+func dial_remote(endpoint string, key *gopdp.PDP_key, tlsConfig *tls.Config) (gopdp.PDP_remote, error) {
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		client := http.DefaultClient
+		if tlsConfig != nil {
+			client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		}
+		return gopdp.NewPDPHTTPClient(endpoint, client, key), nil
+	case "grpc", "grpcs":
+		option := grpc.WithInsecure()
+		if u.Scheme == "grpcs" {
+			config := tlsConfig
+			if config == nil {
+				config = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			option = grpc.WithTransportCredentials(credentials.NewTLS(config))
+		}
+		conn, err := grpc.Dial(u.Host, option)
+		if err != nil {
+			return nil, err
+		}
+		return gopdp.NewPDPGRPCClient(conn, key), nil
+	}
+
+	return nil, fmt.Errorf("unsupported endpoint %q", endpoint)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	gopdp "github.com/kebohan1/go-pdp"
+)
+
+func history(args []string) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	dbPath := flags.String("db", "", "audit history database")
+	fileID := flags.String("file", "", "only this file ID")
+	since := flags.Duration("since", 24*time.Hour, "how far back to look, unless -from is given")
+	from := flags.String("from", "", "start of the time range (RFC 3339)")
+	to := flags.String("to", "", "end of the time range (RFC 3339, default now)")
+	all := flags.Bool("all", false, "list passed audits as well as failures")
+	limit := flags.Int("n", 0, "list at most n audits per file, the most recent")
+	flags.Parse(args)
+	if *dbPath == "" || flags.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "usage: go-pdp history -db path [options]\n")
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+
+	query := &gopdp.PDP_history_query{FileID: *fileID, FailuresOnly: !*all, Limit: *limit}
+	query.From = time.Now().Add(-*since)
+	if *from != "" {
+		t, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			return fmt.Errorf("history: -from: %v", err)
+		}
+		query.From = t
+	}
+	if *to != "" {
+		t, err := time.Parse(time.RFC3339, *to)
+		if err != nil {
+			return fmt.Errorf("history: -to: %v", err)
+		}
+		query.To = t
+	}
+
+	db, err := gopdp.OpenPDPHistory(*dbPath)
+	if err != nil {
+		return fmt.Errorf("history: %v", err)
+	}
+	defer db.Close()
+
+	fileIDs := []string{*fileID}
+	if *fileID == "" {
+		if fileIDs, err = db.Files(); err != nil {
+			return fmt.Errorf("history: %v", err)
+		}
+	}
+	for _, id := range fileIDs {
+		query.FileID = id
+		results, err := db.Query(query)
+		if err != nil {
+			return fmt.Errorf("history: %v", err)
+		}
+		if len(results) == 0 {
+			continue
+		}
+		fmt.Printf("%s: %d\n", id, len(results))
+		for _, result := range results {
+			status := "PASS"
+			if !result.Passed {
+				status = "FAIL " + result.Err.Error()
+			}
+			fmt.Printf("  %s %s %d/%d %v %s\n", result.Time.Format(time.RFC3339), result.Endpoint,
+				result.Challenged, result.NumFileBlocks, result.Latency.Round(time.Millisecond), status)
+		}
+	}
+
+	return nil
+}
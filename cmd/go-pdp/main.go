@@ -1,17 +1,486 @@
 package main
 
 import (
-	"crypto/rand"
-	rsa "crypto/rsa"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	gopdp "github.com/kebohan1/go-pdp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: go-pdp <command> [options]\n\n")
+	fmt.Fprintf(os.Stderr, "commands:\n")
+	fmt.Fprintf(os.Stderr, "  keygen   generate a BLS key pair or a MAC key\n")
+	fmt.Fprintf(os.Stderr, "  tag      tag a file, resuming an interrupted run\n")
+	fmt.Fprintf(os.Stderr, "  append   tag the blocks appended to a tagged file\n")
+	fmt.Fprintf(os.Stderr, "  block    modify, insert or delete a block of a tagged file\n")
+	fmt.Fprintf(os.Stderr, "  replicate write a distinct replica of a tagged file\n")
+	fmt.Fprintf(os.Stderr, "  serve    answer audits for tagged files over HTTP and/or gRPC\n")
+	fmt.Fprintf(os.Stderr, "  auditor  audit remote files periodically\n")
+	fmt.Fprintf(os.Stderr, "  history  list recorded audit failures per file\n")
+	os.Exit(2)
+}
+
 func main() {
-	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
-	for i := 0; i < len(privateKey.Primes); i++ {
-		fmt.Printf("private key %s", privateKey.Primes[i])
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	/* Cancel long-running commands on ^C so the tag file is left at a clean checkpoint */
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(os.Args[2:])
+	case "tag":
+		err = tag(ctx, os.Args[2:])
+	case "append":
+		err = append_file(ctx, os.Args[2:])
+	case "block":
+		err = block(os.Args[2:])
+	case "replicate":
+		err = replicate(ctx, os.Args[2:])
+	case "serve":
+		err = serve(ctx, os.Args[2:])
+	case "auditor":
+		err = auditor(ctx, os.Args[2:])
+	case "history":
+		err = history(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func keygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	scheme := flags.String("scheme", "bls", "bls for a publicly verifiable key pair, mac for a private key")
+	output := flags.String("o", "", "private key file (default pdp.<scheme>); the public or prover key is written to <file>.pub")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		return fmt.Errorf("keygen: unexpected arguments")
+	}
+	if *output == "" {
+		*output = "pdp." + *scheme
+	}
+
+	if *scheme == "mac" {
+		key := gopdp.GenerateKey(gopdp.NewPDPParams(gopdp.PDP_SCHEME_MAC))
+		if key == nil {
+			return fmt.Errorf("keygen: could not generate a MAC key")
+		}
+		if err := gopdp.WriteMACKey(*output, key, true); err != nil {
+			return fmt.Errorf("keygen: %v", err)
+		}
+		if err := gopdp.WriteMACKey(*output+gopdp.PDP_PUBLIC_KEY_SUFFIX, key, false); err != nil {
+			return fmt.Errorf("keygen: %v", err)
+		}
+		return nil
+	}
+	if *scheme != "bls" {
+		return fmt.Errorf("keygen: unknown scheme %q", *scheme)
+	}
+
+	key := gopdp.GenerateKey(gopdp.NewPDPParams(gopdp.PDP_SCHEME_BLS))
+	if key == nil {
+		return fmt.Errorf("keygen: could not generate a BLS key pair")
+	}
+	if err := gopdp.WriteBLSKey(*output, key, true); err != nil {
+		return fmt.Errorf("keygen: %v", err)
+	}
+	if err := gopdp.WriteBLSKey(*output+gopdp.PDP_PUBLIC_KEY_SUFFIX, key, false); err != nil {
+		return fmt.Errorf("keygen: %v", err)
+	}
+
+	return nil
+}
+
+/* read_password: Displays prompt and reads a passphrase or an answer.  The passphrase is taken from the
+ * PDP_PASSPHRASE environment variable if it is set, otherwise it is read as a line off standard input.
+ * Returns the reply or "" on failure.
+ */
+func read_password(prompt string) string {
+
+	if password, ok := os.LookupEnv(gopdp.PDP_PASSPHRASE_ENV); ok {
+		return password
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil && line == "" {
+		return ""
 	}
 
-	// fmt.Printf("%s", publicKey)
+	return strings.TrimRight(line, "\r\n")
+}
+
+/* load_key: The BLS key in blsPath or the MAC key in macPath if either is set, otherwise the RSA key
+ * pair, or only its public key if public.
+ */
+func load_key(blsPath string, macPath string, public bool) (*gopdp.PDP_key, error) {
+	var key *gopdp.PDP_key
+
+	if blsPath != "" && macPath != "" {
+		return nil, fmt.Errorf("-bls and -mac are exclusive")
+	}
+	if blsPath != "" {
+		return gopdp.LoadBLSKey(blsPath)
+	}
+	if macPath != "" {
+		return gopdp.LoadMACKey(macPath)
+	}
+	if public {
+		key = gopdp.GetPubkey()
+	} else {
+		key = gopdp.GetKeypair(read_password)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no PDP key pair")
+	}
+
+	return key, nil
+}
+
+func tag(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("tag", flag.ExitOnError)
+	workers := flags.Int("workers", runtime.NumCPU(), "number of tagging goroutines")
+	tagfile := flags.String("o", "", "tag file (default <file>.tag)")
+	fresh := flags.Bool("fresh", false, "ignore any checkpoint and tag from the start")
+	table := flags.Uint64("gtable", gopdp.PDP_GENERATOR_TABLE_SIZE, "memory in bytes for the generator table, 0 to disable")
+	public := flags.String("public", "", "make the file publicly verifiable as this file ID and write its public key to <file>.pub")
+	blsKey := flags.String("bls", "", "BLS key file from go-pdp keygen (default the RSA key pair)")
+	macKey := flags.String("mac", "", "MAC key file from go-pdp keygen -scheme mac (default the RSA key pair)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("tag: expected one file")
+	}
+
+	key, err := load_key(*blsKey, *macKey, false)
+	if err != nil {
+		return fmt.Errorf("tag: %v", err)
+	}
+	if *blsKey != "" || *macKey != "" {
+		/* BLS and MAC files have no per-file keys and no generator to precompute */
+		if *public != "" {
+			return fmt.Errorf("tag: -public is for RSA keys; audit a BLS file with the .pub of its key")
+		}
+		*table = 0
+	}
+	if *public != "" {
+		if err := gopdp.WritePublicFileKey(flags.Arg(0)+gopdp.PDP_PUBLIC_KEY_SUFFIX, key, *public); err != nil {
+			return fmt.Errorf("tag: %v", err)
+		}
+		key = gopdp.FileKey(key, *public)
+	}
+	if *table > 0 && !gopdp.PrecomputeGenerator(key, *table) {
+		return fmt.Errorf("tag: could not build a generator table in %d bytes", *table)
+	}
+
+	opts := &gopdp.PDP_tag_options{
+		Workers: *workers,
+		Resume:  !*fresh,
+		Progress: func(p gopdp.PDP_tag_progress) {
+			fmt.Fprintf(os.Stderr, "\r%d/%d blocks, %.1f MB/s", p.Blocks, p.TotalBlocks, p.BytesPerSec/1e6)
+		},
+	}
+	err = gopdp.NewPDPCore().TagFile(ctx, key, flags.Arg(0), *tagfile, opts)
+	fmt.Fprintln(os.Stderr)
+	if err != nil || (*blsKey == "" && *macKey == "") {
+		return err
+	}
+
+	/* The verifier audits a BLS or MAC file under the name it was tagged with */
+	if *tagfile == "" {
+		*tagfile = flags.Arg(0) + gopdp.PDP_TAG_FILE_SUFFIX
+	}
+	name, err := gopdp.TagFileName(*tagfile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: name=%x\n", flags.Arg(0), name)
+
+	return nil
+}
+
+func append_file(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("append", flag.ExitOnError)
+	workers := flags.Int("workers", runtime.NumCPU(), "number of tagging goroutines")
+	tagfile := flags.String("o", "", "tag file (default <file>.tag)")
+	dataFile := flags.String("data", "", "file whose contents to append first, - for stdin (default: the file has already grown)")
+	blsKey := flags.String("bls", "", "BLS key file from go-pdp keygen (default the RSA key pair)")
+	macKey := flags.String("mac", "", "MAC key file from go-pdp keygen -scheme mac (default the RSA key pair)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("append: expected one file")
+	}
+
+	key, err := load_key(*blsKey, *macKey, false)
+	if err != nil {
+		return fmt.Errorf("append: %v", err)
+	}
+
+	var data io.Reader
+	if *dataFile == "-" {
+		data = os.Stdin
+	} else if *dataFile != "" {
+		f, err := os.Open(*dataFile)
+		if err != nil {
+			return fmt.Errorf("append: %v", err)
+		}
+		defer f.Close()
+		data = f
+	}
+
+	opts := &gopdp.PDP_tag_options{
+		Workers: *workers,
+		Progress: func(p gopdp.PDP_tag_progress) {
+			fmt.Fprintf(os.Stderr, "\r%d/%d blocks, %.1f MB/s", p.Blocks, p.TotalBlocks, p.BytesPerSec/1e6)
+		},
+	}
+	numfileblocks, err := gopdp.NewPDPCore().AppendFile(ctx, key, flags.Arg(0), *tagfile, data, opts)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d blocks\n", flags.Arg(0), numfileblocks)
+
+	return nil
+}
+
+func replicate(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("replicate", flag.ExitOnError)
+	tagfile := flags.String("t", "", "tag file of the file (default <file>.tag)")
+	output := flags.String("o", "", "replica file (default <file>.r<n>)")
+	replicaTagfile := flags.String("ot", "", "replica tag file (default <replica>.tag)")
+	number := flags.Uint("n", 1, "replica number")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("replicate: expected one file")
+	}
+	if *output == "" {
+		*output = fmt.Sprintf("%s.r%d", flags.Arg(0), *number)
+	}
+
+	key := gopdp.GetKeypair(read_password)
+	if key == nil {
+		return fmt.Errorf("replicate: no PDP key pair")
+	}
+
+	return gopdp.NewPDPCore().ReplicateFile(ctx, key, flags.Arg(0), *tagfile, *output, *replicaTagfile, uint32(*number))
+}
+
+func block(args []string) error {
+	flags := flag.NewFlagSet("block", flag.ExitOnError)
+	tagfile := flags.String("o", "", "tag file (default <file>.tag)")
+	table := flags.String("table", "", "index table (default <file>.idx)")
+	blsKey := flags.String("bls", "", "BLS key file from go-pdp keygen (default the RSA key pair)")
+	macKey := flags.String("mac", "", "MAC key file from go-pdp keygen -scheme mac (default the RSA key pair)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: go-pdp block [options] modify|insert file position block-file\n")
+		fmt.Fprintf(os.Stderr, "       go-pdp block [options] delete file position\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 3 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	op, file := flags.Arg(0), flags.Arg(1)
+	position, err := strconv.ParseUint(flags.Arg(2), 10, 64)
+	if err != nil {
+		return fmt.Errorf("block: %v", err)
+	}
+	if op == "delete" {
+		if flags.NArg() != 3 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		return gopdp.NewPDPCore().DeleteBlock(file, *tagfile, *table, position)
+	}
+	if flags.NArg() != 4 || (op != "modify" && op != "insert") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	key, err := load_key(*blsKey, *macKey, false)
+	if err != nil {
+		return fmt.Errorf("block: %v", err)
+	}
+	data, err := ioutil.ReadFile(flags.Arg(3))
+	if err != nil {
+		return fmt.Errorf("block: %v", err)
+	}
+	if op == "modify" {
+		return gopdp.NewPDPCore().ModifyBlock(key, file, *tagfile, *table, position, data)
+	}
+	return gopdp.NewPDPCore().InsertBlock(key, file, *tagfile, *table, position, data)
+}
+
+func serve(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	httpAddr := flags.String("http", "", "listen address for the HTTP/JSON API")
+	grpcAddr := flags.String("grpc", "", "listen address for the gRPC API")
+	readers := flags.Int("readers", 0, "concurrent block readers per proof (default the prover's)")
+	memory := flags.Uint64("memory", 0, "bytes of block data a proof may hold in memory (default the prover's)")
+	mask := flags.Bool("mask", false, "mask public proofs so third-party auditors learn nothing about the data")
+	certFile := flags.String("cert", "", "server certificate (PEM); enables mutual TLS")
+	keyFile := flags.String("key", "", "server private key (PEM)")
+	clientCA := flags.String("client-ca", "", "CA bundle (PEM) that client certificates must chain to")
+	aclFile := flags.String("acl", "", "file listing which client certificates may audit which files")
+	metricsAddr := flags.String("metrics", "", "listen address for Prometheus /metrics")
+	blsKey := flags.String("bls", "", "BLS public key file from go-pdp keygen (default the RSA public key)")
+	macKey := flags.String("mac", "", "MAC prover key file, the .pub from go-pdp keygen -scheme mac (default the RSA public key)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: go-pdp serve [options] id=file[,tagfile] ...\n")
+		fmt.Fprintf(os.Stderr, "SIGHUP rereads the tag files, picking up appended blocks.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 || (*httpAddr == "" && *grpcAddr == "") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	key, err := load_key(*blsKey, *macKey, true)
+	if err != nil {
+		return fmt.Errorf("serve: %v", err)
+	}
+	server := gopdp.NewPDPServer(key, &gopdp.PDP_prove_options{Readers: *readers, MaxMemory: *memory, Mask: *mask})
+	var tlsConfig *tls.Config
+	if *certFile != "" {
+		var err error
+		if tlsConfig, err = gopdp.NewPDPServerTLSConfig(*certFile, *keyFile, *clientCA); err != nil {
+			return fmt.Errorf("serve: %v", err)
+		}
+	}
+	if *aclFile != "" {
+		if tlsConfig == nil {
+			return fmt.Errorf("serve: -acl needs mutual TLS (-cert, -key, -client-ca)")
+		}
+		acl, err := gopdp.LoadPDPACL(*aclFile)
+		if err != nil {
+			return fmt.Errorf("serve: %v", err)
+		}
+		server.SetACL(acl)
+	}
+	if err := add_files(server, flags.Args()); err != nil {
+		return fmt.Errorf("serve: %v", err)
+	}
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+	go func() {
+		for range reload {
+			if err := add_files(server, flags.Args()); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: reload: %v\n", err)
+			}
+		}
+	}()
+
+	errs := make(chan error, 3)
+	if *metricsAddr != "" {
+		defer serve_metrics(*metricsAddr, errs).Close()
+	}
+	if *httpAddr != "" {
+		httpServer := &http.Server{Addr: *httpAddr, Handler: gopdp.NewPDPHTTPServer(server), TLSConfig: tlsConfig}
+		go func() {
+			if tlsConfig != nil {
+				errs <- httpServer.ListenAndServeTLS("", "")
+			} else {
+				errs <- httpServer.ListenAndServe()
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			httpServer.Shutdown(shutdownCtx)
+		}()
+		fmt.Fprintf(os.Stderr, "serving HTTP on %s\n", *httpAddr)
+	}
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+		var options []grpc.ServerOption
+		if tlsConfig != nil {
+			options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		grpcServer := grpc.NewServer(options...)
+		gopdp.RegisterPDPGRPCServer(grpcServer, server)
+		go func() { errs <- grpcServer.Serve(listener) }()
+		defer grpcServer.GracefulStop()
+		fmt.Fprintf(os.Stderr, "serving gRPC on %s\n", *grpcAddr)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
+
+/* add_files: Registers each id=file[,tagfile] argument with server, replacing any earlier registration. */
+func add_files(server *gopdp.PDPServer, args []string) error {
+	for _, arg := range args {
+		eq := strings.IndexByte(arg, '=')
+		if eq <= 0 {
+			return fmt.Errorf("expected id=file[,tagfile], got %q", arg)
+		}
+		paths := strings.SplitN(arg[eq+1:], ",", 2)
+		tagfile := ""
+		if len(paths) == 2 {
+			tagfile = paths[1]
+		}
+		if err := server.AddFile(arg[:eq], paths[0], tagfile); err != nil {
+			return fmt.Errorf("%s: %v", arg[:eq], err)
+		}
+	}
+
+	return nil
+}
+
+/* serve_metrics: Serves Prometheus metrics on addr at /metrics, sending a listener failure to errs. */
+func serve_metrics(addr string, errs chan<- error) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", gopdp.PDPMetricsHandler())
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			errs <- err
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "serving metrics on %s/metrics\n", addr)
 
+	return server
 }
@@ -0,0 +1,305 @@
+package gopdp
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	/* Default time an audit may take, from FileInfo to verification */
+	PDP_AUDIT_TIMEOUT = 5 * time.Minute
+)
+
+var ErrAuditorRunning = errors.New("pdp: auditor is already running")
+
+/* PDP_audit_file registers a remote file with the auditor.  It is audited every Interval plus a random
+ * delay of up to Jitter, the first time at a random point within the first period, so files registered
+ * together are not challenged together.
+ */
+type PDP_audit_file struct {
+	FileID string
+	Remote PDP_remote
+
+	/* Where Remote connects, for the audit records */
+	Endpoint string
+
+	/* Block count the file was tagged with.  A remote reporting a different count fails the audit.
+	 * It must be set unless Table is; the remote's own count is never trusted.
+	 */
+	NumBlocks uint64
+
+	/* Index table of a file changed with the dynamic block operations; NumBlocks is then taken from it */
+	Table *PDP_index_table
+
+	/* The replica Remote holds, for MR-PDP; nil for the file itself */
+	Replica *uint32
+
+	/* Public key of a publicly verifiable file, see pdp_public_file_key.  The file is then audited with
+	 * a public challenge under this key instead of the auditor's, which may be nil.
+	 */
+	PublicKey *PDP_key
+
+	/* Name a BLS or MAC file was tagged under, see pdp_tag_file_name.  It is challenged and verified under
+	 * the auditor's key named after it.
+	 */
+	Name []byte
+
+	Interval time.Duration
+	Jitter   time.Duration
+}
+
+/* PDP_audit_result records one audit. */
+type PDP_audit_result struct {
+	FileID         string
+	Endpoint       string
+	KeyFingerprint []byte
+	Time           time.Time
+	Latency        time.Duration
+
+	/* Challenge parameters: blocks challenged out of the file's block count.  Zero if the audit
+	 * failed before a challenge was made.
+	 */
+	Challenged    uint64
+	NumFileBlocks uint64
+
+	Passed bool
+	Err    error
+}
+
+/* PDP_audit_recorder stores audit results.  RecordAudit is called from the auditor's goroutines and
+ * must be safe for concurrent use.
+ */
+type PDP_audit_recorder interface {
+	RecordAudit(result *PDP_audit_result) error
+}
+
+/* PDP_auditor_options tunes the auditor; the zero value uses the defaults. */
+type PDP_auditor_options struct {
+	/* Time limit per audit; PDP_AUDIT_TIMEOUT if 0 */
+	Timeout time.Duration
+
+	/* Called if the recorder fails; the result is otherwise dropped */
+	OnRecordError func(result *PDP_audit_result, err error)
+}
+
+type pdp_auditor_file struct {
+	file   PDP_audit_file
+	cancel context.CancelFunc
+	last   *PDP_audit_result
+}
+
+/* PDPAuditor periodically audits a registry of remote files with the verifier's key and hands every
+ * result to a recorder.
+ */
+type PDPAuditor struct {
+	core     *PDPCore
+	key      *PDP_key
+	recorder PDP_audit_recorder
+	opts     PDP_auditor_options
+
+	mutex sync.Mutex
+	files map[string]*pdp_auditor_file
+	ctx   context.Context
+	wait  sync.WaitGroup
+}
+
+/* NewPDPAuditor: Returns an auditor holding the verifier's key.  recorder may be nil and opts may be nil.
+ * key may also be nil for an auditor of only publicly verifiable files.
+ */
+func NewPDPAuditor(key *PDP_key, recorder PDP_audit_recorder, opts *PDP_auditor_options) *PDPAuditor {
+	var auditor = &PDPAuditor{
+		core:     NewPDPCore(),
+		key:      key,
+		recorder: recorder,
+		files:    make(map[string]*pdp_auditor_file),
+	}
+
+	if opts != nil {
+		auditor.opts = *opts
+	}
+	if auditor.opts.Timeout <= 0 {
+		auditor.opts.Timeout = PDP_AUDIT_TIMEOUT
+	}
+
+	return auditor
+}
+
+/* AddFile registers file, replacing any file with the same ID.  If the auditor is running, the file's
+ * schedule starts at once.
+ */
+func (auditor *PDPAuditor) AddFile(file PDP_audit_file) error {
+
+	if file.FileID == "" || file.Remote == nil || file.Interval <= 0 || file.Jitter < 0 {
+		return errors.New("pdp: audit file needs an ID, a remote and a positive interval")
+	}
+
+	auditor.mutex.Lock()
+	defer auditor.mutex.Unlock()
+
+	if old, ok := auditor.files[file.FileID]; ok && old.cancel != nil {
+		old.cancel()
+	}
+	entry := &pdp_auditor_file{file: file}
+	auditor.files[file.FileID] = entry
+	if auditor.ctx != nil {
+		auditor.start(entry)
+	}
+
+	return nil
+}
+
+/* RemoveFile stops auditing fileID.  An audit already in progress still completes and is recorded. */
+func (auditor *PDPAuditor) RemoveFile(fileID string) {
+	auditor.mutex.Lock()
+	defer auditor.mutex.Unlock()
+
+	if entry, ok := auditor.files[fileID]; ok {
+		if entry.cancel != nil {
+			entry.cancel()
+		}
+		delete(auditor.files, fileID)
+	}
+}
+
+/* LastResult returns the most recent result for fileID, or nil if it has not been audited. */
+func (auditor *PDPAuditor) LastResult(fileID string) *PDP_audit_result {
+	auditor.mutex.Lock()
+	defer auditor.mutex.Unlock()
+
+	if entry, ok := auditor.files[fileID]; ok {
+		return entry.last
+	}
+	return nil
+}
+
+/* Run audits the registered files on their schedules until ctx is done.  It then stops scheduling,
+ * waits for audits in progress to finish (each is bounded by the audit timeout, not by ctx) and
+ * returns nil.
+ */
+func (auditor *PDPAuditor) Run(ctx context.Context) error {
+
+	auditor.mutex.Lock()
+	if auditor.ctx != nil {
+		auditor.mutex.Unlock()
+		return ErrAuditorRunning
+	}
+	auditor.ctx = ctx
+	for _, entry := range auditor.files {
+		auditor.start(entry)
+	}
+	auditor.mutex.Unlock()
+
+	<-ctx.Done()
+
+	/* Files added from now on wait for the next Run */
+	auditor.mutex.Lock()
+	auditor.ctx = nil
+	for _, entry := range auditor.files {
+		entry.cancel = nil
+	}
+	auditor.mutex.Unlock()
+	auditor.wait.Wait()
+
+	return nil
+}
+
+/* Audit audits fileID once, now, records the result and returns it. */
+func (auditor *PDPAuditor) Audit(ctx context.Context, fileID string) (*PDP_audit_result, error) {
+
+	auditor.mutex.Lock()
+	entry, ok := auditor.files[fileID]
+	auditor.mutex.Unlock()
+	if !ok {
+		return nil, ErrUnknownFile
+	}
+
+	return auditor.audit(ctx, entry), nil
+}
+
+/* start: Starts entry's schedule under the running context.  Called with the mutex held. */
+func (auditor *PDPAuditor) start(entry *pdp_auditor_file) {
+	var ctx context.Context
+
+	ctx, entry.cancel = context.WithCancel(auditor.ctx)
+	auditor.wait.Add(1)
+	go func() {
+		defer auditor.wait.Done()
+		auditor.schedule(ctx, entry)
+	}()
+}
+
+/* schedule: Audits entry each period until ctx is done. */
+func (auditor *PDPAuditor) schedule(ctx context.Context, entry *pdp_auditor_file) {
+	var delay = random_duration(entry.file.Interval + entry.file.Jitter)
+
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		/* Shutting down must not cut an audit short, so it only inherits the timeout */
+		auditor.audit(context.Background(), entry)
+		delay = entry.file.Interval + random_duration(entry.file.Jitter)
+	}
+}
+
+/* audit: Runs one audit of entry and records it. */
+func (auditor *PDPAuditor) audit(ctx context.Context, entry *pdp_auditor_file) *PDP_audit_result {
+	var file = entry.file
+	var key = auditor.key
+	var result *PDP_audit_result
+
+	if file.PublicKey != nil {
+		key = file.PublicKey
+	}
+	result = &PDP_audit_result{
+		FileID:         file.FileID,
+		Endpoint:       file.Endpoint,
+		KeyFingerprint: pdp_key_fingerprint(key),
+		Time:           time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, auditor.opts.Timeout)
+	defer cancel()
+
+	challenge, err := auditor.core.pdp_audit_remote_file(ctx, auditor.key, &file)
+	result.Latency = time.Since(result.Time)
+	if challenge != nil {
+		result.Challenged = uint64(challenge.c)
+		result.NumFileBlocks = uint64(challenge.numfileblocks)
+	}
+	result.Passed = err == nil
+	result.Err = err
+
+	auditor.mutex.Lock()
+	entry.last = result
+	auditor.mutex.Unlock()
+
+	if auditor.recorder != nil {
+		if err := auditor.recorder.RecordAudit(result); err != nil && auditor.opts.OnRecordError != nil {
+			auditor.opts.OnRecordError(result, err)
+		}
+	}
+
+	return result
+}
+
+/* random_duration: A uniformly random duration in [0, max). */
+func random_duration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(n.Int64())
+}
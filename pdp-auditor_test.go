@@ -0,0 +1,215 @@
+package gopdp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+/* fake_prover is a PDP_remote answering from a PDPServer in-process, which can be told to misbehave. */
+type fake_prover struct {
+	server *PDPServer
+	key    *PDP_key
+
+	mutex sync.Mutex
+	calls int
+
+	/* Block count to report instead of the file's, if not 0 */
+	numblocks uint64
+
+	/* Returns a proof altered after it was made, as a prover that lost the data might */
+	forge bool
+
+	/* Does not answer until the audit times out */
+	stall bool
+
+	/* Returned by every request, as by a broken transport */
+	err error
+}
+
+func (prover *fake_prover) FileInfo(ctx context.Context, fileID string) (*PDP_file_info, error) {
+
+	if prover.err != nil {
+		return nil, prover.err
+	}
+	info, err := prover.server.FileInfo(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if prover.numblocks != 0 {
+		info.NumBlocks = prover.numblocks
+	}
+
+	return info, nil
+}
+
+func (prover *fake_prover) Prove(ctx context.Context, fileID string, challenge *PDP_challenge) (*PDP_proof, error) {
+
+	prover.mutex.Lock()
+	prover.calls++
+	prover.mutex.Unlock()
+
+	if prover.err != nil {
+		return nil, prover.err
+	}
+	if prover.stall {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	encoded, err := prover.server.Prove(ctx, fileID, encode_pdp_challenge(challenge))
+	if err != nil {
+		return nil, err
+	}
+	proof, err := decode_pdp_proof(encoded, prover.key)
+	if err != nil {
+		return nil, err
+	}
+	if prover.forge {
+		proof.T.Add(proof.T, bigOne)
+	}
+
+	return proof, nil
+}
+
+func (prover *fake_prover) Calls() int {
+	prover.mutex.Lock()
+	defer prover.mutex.Unlock()
+
+	return prover.calls
+}
+
+/* test_recorder keeps every result it is given. */
+type test_recorder struct {
+	mutex   sync.Mutex
+	results []*PDP_audit_result
+}
+
+func (recorder *test_recorder) RecordAudit(result *PDP_audit_result) error {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.results = append(recorder.results, result)
+
+	return nil
+}
+
+/* Count returns the number of results recorded for fileID. */
+func (recorder *test_recorder) Count(fileID string) int {
+	var count int = 0
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	for _, result := range recorder.results {
+		if result.FileID == fileID {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestAuditorResults(t *testing.T) {
+	var ctx = context.Background()
+	var recorder = &test_recorder{}
+	var broken = errors.New("connection refused")
+
+	key, server, _, _ := serve_test_file(t, 20)
+	auditor := NewPDPAuditor(key, recorder, &PDP_auditor_options{Timeout: 200 * time.Millisecond})
+
+	for _, test := range []struct {
+		name       string
+		prover     *fake_prover
+		err        error
+		challenged uint64
+	}{
+		{"honest", &fake_prover{}, nil, 20},
+		{"forged proof", &fake_prover{forge: true}, ErrProofRejected, 20},
+		{"short file", &fake_prover{numblocks: 10}, ErrBlockCountMismatch, 0},
+		{"stalled", &fake_prover{stall: true}, context.DeadlineExceeded, 20},
+		{"unreachable", &fake_prover{err: broken}, broken, 0},
+	} {
+		test.prover.server, test.prover.key = server, public_test_key(key)
+		if err := auditor.AddFile(PDP_audit_file{FileID: "data", Remote: test.prover, NumBlocks: 20,
+			Interval: time.Hour}); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := auditor.Audit(ctx, "data")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !errors.Is(result.Err, test.err) || result.Passed != (test.err == nil) {
+			t.Errorf("%s: audit error %v, passed %v; want %v", test.name, result.Err, result.Passed, test.err)
+		}
+		if result.Challenged != test.challenged {
+			t.Errorf("%s: %d blocks challenged, want %d", test.name, result.Challenged, test.challenged)
+		}
+		if auditor.LastResult("data") != result {
+			t.Errorf("%s: last result is not the audit's", test.name)
+		}
+	}
+
+	if len(recorder.results) != 5 {
+		t.Fatalf("%d results recorded, want 5", len(recorder.results))
+	}
+	if _, err := auditor.Audit(ctx, "other"); !errors.Is(err, ErrUnknownFile) {
+		t.Fatalf("audit of an unregistered file: %v", err)
+	}
+}
+
+func TestAuditorSchedule(t *testing.T) {
+	var recorder = &test_recorder{}
+
+	key, server, path, _ := serve_test_file(t, 3)
+	if err := server.AddFile("fast", path, ""); err != nil {
+		t.Fatal(err)
+	}
+	auditor := NewPDPAuditor(key, recorder, nil)
+	fast := &fake_prover{server: server, key: public_test_key(key)}
+	slow := &fake_prover{server: server, key: public_test_key(key)}
+	auditor.AddFile(PDP_audit_file{FileID: "fast", Remote: fast, NumBlocks: 3, Interval: 10 * time.Millisecond})
+	auditor.AddFile(PDP_audit_file{FileID: "data", Remote: slow, NumBlocks: 3, Interval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- auditor.Run(ctx) }()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for fast.Calls() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d audits of a file audited every 10ms", fast.Calls())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := auditor.Run(ctx); !errors.Is(err, ErrAuditorRunning) {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	/* A removed file is no longer audited */
+	auditor.RemoveFile("fast")
+	time.Sleep(20 * time.Millisecond)
+	calls := fast.Calls()
+	time.Sleep(50 * time.Millisecond)
+	if fast.Calls() != calls {
+		t.Fatal("a removed file is still audited")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if slow.Calls() != 0 || recorder.Count("data") != 0 {
+		t.Fatal("a file audited hourly was audited at once")
+	}
+	if recorder.Count("fast") != calls {
+		t.Fatalf("%d results recorded for %d audits", recorder.Count("fast"), calls)
+	}
+	for _, result := range recorder.results {
+		if !result.Passed {
+			t.Fatalf("audit of %s failed: %v", result.FileID, result.Err)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+package gopdp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"github.com/cloudflare/circl/ecc/bls12381"
+)
+
+/* Shacham-Waters compact proofs of retrievability with BLS signatures over BLS12-381, the
+ * PDP_SCHEME_BLS backend.  A block is split into PDP_BLS_SECTORS sectors of PDP_BLS_SECTOR_SIZE bytes,
+ * m_i1 ... m_is, each smaller than the group order p.  The secret key is x in Z_p and the public key
+ * v = g2^x with a seed from which the sector generators u_j = H_G1("u" | seed | j) and the block hashes
+ * H(name | i) = H_G1("i" | seed | name | i) are derived, name being the random PDP_TAG_FILE_NAME_SIZE-byte
+ * name the file was tagged under and recorded in its tag file header.  The tag of block i is
+ *
+ *   sigma_i = (H(name | i) * prod u_j^m_ij)^x
+ *
+ * a single compressed G1 point of PDP_BLS_TAG_SIZE bytes, stored in the tag file like an RSA tag.  Without
+ * the name, block i of every file tagged under one key would hash alike, and a server could answer for a
+ * file it dropped with another file's blocks and tags.  A challenge is the ordinary <c, k1, k2> with
+ * coefficients nu = f_k2(j) and no secret, plus the name of the file the verifier has on record, so
+ * anyone holding the public key and the name can verify.  The proof is sigma = prod sigma_i^nu_i,
+ * carried in T, and the sector sums mu_j = sum nu_i m_ij mod p, and verifies if
+ *
+ *   e(sigma, g2) == e(prod H(name | i)^nu_i * prod u_j^mu_j, v)
+ *
+ * The key pair is selected with PDP_params; PDP_key.bls is set for a BLS key and the core functions
+ * dispatch on it.  Replicas, proof masking and signed Merkle roots are RSA only.
+ *
+ * A BLS key is stored as:
+ *
+ *   magic[4] | version u32 | v | seed | x
+ *
+ * with v compressed and each field u32 length-prefixed; x is empty in a public key.
+ */
+
+const (
+	PDP_SCHEME_RSA = 0
+	PDP_SCHEME_BLS = 1
+
+	/* Sectors are one byte shorter than the group order so any 31 bytes fit */
+	PDP_BLS_SECTOR_SIZE = 31
+	PDP_BLS_SECTORS     = (PDP_BLOCKSIZE + PDP_BLS_SECTOR_SIZE - 1) / PDP_BLS_SECTOR_SIZE
+	PDP_BLS_TAG_SIZE    = bls12381.G1SizeCompressed
+	PDP_BLS_SEED_SIZE   = 32
+
+	PDP_BLS_KEY_MAGIC   = "PDPB"
+	PDP_BLS_KEY_VERSION = 1
+
+	pdp_bls_dst = "GOPDP-V01-CS01-with-BLS12381G1_XMD:SHA-256_SSWU_RO_"
+)
+
+var ErrBadBLSKey = errors.New("pdp: malformed BLS key")
+
+type PDP_bls_key struct {
+	x    *bls12381.Scalar
+	v    *bls12381.G2
+	seed []byte
+
+	/* The sector generators u_j, derived from seed */
+	u []*bls12381.G1
+}
+
+/* NewPDPParams: Returns the default parameters of scheme, PDP_SCHEME_RSA, PDP_SCHEME_BLS or
+ * PDP_SCHEME_MAC.
+ */
+func NewPDPParams(scheme uint) *PDP_params {
+	return &PDP_params{
+		scheme:        scheme,
+		prf_key_size:  PRF_KEY_SIZE,
+		prp_key_size:  PRP_KEY_SIZE,
+		rsa_key_size:  RSA_KEY_SIZE,
+		block_size:    PDP_BLOCKSIZE,
+		num_challenge: MAGIC_NUM_CHALLENGE_BLOCKS,
+	}
+}
+
+/* pdp_generate_key: Generates a new key pair for the scheme params selects.  Returns an allocated
+ * PDP_key structure or nil on failure.
+ */
+func pdp_generate_key(params *PDP_params) *PDP_key {
+
+	if params != nil && params.scheme == PDP_SCHEME_BLS {
+		return generate_bls_key()
+	}
+	if params != nil && params.scheme == PDP_SCHEME_MAC {
+		return generate_mac_key()
+	}
+
+	return generate_pdp_key()
+}
+
+/* GenerateKey: Exported form of pdp_generate_key. */
+func GenerateKey(params *PDP_params) *PDP_key {
+	return pdp_generate_key(params)
+}
+
+/* generate_bls_key: Generates a BLS key pair, x random in Z_p and a random seed. */
+func generate_bls_key() *PDP_key {
+	var bls = &PDP_bls_key{x: new(bls12381.Scalar), v: new(bls12381.G2)}
+	var err error
+
+	if err = bls.x.Random(rand.Reader); err != nil || bls.x.IsZero() == 1 {
+		return nil
+	}
+	bls.v.ScalarMult(bls.x, bls12381.G2Generator())
+	if bls.seed, err = GenerateRandomBytes(PDP_BLS_SEED_SIZE); err != nil {
+		return nil
+	}
+	bls.u = bls_generators(bls.seed)
+
+	return &PDP_key{bls: bls}
+}
+
+/* bls_public_key: Returns the public components of a BLS key pair. */
+func bls_public_key(key *PDP_key) *PDP_key {
+
+	if key == nil || key.bls == nil {
+		return nil
+	}
+
+	return &PDP_key{bls: &PDP_bls_key{v: key.bls.v, seed: key.bls.seed, u: key.bls.u}}
+}
+
+/* bls_generators: The sector generators u_1 ... u_s of seed. */
+func bls_generators(seed []byte) []*bls12381.G1 {
+	var u = make([]*bls12381.G1, PDP_BLS_SECTORS)
+	var input = make([]byte, 1+len(seed)+4)
+
+	input[0] = 'u'
+	copy(input[1:], seed)
+	for j := range u {
+		binary.BigEndian.PutUint32(input[1+len(seed):], uint32(j))
+		u[j] = new(bls12381.G1)
+		u[j].Hash(input, []byte(pdp_bls_dst))
+	}
+
+	return u
+}
+
+/* bls_hash_index: H(name | i), the hash of block index i of the file named name onto G1. */
+func bls_hash_index(key *PDP_key, name []byte, index uint) *bls12381.G1 {
+	var input = make([]byte, 1+len(key.bls.seed)+PDP_TAG_FILE_NAME_SIZE+8)
+	var point = new(bls12381.G1)
+
+	input[0] = 'i'
+	copy(input[1:], key.bls.seed)
+	copy(input[1+len(key.bls.seed):], name)
+	binary.BigEndian.PutUint64(input[1+len(key.bls.seed)+PDP_TAG_FILE_NAME_SIZE:], uint64(index))
+	point.Hash(input, []byte(pdp_bls_dst))
+
+	return point
+}
+
+/* block_to_sectors: Splits a data block into its sectors, each a big-endian number below p. */
+func block_to_sectors(block []byte) []*bls12381.Scalar {
+	var sectors = make([]*bls12381.Scalar, PDP_BLS_SECTORS)
+
+	for j := range sectors {
+		sectors[j] = new(bls12381.Scalar)
+		start := j * PDP_BLS_SECTOR_SIZE
+		if start >= len(block) {
+			continue
+		}
+		end := start + PDP_BLS_SECTOR_SIZE
+		if end > len(block) {
+			end = len(block)
+		}
+		sectors[j].SetBytes(block[start:end])
+	}
+
+	return sectors
+}
+
+/* bls_block_point: H(name | i) * prod u_j^m_ij, the point a block's tag signs, for key's name. */
+func bls_block_point(key *PDP_key, block []byte, index uint) *bls12381.G1 {
+	var point = bls_hash_index(key, key.name, index)
+	var r0 = new(bls12381.G1)
+
+	for j, m := range block_to_sectors(block) {
+		if m.IsZero() == 1 {
+			continue
+		}
+		r0.ScalarMult(m, key.bls.u[j])
+		point.Add(point, r0)
+	}
+
+	return point
+}
+
+/* bls_tag_block: pdp_tag_block for a BLS key named after its file,
+ * sigma_i = (H(name | i) * prod u_j^m_ij)^x.
+ */
+func (pdpCore *PDPCore) bls_tag_block(key *PDP_key, block *string, blocksize *uint64, index uint) *PDP_tag {
+	var tag *PDP_tag
+	var data = []byte(*block)
+
+	if key.bls.x == nil || len(key.bls.u) != PDP_BLS_SECTORS || len(key.name) != PDP_TAG_FILE_NAME_SIZE {
+		return nil
+	}
+	if uint64(len(data)) > *blocksize {
+		data = data[:*blocksize]
+	}
+
+	tag = pdpCore.generate_pdp_tag()
+	tag.index = index
+
+	sigma := bls_block_point(key, data, index)
+	sigma.ScalarMult(key.bls.x, sigma)
+	tag.Tim = g1_to_bn(sigma)
+
+	return tag
+}
+
+/* bls_check_tag_block: check_pdp_tag_block for a BLS key named after its file,
+ * e(sigma_i, g2) == e(H(name | i) * prod u_j^m_ij, v).
+ */
+func bls_check_tag_block(key *PDP_key, block []byte, tag *PDP_tag) int {
+
+	sigma := bn_to_g1(tag.Tim)
+	if sigma == nil || len(key.name) != PDP_TAG_FILE_NAME_SIZE {
+		return 0
+	}
+
+	return bls_pairing_check(key, sigma, bls_block_point(key, block, tag.index))
+}
+
+/* bls_pairing_check: Returns 1 if e(sigma, g2) == e(point, v), 0 otherwise. */
+func bls_pairing_check(key *PDP_key, sigma *bls12381.G1, point *bls12381.G1) int {
+
+	product := bls12381.ProdPairFrac([]*bls12381.G1{sigma, point}, []*bls12381.G2{bls12381.G2Generator(), key.bls.v},
+		[]int{1, -1})
+	if !product.IsIdentity() {
+		return 0
+	}
+
+	return 1
+}
+
+/* bls_coefficient: The coefficient nu_j = f_k2(j) of the j-th challenged block, reduced mod p. */
+func (pdpCore *PDPCore) bls_coefficient(challenge *PDP_challenge, j uint) *bls12381.Scalar {
+	var prf_result_size uint64 = 0
+	var nu = new(bls12381.Scalar)
+
+	prf_result := pdpCore.generate_prf_f(challenge, j, &prf_result_size)
+	if prf_result == nil {
+		return nil
+	}
+	nu.SetBytes([]byte(*prf_result)[:prf_result_size])
+
+	return nu
+}
+
+/* bls_generate_proof_update: Folds challenged blocks into a BLS proof, creating it if nil.  tags[k] and
+ * blocks[k] are the tag and data of the js[k]-th challenged block.  T holds sigma between calls and mu
+ * the sector sums, reduced by pdp_generate_proof_final.
+ */
+func (pdpCore *PDPCore) bls_generate_proof_update(challenge *PDP_challenge, proof *PDP_proof, js []uint,
+	tags []*PDP_tag, blocks [][]byte) *PDP_proof {
+
+	var sigma = new(bls12381.G1)
+	var r0 = new(bls12381.G1)
+
+	if proof == nil {
+		proof = pdpCore.generate_pdp_proof()
+		proof.T = nil
+		proof.mu = make([]*big.Int, PDP_BLS_SECTORS)
+		for j := range proof.mu {
+			proof.mu[j] = new(big.Int)
+		}
+	}
+	if proof.T == nil {
+		sigma.SetIdentity()
+	} else if sigma = bn_to_g1(proof.T); sigma == nil || len(proof.mu) != PDP_BLS_SECTORS {
+		return nil
+	}
+
+	for k, j := range js {
+		if tags[k] == nil || tags[k].Tim == nil || blocks[k] == nil {
+			return nil
+		}
+		tag := bn_to_g1(tags[k].Tim)
+		nu := pdpCore.bls_coefficient(challenge, j)
+		if tag == nil || nu == nil {
+			return nil
+		}
+
+		/* sigma = sigma * sigma_i^nu */
+		r0.ScalarMult(nu, tag)
+		sigma.Add(sigma, r0)
+
+		add_sector_sums(proof.mu, scalar_to_bn(nu), blocks[k])
+	}
+	proof.T = g1_to_bn(sigma)
+
+	return proof
+}
+
+/* add_sector_sums: mu_j = mu_j + nu * m_ij over the sectors of block, left for the caller to reduce. */
+func add_sector_sums(mu []*big.Int, nu *big.Int, block []byte) {
+
+	for j := range mu {
+		start := j * PDP_BLS_SECTOR_SIZE
+		if start >= len(block) {
+			break
+		}
+		end := start + PDP_BLS_SECTOR_SIZE
+		if end > len(block) {
+			end = len(block)
+		}
+		m := new(big.Int).SetBytes(block[start:end])
+		mu[j].Add(mu[j], m.Mul(m, nu))
+	}
+}
+
+/* bls_combine_proofs: Adds the partial BLS proof into proof.  Returns proof or nil on failure. */
+func bls_combine_proofs(proof *PDP_proof, partial *PDP_proof) *PDP_proof {
+
+	sigma := bn_to_g1(proof.T)
+	other := bn_to_g1(partial.T)
+	if sigma == nil || other == nil || len(proof.mu) != PDP_BLS_SECTORS || len(partial.mu) != PDP_BLS_SECTORS {
+		return nil
+	}
+	sigma.Add(sigma, other)
+	proof.T = g1_to_bn(sigma)
+	for j := range proof.mu {
+		proof.mu[j].Add(proof.mu[j], partial.mu[j])
+	}
+
+	return proof
+}
+
+/* bls_generate_proof_final: Reduces the sector sums mod p. */
+func bls_generate_proof_final(proof *PDP_proof) *PDP_proof {
+	var order = new(big.Int).SetBytes(bls12381.Order())
+
+	if proof.T == nil || len(proof.mu) != PDP_BLS_SECTORS {
+		return nil
+	}
+	for j := range proof.mu {
+		proof.mu[j].Mod(proof.mu[j], order)
+	}
+
+	return proof
+}
+
+/* bls_verify_proof: verify_proof for a BLS key, e(sigma, g2) == e(prod H(name | i)^nu_i * prod u_j^mu_j, v)
+ * with the name the challenge was made for.
+ */
+func (pdpCore *PDPCore) bls_verify_proof(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof,
+	tag_index func(position uint) (uint, bool)) int {
+
+	var point = new(bls12381.G1)
+	var r0 = new(bls12381.G1)
+
+	if len(key.bls.u) != PDP_BLS_SECTORS || proof.T == nil || len(proof.mu) != PDP_BLS_SECTORS {
+		return 0
+	}
+	if challenge.replica != nil || len(challenge.name) != PDP_TAG_FILE_NAME_SIZE {
+		return 0
+	}
+	sigma := bn_to_g1(proof.T)
+	if sigma == nil {
+		return 0
+	}
+
+	/* prod H(name | i)^nu_i over the challenged blocks */
+	indices := pdpCore.generate_prp_pi(challenge)
+	if uint(len(indices)) < challenge.c {
+		return 0
+	}
+	point.SetIdentity()
+	for j := uint(0); j < challenge.c; j++ {
+		var index = indices[j]
+
+		if tag_index != nil {
+			var ok bool
+			if index, ok = tag_index(index); !ok {
+				return 0
+			}
+		}
+		nu := pdpCore.bls_coefficient(challenge, j)
+		if nu == nil {
+			return 0
+		}
+		r0.ScalarMult(nu, bls_hash_index(key, challenge.name, index))
+		point.Add(point, r0)
+	}
+
+	/* times prod u_j^mu_j */
+	for j := range proof.mu {
+		mu := bn_to_scalar(proof.mu[j])
+		if mu == nil {
+			return 0
+		}
+		r0.ScalarMult(mu, key.bls.u[j])
+		point.Add(point, r0)
+	}
+
+	return bls_pairing_check(key, sigma, point)
+}
+
+/* scalar_to_bn: A scalar as a BIGNUM below p. */
+func scalar_to_bn(x *bls12381.Scalar) *big.Int {
+	data, _ := x.MarshalBinary()
+	return new(big.Int).SetBytes(data)
+}
+
+/* bn_to_scalar: The scalar x, or nil unless 0 <= x < p. */
+func bn_to_scalar(x *big.Int) *bls12381.Scalar {
+	var scalar = new(bls12381.Scalar)
+
+	if x == nil || x.Sign() < 0 || x.BitLen() > 8*bls12381.ScalarSize {
+		return nil
+	}
+	if scalar.UnmarshalBinary(x.FillBytes(make([]byte, bls12381.ScalarSize))) != nil {
+		return nil
+	}
+
+	return scalar
+}
+
+/* g1_to_bn: A G1 point as the big-endian number of its compressed encoding, how tags and sigma are
+ * held.  The compression flag keeps the leading byte non-zero.
+ */
+func g1_to_bn(point *bls12381.G1) *big.Int {
+	return new(big.Int).SetBytes(point.BytesCompressed())
+}
+
+/* bn_to_g1: The G1 point g1_to_bn encoded, or nil if it is not a point of G1. */
+func bn_to_g1(x *big.Int) *bls12381.G1 {
+	var point = new(bls12381.G1)
+
+	if x == nil || x.Sign() <= 0 || x.BitLen() > 8*PDP_BLS_TAG_SIZE {
+		return nil
+	}
+	if point.SetBytes(x.FillBytes(make([]byte, PDP_BLS_TAG_SIZE))) != nil || !point.IsOnG1() {
+		return nil
+	}
+
+	return point
+}
+
+/* encode_bls_key: Encodes a BLS key, with x if private is set.  Returns nil on failure. */
+func encode_bls_key(key *PDP_key, private bool) []byte {
+	var buf []byte
+	var x []byte
+
+	if key == nil || key.bls == nil || key.bls.v == nil || (private && key.bls.x == nil) {
+		return nil
+	}
+	if private {
+		x, _ = key.bls.x.MarshalBinary()
+	}
+
+	buf = append([]byte(PDP_BLS_KEY_MAGIC), append_u32(nil, PDP_BLS_KEY_VERSION)...)
+	buf = append_bytes(buf, key.bls.v.BytesCompressed())
+	buf = append_bytes(buf, key.bls.seed)
+	buf = append_bytes(buf, x)
+
+	return buf
+}
+
+/* decode_bls_key: Decodes a BLS key.  v must be in G2 and, if present, x a non-zero scalar with
+ * g2^x == v.  Returns the key or ErrBadBLSKey.
+ */
+func decode_bls_key(data []byte) (*PDP_key, error) {
+	var reader = &pdp_wire_reader{data: data}
+	var bls = &PDP_bls_key{v: new(bls12381.G2)}
+
+	if string(reader.take(4)) != PDP_BLS_KEY_MAGIC || reader.u32() != PDP_BLS_KEY_VERSION {
+		return nil, ErrBadBLSKey
+	}
+	v := reader.bytes(bls12381.G2SizeCompressed)
+	bls.seed = reader.bytes(PDP_BLS_SEED_SIZE)
+	x := reader.bytes(bls12381.ScalarSize)
+	if !reader.done() || len(bls.seed) != PDP_BLS_SEED_SIZE || (len(x) != 0 && len(x) != bls12381.ScalarSize) {
+		return nil, ErrBadBLSKey
+	}
+	if bls.v.SetBytes(v) != nil || !bls.v.IsOnG2() || bls.v.IsIdentity() {
+		return nil, ErrBadBLSKey
+	}
+	if len(x) != 0 {
+		var check = new(bls12381.G2)
+		bls.x = new(bls12381.Scalar)
+		if bls.x.UnmarshalBinary(x) != nil || bls.x.IsZero() == 1 {
+			return nil, ErrBadBLSKey
+		}
+		if check.ScalarMult(bls.x, bls12381.G2Generator()); !check.IsEqual(bls.v) {
+			return nil, ErrBadBLSKey
+		}
+	}
+	bls.u = bls_generators(bls.seed)
+
+	return &PDP_key{bls: bls}, nil
+}
+
+/* WriteBLSKey writes a BLS key to path, with its secret if private is set. */
+func WriteBLSKey(path string, key *PDP_key, private bool) error {
+	var mode os.FileMode = 0644
+
+	data := encode_bls_key(key, private)
+	if data == nil {
+		return ErrBadBLSKey
+	}
+	if private {
+		mode = 0600
+	}
+
+	return ioutil.WriteFile(path, data, mode)
+}
+
+/* LoadBLSKey reads a BLS key written by WriteBLSKey. */
+func LoadBLSKey(path string) (*PDP_key, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode_bls_key(data)
+}
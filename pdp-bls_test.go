@@ -0,0 +1,120 @@
+package gopdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+/* bls_test_file: Tags a random file of size bytes under the BLS or MAC key key.  Returns its path and
+ * the name it was tagged under.
+ */
+func bls_test_file(t testing.TB, key *PDP_key, size int) (string, []byte) {
+	t.Helper()
+
+	path, _ := tag_test_file(t, key, size)
+	name, err := pdp_tag_file_name(path + PDP_TAG_FILE_SUFFIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(name) != PDP_TAG_FILE_NAME_SIZE {
+		t.Fatalf("BLS tag file has a name of %d bytes", len(name))
+	}
+
+	return path, name
+}
+
+func TestBLSFileCannotAnswerForAnother(t *testing.T) {
+	var core = NewPDPCore()
+	var ctx = context.Background()
+
+	key := generate_bls_key()
+	if key == nil {
+		t.Fatal("could not generate a BLS key")
+	}
+	first, first_name := bls_test_file(t, key, 4*PDP_BLOCKSIZE+100)
+	second, second_name := bls_test_file(t, key, 4*PDP_BLOCKSIZE+100)
+	if bytes.Equal(first_name, second_name) {
+		t.Fatal("two files were tagged under the same name")
+	}
+	server_key := bls_public_key(key)
+	named := pdp_named_key(key, first_name)
+
+	challenge := core.pdp_challenge(named, 5)
+	proof, err := core.pdp_prove_file(ctx, first, "", challenge, server_key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.pdp_verify_proof(named, challenge, proof) != 1 {
+		t.Fatal("proof of the first file did not verify")
+	}
+
+	/* A server holding only the second file refuses the first file's challenge */
+	if _, err := core.pdp_prove_file(ctx, second, "", challenge, server_key, nil); !errors.Is(err, ErrBadChallenge) {
+		t.Fatalf("second file proved the first file's challenge: %v", err)
+	}
+
+	/* and if it answers anyway under the second file's name, the proof does not verify */
+	substitute := *challenge
+	substitute.name = second_name
+	proof, err = core.pdp_prove_file(ctx, second, "", &substitute, server_key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.pdp_verify_proof(named, challenge, proof) == 1 {
+		t.Error("second file answered for the first")
+	}
+	if core.pdp_verify_proof(pdp_named_key(key, second_name), &substitute, proof) != 1 {
+		t.Error("proof of the second file did not verify under its own name")
+	}
+}
+
+func TestBLSRemoteAudit(t *testing.T) {
+	var ctx = context.Background()
+
+	key := generate_bls_key()
+	if key == nil {
+		t.Fatal("could not generate a BLS key")
+	}
+	path, name := bls_test_file(t, key, 4*PDP_BLOCKSIZE+100)
+	server := NewPDPServer(bls_public_key(key), nil)
+	if err := server.AddFile("data", path, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := grpc_test_client(t, server, pdp_named_key(key, name)).Audit(ctx, "data", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := grpc_test_client(t, server, key).Audit(ctx, "data", 5); err == nil {
+		t.Error("audit of a BLS file without its name passed")
+	}
+	other := make([]byte, PDP_TAG_FILE_NAME_SIZE)
+	if err := grpc_test_client(t, server, pdp_named_key(key, other)).Audit(ctx, "data", 5); err == nil {
+		t.Error("audit of a BLS file under another name passed")
+	}
+	if pdp_scheme(key) != "bls" {
+		t.Errorf("BLS key is labelled %q", pdp_scheme(key))
+	}
+}
+
+func TestProofBatchBounds(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := pdp_named_key(generate_bls_key(), []byte("batch test file!"))
+	challenge := core.pdp_challenge(key, 2)
+	block := string(make([]byte, 100))
+	size := uint64(len(block))
+	tag := core.pdp_tag_block(key, &block, &size, 0)
+	if tag == nil {
+		t.Fatal("could not tag a block")
+	}
+
+	/* Mismatched slices and sizes past the block's end fail or are clamped, never panic */
+	if core.pdp_generate_proof_batch(key, challenge, []*PDP_tag{tag, tag}, []*string{&block}, []uint64{size, size}) != nil {
+		t.Error("proved a batch with fewer blocks than tags")
+	}
+	if core.pdp_generate_proof_batch(key, challenge, []*PDP_tag{tag}, []*string{&block}, []uint64{PDP_BLOCKSIZE}) == nil {
+		t.Error("could not prove a block shorter than its size")
+	}
+}
@@ -2,6 +2,7 @@ package gopdp
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"math/big"
 )
 
@@ -21,8 +22,6 @@ func NewPDPCore() *PDPCore {
 
 func (pdpCore *PDPCore) pdp_tag_block(key *PDP_key, block *string, blocksize *uint64, index uint) *PDP_tag {
 	var tag *PDP_tag = nil
-	// BN_CTX * ctx = NULL
-	var phi *big.Int
 	var fdh_hash *big.Int
 	var message *big.Int
 	var r0 *big.Int
@@ -32,10 +31,19 @@ func (pdpCore *PDPCore) pdp_tag_block(key *PDP_key, block *string, blocksize *ui
 	if key == nil || block == nil || blocksize == nil {
 		return nil
 	}
-	if key.rsa.PublicKey.N == nil {
+	if key.bls != nil {
+		return pdpCore.bls_tag_block(key, block, blocksize, index)
+	}
+	if key.mac != nil {
+		return pdpCore.mac_tag_block(key, block, blocksize, index)
+	}
+	if key.rsa == nil || key.rsa.PublicKey.N == nil {
+		return nil
+	}
+	if key.rsa.PublicKey.E == 0 || key.rsa.D == nil {
 		return nil
 	}
-	if key.rsa.PublicKey.E == 0 {
+	if len(key.rsa.Primes) < 2 {
 		return nil
 	}
 	for i := 0; i < 2; i++ {
@@ -55,27 +63,28 @@ func (pdpCore *PDPCore) pdp_tag_block(key *PDP_key, block *string, blocksize *ui
 
 	/* Perform the pseudo-random function (prf) Wi = w_v(i) */
 	tag.index_prf = pdpCore.generate_prf_w(key, tag.index, tag.index_prf_size)
+	if tag.index_prf == nil {
+		return nil
+	}
 
 	/* Peform the full-domain hash function h(Wi) */
 	fdh_hash = pdpCore.generate_fdh_h(key, tag.index_prf, *tag.index_prf_size)
+	if fdh_hash == nil {
+		return nil
+	}
 
 	/* Turn the data block into a BIGNUM */
-	message, _ = new(big.Int).SetString(*block, int(*blocksize))
-
-	/* Calculate phi */
-	r0 = new(big.Int).Sub(key.rsa.Primes[0], new(big.Int).SetInt64(1))
-	r1 = new(big.Int).Sub(key.rsa.Primes[0], new(big.Int).SetInt64(1))
-	phi = new(big.Int).Mul(fdh_hash, r0)
-
-	/* Reduce the message by modulo phi(N) */
-	message = new(big.Int).Mod(message, phi)
-
-	/* r0 = g^m */
-	r0 = new(big.Int).Exp(key.g, message, key.rsa.N)
-	/* r1 = h(W_i) * g^m */
-	r1 = new(big.Int).Mul(fdh_hash, r0)
-	/* T_im = (h(W_i) * g^m)^d mod N */
-	tag.Tim = new(big.Int).Exp(r1, key.rsa.D, key.rsa.N)
+	message = block_to_bn(block, *blocksize)
+
+	/* r0 = g^m, computed mod p and mod q with the message reduced by p-1 and q-1 */
+	r0 = generator_exp(key, message)
+	/* r1 = h(W_i) * g^m mod N */
+	r1 = ModMul(fdh_hash, r0, key.rsa.N)
+	/* T_im = (h(W_i) * g^m)^d mod N, blinded and computed with CRT */
+	tag.Tim = crt_sign(key, r1)
+	if tag.Tim == nil {
+		return nil
+	}
 
 	return tag
 
@@ -94,8 +103,13 @@ func (pdpCore *PDPCore) pdp_challenge(key *PDP_key, numfileblocks uint) *PDP_cha
 		return nil
 	}
 
+	/* A BLS or MAC challenge has no secret */
+	if key.bls != nil || key.mac != nil {
+		return pdpCore.pdp_challenge_public(key, numfileblocks)
+	}
+
 	/* Verify keys */
-	if key.rsa.N == nil {
+	if key.rsa == nil || key.rsa.N == nil {
 		return nil
 	}
 	if key.g == nil {
@@ -109,7 +123,7 @@ func (pdpCore *PDPCore) pdp_challenge(key *PDP_key, numfileblocks uint) *PDP_cha
 	for {
 		challenge.s, _ = rand.Int(rand.Reader, key.rsa.N)
 		r0 = new(big.Int).GCD(nil, nil, challenge.s, key.rsa.N)
-		if r0.Cmp(big.NewInt(1)) == 1 {
+		if r0.Cmp(bigOne) == 0 {
 			break
 		}
 	}
@@ -131,7 +145,7 @@ func (pdpCore *PDPCore) pdp_challenge(key *PDP_key, numfileblocks uint) *PDP_cha
 		challenge.c = MAGIC_NUM_CHALLENGE_BLOCKS
 	}
 
-	challenge.numfileblocks = MAGIC_NUM_CHALLENGE_BLOCKS
+	challenge.numfileblocks = numfileblocks
 
 	return challenge
 }
@@ -155,9 +169,16 @@ func (pdpCore *PDPCore) pdp_generate_proof_update(key *PDP_key, challenge *PDP_c
 	if key == nil || challenge == nil || tag == nil || block == nil || blocksize == nil {
 		return nil
 	}
+	if key.bls != nil || key.mac != nil {
+		data := block_data(block, *blocksize)
+		if key.mac != nil {
+			return pdpCore.mac_generate_proof_update(challenge, proof, []uint{j}, []*PDP_tag{tag}, [][]byte{data})
+		}
+		return pdpCore.bls_generate_proof_update(challenge, proof, []uint{j}, []*PDP_tag{tag}, [][]byte{data})
+	}
 
 	/* Verify keys */
-	if key.rsa.N == nil {
+	if key.rsa == nil || key.rsa.N == nil {
 		return nil
 	}
 
@@ -167,27 +188,26 @@ func (pdpCore *PDPCore) pdp_generate_proof_update(key *PDP_key, challenge *PDP_c
 		proof = pdpCore.generate_pdp_proof()
 	}
 
+	if proof.T == nil {
+		proof.T = new(big.Int)
+	}
+	if proof.rho_temp == nil {
+		proof.rho_temp = new(big.Int)
+	}
+
 	/* Data block into a BIGNUM */
-	message, _ = new(big.Int).SetString(*block, int(*blocksize))
+	message = block_to_bn(block, *blocksize)
 
 	if USE_E_PDP == 1 { /* Use E-PDP */
 
 		/* No coefficients to calculate in E-PDP, so T is just product of tags */
-		if proof.T.Cmp(big.NewInt(0)) == 1 {
-			if tag.Tim == nil {
-				return nil
-			} else {
-				proof.T.Set(tag.Tim)
-			}
-		} else {
-			proof.T = new(big.Int).Mul(proof.T, tag.Tim)
-		}
-
-		/* Copy message into r0 for summing */
-		if message == nil {
+		if tag.Tim == nil {
 			return nil
 		}
-		r0.Set(message)
+		r0 = tag.Tim
+
+		/* Messages are summed without a coefficient */
+		coefficient_a = bigOne
 	} else { /* Use S-PDP */
 
 		/* Compute the coefficient for block tag->index, where a_j = f_k2(j) */
@@ -197,42 +217,23 @@ func (pdpCore *PDPCore) pdp_generate_proof_update(key *PDP_key, challenge *PDP_c
 		}
 
 		/* Convert prf result to a big number */
-		a, err := new(big.Int).SetString(*prf_result, int(prf_result_size))
-		coefficient_a = a
-		if err {
-			return nil
-		}
+		coefficient_a = prf_to_bn(prf_result, prf_result_size)
 
 		/* Compute T_im ^ coefficient_a */
 		r0 = new(big.Int).Exp(tag.Tim, coefficient_a, key.rsa.N)
-		if r0 == nil {
-			return nil
-		}
-
-		/* Update T, where T = T1m^a1 * ... * Tim^aj */
-		if proof.T.Cmp(big.NewInt(0)) == 1 {
-			if r0 == nil {
-				return nil
-			}
-			proof.T.Set(r0)
-		} else {
-			proof.T = new(big.Int).Mul(proof.T, r0)
-		}
-		/* Compute coefficient_a * message, where message = data block*/
-		r0 = new(big.Int).Mul(coefficient_a, message)
+	}
 
+	/* Update T, where T = T1m^a1 * ... * Tim^aj mod N */
+	if proof.T.Sign() == 0 {
+		proof.T.Set(r0)
+	} else {
+		proof.T = ModMul(proof.T, r0, key.rsa.N)
 	}
 
 	/* Store the sum of (coefficient_a_j * message) in rho_temp. */
 	/* If E-PDP, then there's no coefficient */
-	if proof.rho_temp.Cmp(big.NewInt(0)) == 0 {
-		if r0 == nil {
-			return nil
-		}
-		proof.rho_temp.Set(r0)
-	} else {
-		proof.rho_temp = new(big.Int).Add(proof.rho_temp, r0)
-	}
+	r0 = new(big.Int).Mul(coefficient_a, message)
+	proof.rho_temp = new(big.Int).Add(proof.rho_temp, r0)
 	/* We do not compute g_s^coefficients*messages or H(g_s^coefficients*messages) until the call to generate_proof_final */
 
 	return proof
@@ -249,13 +250,24 @@ func (pdpCore *PDPCore) pdp_generate_proof_final(key *PDP_key, challenge *PDP_ch
 	if proof == nil {
 		return nil
 	}
-	if key == nil || challenge == nil || proof.rho_temp == nil || proof.rho_temp.Cmp(big.NewInt(0)) == 1 {
+	if key != nil && key.bls != nil {
+		return bls_generate_proof_final(proof)
+	}
+	if key != nil && key.mac != nil {
+		return mac_generate_proof_final(proof)
+	}
+	if key == nil || challenge == nil || proof.T == nil || proof.rho_temp == nil {
 		return nil
 	}
-	if key.rsa.N == nil || challenge.g_s == nil {
+	if key.rsa == nil || key.rsa.N == nil {
 		return nil
 	}
 
+	/* A public challenge has no g_s; its proof is T and M = M1 + M2 + ... + Mc, see pdp_challenge_public */
+	if challenge.g_s == nil {
+		return proof
+	}
+
 	/* Compute g_s^ (M1 + M2 + ... + Mc) mod N*/
 	proof.rho_temp = new(big.Int).Exp(challenge.g_s, proof.rho_temp, key.rsa.N)
 	if proof.rho_temp == nil {
@@ -263,7 +275,7 @@ func (pdpCore *PDPCore) pdp_generate_proof_final(key *PDP_key, challenge *PDP_ch
 	}
 
 	/* Compute H(g_s^(M1 + M2 + ... + Mc)) */
-	proof.rho = pdpCore.generate_H(proof.rho_temp, proof.rho_size)
+	proof.rho = pdpCore.generate_H(proof.rho_temp, &proof.rho_size)
 	if proof.rho == nil {
 		return nil
 	}
@@ -273,30 +285,45 @@ func (pdpCore *PDPCore) pdp_generate_proof_final(key *PDP_key, challenge *PDP_ch
 }
 
 /* pdp_verify_proof: The client-side proof verification function.
- * Takes a user's pdp-key, a challenge, its correspond proof and the file size in blocks.
+ * Takes a user's pdp-key, a challenge, its correspond proof and the file size in blocks.  A challenge
+ * from pdp_challenge_public is verified with only the public file key.
  * Returns a 1 if verified, 0 otherwise.
  */
-func pdp_verify_proof(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof) int {
+func (pdpCore *PDPCore) pdp_verify_proof(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof) int {
+	return pdpCore.verify_proof(key, challenge, proof, nil)
+}
+
+/* verify_proof: pdp_verify_proof with the index each challenged block was tagged under given by tag_index,
+ * which returns false for a position it cannot map.  A nil tag_index tags blocks by their position.
+ */
+func (pdpCore *PDPCore) verify_proof(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof,
+	tag_index func(position uint) (uint, bool)) int {
 
 	var tao *big.Int
 	var denom *big.Int
-	var coefficient_a *big.Int
-	var fdh_hash *big.Int
+	var fdh_hashes []*big.Int
+	var coefficients []*big.Int
 	var tao_s *big.Int
-	var r0 *big.Int
 	var index_prf *string
 	var index_prf_size uint64 = 0
 	var prf_result *string
 	var prf_result_size uint64 = 0
 	var H_result *string
 	var H_result_size uint64 = 0
-	var j uint = 0
 	var result int = 0
-	var indices *uint
+	var indices []uint
+	var replica_prf []byte
+	var mask_sum *big.Int
 
 	if key == nil || challenge == nil || proof == nil {
 		return -1
 	}
+	if key.bls != nil {
+		return pdpCore.bls_verify_proof(key, challenge, proof, tag_index)
+	}
+	if key.mac != nil {
+		return pdpCore.mac_verify_proof(key, challenge, proof, tag_index)
+	}
 
 	/* Verify keys */
 	if key.rsa == nil {
@@ -309,37 +336,61 @@ func pdp_verify_proof(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof)
 		return 0
 	}
 
-	/* Make sure we don't have a "sanitized" challenge */
-	if challenge.s == nil {
+	/* Make sure we don't have a "sanitized" challenge.  A public challenge has neither s nor g_s */
+	if challenge.s == nil && challenge.g_s != nil {
+		return 0
+	}
+	if proof.T == nil {
+		return 0
+	}
+	if challenge.g_s == nil && (proof.rho_temp == nil || proof.rho_temp.Sign() < 0) {
+		return 0
+	}
+	/* A public proof is only sound under a file key's e_f; see pdp-public.go */
+	if challenge.g_s == nil && (key.e == nil || key.e.Cmp(pdp_file_exponent) != 0) {
+		return 0
+	}
+	if challenge.g_s != nil && proof.rho == nil {
 		return 0
 	}
 
 	/* Compute tao where tao = T^e */
-	tao = new(big.Int).Exp(proof.T, new(big.Int).SetInt64(int64(key.rsa.E)), key.rsa.N)
-	if tao == nil {
+	tao = new(big.Int).Exp(proof.T, pdp_key_exponent(key), key.rsa.N)
+
+	/* Compute the indices i_j = pi_k1(j); the indices of blocks to sample */
+	indices = pdpCore.generate_prp_pi(challenge)
+	if uint(len(indices)) < challenge.c {
 		return 0
 	}
+	fdh_hashes = make([]*big.Int, challenge.c)
+	coefficients = make([]*big.Int, challenge.c)
+	if challenge.replica != nil {
+		replica_prf = replica_key(key, *challenge.replica)
+		mask_sum = new(big.Int)
+	}
+	for j := uint(0); j < challenge.c; j++ {
+		var index = indices[j]
 
-	/* Compute the indices i_j = pi_k1(j); the indices of blocks to sample */
-	indices = generate_prp_pi(challenge)
-	for j := 0; j < int(challenge.c); j++ {
+		if tag_index != nil {
+			var ok bool
+			if index, ok = tag_index(index); !ok {
+				return 0
+			}
+		}
 
 		/* Perform the pseudo-random function Wi = w_v(i) */
-		index_prf = pdpCore.generate_prf_w(key, indices[j], &index_prf_size)
+		index_prf = pdpCore.generate_prf_w(key, index, &index_prf_size)
 		if index_prf == nil {
 			return 0
 		}
 		/* Calculate the full-domain hash h(W_i) */
-		fdh_hash = pdpCore.generate_fdh_h(key, index_prf, index_prf_size)
-		if fdh_hash == nil {
+		fdh_hashes[j] = pdpCore.generate_fdh_h(key, index_prf, index_prf_size)
+		if fdh_hashes[j] == nil {
 			return 0
 		}
 
 		if USE_E_PDP == 1 { /* Use E-PDP */
-			if fdh_hash == nil {
-				return nil
-			}
-			r0.Set(fdh_hash)
+			coefficients[j] = bigOne
 		} else { /* Use S-PDP */
 			/* Generate the coefficient for block index a = f_k2(j) */
 			prf_result = pdpCore.generate_prf_f(challenge, j, &prf_result_size)
@@ -348,33 +399,19 @@ func pdp_verify_proof(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof)
 			}
 
 			/* Convert prf coefficient result to a BIGNUM */
-			prf_result = new(big.Int).SetString(prf_result_size, coefficient_a)
-			if prf_result == nil {
-				return 0
-			}
-
-			/* Calculate h(W_i)^a */
-			r0 = new(big.Int).Exp(fdh_hash, coefficient_a, key.rsa.N)
-			if r0 == nil {
-				return 0
-			}
+			coefficients[j] = prf_to_bn(prf_result, prf_result_size)
 		}
 
-		/* Calculate products of h(W_i)^a (no coefficeint a in E-PDP) */
-		if denom.Cmp(big.NewInt(0)) == 1 {
-			if r0 == nil {
-				return 0
-			}
-			denom.Set(r0)
-		} else {
-			denom = ModMul(denom, r0, key.rsa.N)
-			if denom == nil {
-				return 0
-			}
+		/* A replica's blocks are masked, m~ = m + r; sum a * r to unmask the proof */
+		if replica_prf != nil {
+			mask_sum.Add(mask_sum, new(big.Int).Mul(coefficients[j], replica_mask(replica_prf, indices[j])))
 		}
 
 	} /* end for */
 
+	/* Calculate products of h(W_i)^a (no coefficeint a in E-PDP) */
+	denom = multi_exp(fdh_hashes, coefficients, key.rsa.N)
+
 	/* Calculate tao, where tao = tao/h(W_i)^a mod N */
 	/* Inverse h(W_i)^a to create 1/h(W_i)^a */
 	denom = new(big.Int).ModInverse(denom, key.rsa.N)
@@ -382,25 +419,45 @@ func pdp_verify_proof(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof)
 		return 0
 	}
 	/* tao = tao * 1/h(W_i)^a mod N*/
-	tao = new(big.Int).ModMul(tao, denom, key.rsa.N)
-	if tao == nil {
-		return 0
+	tao = ModMul(tao, denom, key.rsa.N)
+
+	/* For a replica, tao = tao * g^(sum a * r) mod N, so it matches the masked blocks */
+	if mask_sum != nil {
+		tao = ModMul(tao, generator_exp(key, mask_sum), key.rsa.N)
+	}
+
+	/* A public proof carries M; does tao == g^M mod N? */
+	if challenge.g_s == nil && proof.R == nil {
+		if tao.Cmp(generator_exp(key, proof.rho_temp)) == 0 {
+			result = 1
+		}
+		return result
+	}
+
+	/* A masked one carries R = g^r and mu = r + gamma * M; does g^mu == R * tao^gamma mod N? */
+	if challenge.g_s == nil {
+		gamma := mask_gamma(challenge, proof)
+		if gamma == nil || in_z_star_n(key, proof.R) == 0 {
+			return 0
+		}
+		tao = ModMul(proof.R, new(big.Int).Exp(tao, gamma, key.rsa.N), key.rsa.N)
+		if tao.Cmp(generator_exp(key, proof.rho_temp)) == 0 {
+			result = 1
+		}
+		return result
 	}
 
 	/* Calculate tao^s mod N*/
 	tao_s = new(big.Int).Exp(tao, challenge.s, key.rsa.N)
-	if tao_s == nil {
-		return 0
-	}
 
 	/* Calculate H(tao^s mod N) */
-	H_result = generate_H(tao_s, &(H_result_size))
+	H_result = pdpCore.generate_H(tao_s, &(H_result_size))
 	if H_result == nil {
 		return 0
 	}
 
 	/* The final verification step.  Does rho == rho? */
-	if H_result == proof.rho {
+	if subtle.ConstantTimeCompare([]byte(*H_result), []byte(*proof.rho)) == 1 {
 		result = 1
 	}
 
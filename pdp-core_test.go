@@ -0,0 +1,112 @@
+package gopdp
+
+import (
+	"context"
+	"crypto/rand"
+	RSA "crypto/rsa"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+/* new_test_key: Generates a PDP key pair or fails the test. */
+func new_test_key(t testing.TB) *PDP_key {
+	t.Helper()
+
+	key := generate_pdp_key()
+	if key == nil {
+		t.Fatal("generate_pdp_key failed")
+	}
+
+	return key
+}
+
+/* write_test_file: Writes size random bytes to a file in a temporary directory.  Returns its path and
+ * contents.
+ */
+func write_test_file(t testing.TB, size int) (string, []byte) {
+	t.Helper()
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "data")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, data
+}
+
+/* tag_test_file: Writes a random file of size bytes and tags it under key into <file>.tag.  Returns the
+ * file's path and contents.
+ */
+func tag_test_file(t testing.TB, key *PDP_key, size int) (string, []byte) {
+	t.Helper()
+
+	path, data := write_test_file(t, size)
+	if err := NewPDPCore().pdp_tag_file(context.Background(), key, path, "", &PDP_tag_options{Workers: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, data
+}
+
+/* test_block: The index-th block of data, short if it is the partial last block. */
+func test_block(data []byte, index int) []byte {
+	end := (index + 1) * PDP_BLOCKSIZE
+	if end > len(data) {
+		end = len(data)
+	}
+
+	return data[index*PDP_BLOCKSIZE : end]
+}
+
+/* public_test_key: The public components of an RSA key, as a server holds them. */
+func public_test_key(key *PDP_key) *PDP_key {
+	return &PDP_key{rsa: &RSA.PrivateKey{PublicKey: key.rsa.PublicKey}, g: key.g}
+}
+
+func TestTagChallengeProveVerify(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	path, _ := tag_test_file(t, key, 37*PDP_BLOCKSIZE+100)
+
+	challenge := core.pdp_challenge(key, 38)
+	if challenge == nil {
+		t.Fatal("pdp_challenge failed")
+	}
+	proof, err := core.pdp_prove_file(context.Background(), path, "", core.sanitize_pdp_challenge(challenge),
+		public_test_key(key), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.pdp_verify_proof(key, challenge, proof) != 1 {
+		t.Fatal("proof did not verify")
+	}
+
+	/* The proof does not verify against another challenge */
+	if core.pdp_verify_proof(key, core.pdp_challenge(key, 38), proof) == 1 {
+		t.Fatal("proof verified against another challenge")
+	}
+}
+
+func TestPRPIsAPermutation(t *testing.T) {
+	for _, n := range []uint{1, 2, 3, 17, 460, 1000, 4097} {
+		k1, _ := GenerateRandomBytes(PRP_KEY_SIZE)
+		challenge := &PDP_challenge{c: n, numfileblocks: n, k1: &k1}
+
+		seen := make(map[uint]bool)
+		for _, index := range NewPDPCore().generate_prp_pi(challenge) {
+			if index >= n || seen[index] {
+				t.Fatalf("n=%d: index %d repeated or out of range", n, index)
+			}
+			seen[index] = true
+		}
+		if uint(len(seen)) != n {
+			t.Fatalf("n=%d: %d indices", n, len(seen))
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package gopdp
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+var bigOne = big.NewInt(1)
+
+/* crt_precomputed: Checks that the Chinese Remainder Theorem values of the RSA key (d mod p-1, d mod q-1
+ * and q^-1 mod p) are populated in key.rsa.Precomputed.  Only two-prime keys are supported.
+ * The values are filled in once when the key is generated or loaded (see generate_pdp_key and
+ * read_pdp_keypair), never here, so keys can be shared between concurrent audits.  A private key
+ * without them is a bug in whoever made it, and crt_sign refuses it rather than silently signing
+ * several times slower.
+ * Returns 1 if the key can be used with the CRT functions, 0 otherwise.
+ */
+func crt_precomputed(key *PDP_key) int {
+
+	if key == nil || key.rsa == nil || key.rsa.D == nil {
+		return 0
+	}
+	if len(key.rsa.Primes) != 2 || key.rsa.Primes[0] == nil || key.rsa.Primes[1] == nil {
+		return 0
+	}
+
+	if key.rsa.Precomputed.Dp == nil || key.rsa.Precomputed.Dq == nil || key.rsa.Precomputed.Qinv == nil {
+		return 0
+	}
+
+	return 1
+}
+
+/* crt_combine: Recombines m1 = x mod p and m2 = x mod q into x mod N using Garner's formula,
+ * x = m2 + q * (q^-1 * (m1 - m2) mod p).
+ */
+func crt_combine(key *PDP_key, m1 *big.Int, m2 *big.Int) *big.Int {
+	var p = key.rsa.Primes[0]
+	var q = key.rsa.Primes[1]
+	var h *big.Int
+
+	h = new(big.Int).Sub(m1, m2)
+	h.Mul(h, key.rsa.Precomputed.Qinv)
+	h.Mod(h, p)
+	h.Mul(h, q)
+
+	return h.Add(h, m2)
+}
+
+/* crt_exp: Computes base^exponent mod N.  If the private primes are available the exponent is reduced
+ * by p-1 and q-1 and the two half-size exponentiations are recombined, otherwise a plain modular
+ * exponentiation is done.  base must be in Z*_N.
+ */
+func crt_exp(key *PDP_key, base *big.Int, exponent *big.Int) *big.Int {
+	var p *big.Int
+	var q *big.Int
+	var r0 *big.Int
+	var m1 *big.Int
+	var m2 *big.Int
+
+	if crt_precomputed(key) == 0 {
+		return new(big.Int).Exp(base, exponent, key.rsa.N)
+	}
+	p = key.rsa.Primes[0]
+	q = key.rsa.Primes[1]
+
+	/* m1 = base^(exponent mod p-1) mod p */
+	r0 = new(big.Int).Sub(p, bigOne)
+	r0.Mod(exponent, r0)
+	m1 = new(big.Int).Exp(new(big.Int).Mod(base, p), r0, p)
+
+	/* m2 = base^(exponent mod q-1) mod q */
+	r0 = new(big.Int).Sub(q, bigOne)
+	r0.Mod(exponent, r0)
+	m2 = new(big.Int).Exp(new(big.Int).Mod(base, q), r0, q)
+
+	return crt_combine(key, m1, m2)
+}
+
+/* crt_sign: Computes x^d mod N with the private key.  x is blinded with a random r^e before the
+ * CRT exponentiation so the timing does not depend on x, and the result is checked against
+ * the public exponent so a faulty CRT half cannot leak a factor of N.  Both powers of e are taken
+ * with crt_exp, which keeps a file key's large exponent cheap.  The key must have its CRT values, see
+ * crt_precomputed.
+ * Returns the result or nil on failure.
+ */
+func crt_sign(key *PDP_key, x *big.Int) *big.Int {
+	var e *big.Int
+	var r *big.Int
+	var r_inv *big.Int
+	var c *big.Int
+	var m1 *big.Int
+	var m2 *big.Int
+	var y *big.Int
+	var err error
+
+	if key == nil || key.rsa == nil || key.rsa.N == nil || x == nil {
+		return nil
+	}
+	if crt_precomputed(key) == 0 {
+		return nil
+	}
+	e = pdp_key_exponent(key)
+
+	/* Pick a blinding factor r from Z*_N */
+	for {
+		r, err = rand.Int(rand.Reader, key.rsa.N)
+		if err != nil {
+			return nil
+		}
+		if r.Sign() == 0 {
+			continue
+		}
+		r_inv = new(big.Int).ModInverse(r, key.rsa.N)
+		if r_inv != nil {
+			break
+		}
+	}
+
+	/* c = x * r^e mod N */
+	c = crt_exp(key, r, e)
+	c = ModMul(c, x, key.rsa.N)
+
+	/* m1 = c^(d mod p-1) mod p, m2 = c^(d mod q-1) mod q */
+	m1 = new(big.Int).Exp(new(big.Int).Mod(c, key.rsa.Primes[0]), key.rsa.Precomputed.Dp, key.rsa.Primes[0])
+	m2 = new(big.Int).Exp(new(big.Int).Mod(c, key.rsa.Primes[1]), key.rsa.Precomputed.Dq, key.rsa.Primes[1])
+
+	/* y = c^d * r^-1 = x^d mod N */
+	y = crt_combine(key, m1, m2)
+	y = ModMul(y, r_inv, key.rsa.N)
+
+	/* Verify y^e == x mod N */
+	if crt_exp(key, y, e).Cmp(new(big.Int).Mod(x, key.rsa.N)) != 0 {
+		return nil
+	}
+
+	return y
+}
@@ -0,0 +1,144 @@
+package gopdp
+
+import (
+	"crypto/rand"
+	RSA "crypto/rsa"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+/* new_crt_test_key: A PDP key around a fresh RSA key of bits bits. */
+func new_crt_test_key(t testing.TB, bits int) *PDP_key {
+	t.Helper()
+
+	rsa, err := RSA.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &PDP_key{rsa: rsa, g: pick_pdp_generator(rsa.N)}
+}
+
+func TestCRTMatchesExp(t *testing.T) {
+	key := new_crt_test_key(t, 1024)
+
+	for i := 0; i < 8; i++ {
+		x, _ := rand.Int(rand.Reader, key.rsa.N)
+		e, _ := rand.Int(rand.Reader, key.rsa.N)
+
+		if crt_exp(key, x, e).Cmp(new(big.Int).Exp(x, e, key.rsa.N)) != 0 {
+			t.Fatal("crt_exp differs from Exp")
+		}
+		if crt_sign(key, x).Cmp(new(big.Int).Exp(x, key.rsa.D, key.rsa.N)) != 0 {
+			t.Fatal("crt_sign differs from Exp")
+		}
+	}
+
+	/* A key without its primes or its CRT values is refused, not signed with a plain exponentiation */
+	x := big.NewInt(12345)
+	if crt_sign(&PDP_key{rsa: &RSA.PrivateKey{PublicKey: key.rsa.PublicKey, D: key.rsa.D}}, x) != nil {
+		t.Fatal("crt_sign signed without the primes")
+	}
+	bare := &PDP_key{rsa: &RSA.PrivateKey{PublicKey: key.rsa.PublicKey, D: key.rsa.D, Primes: key.rsa.Primes}, g: key.g}
+	if crt_sign(bare, x) != nil {
+		t.Fatal("crt_sign signed without the CRT values")
+	}
+	block := "block"
+	size := uint64(len(block))
+	if NewPDPCore().pdp_tag_block(bare, &block, &size, 0) != nil {
+		t.Fatal("pdp_tag_block tagged without the CRT values")
+	}
+}
+
+func TestCRTSharedKey(t *testing.T) {
+	var wg sync.WaitGroup
+
+	key := new_crt_test_key(t, 1024)
+	x := big.NewInt(12345)
+	want := new(big.Int).Exp(x, key.rsa.D, key.rsa.N)
+
+	/* The key is only read, so concurrent signers need no locking (run with -race) */
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if crt_sign(key, x).Cmp(want) != 0 {
+				errs <- fmt.Errorf("crt_sign differs from Exp")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkCRT(b *testing.B) {
+	for _, bits := range []int{2048, 3072} {
+		key := new_crt_test_key(b, bits)
+		x, _ := rand.Int(rand.Reader, key.rsa.N)
+
+		b.Run(fmt.Sprintf("Exp/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				new(big.Int).Exp(x, key.rsa.D, key.rsa.N)
+			}
+		})
+		b.Run(fmt.Sprintf("crt_exp/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				crt_exp(key, x, key.rsa.D)
+			}
+		})
+		b.Run(fmt.Sprintf("crt_sign/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				crt_sign(key, x)
+			}
+		})
+	}
+}
+
+/* tag_block_without_crt: pdp_tag_block with both exponentiations done mod N, as before CRT tagging. */
+func tag_block_without_crt(core *PDPCore, key *PDP_key, block *string, blocksize *uint64, index uint) *big.Int {
+	var index_prf_size uint64
+
+	index_prf := core.generate_prf_w(key, index, &index_prf_size)
+	fdh_hash := core.generate_fdh_h(key, index_prf, index_prf_size)
+	r0 := new(big.Int).Exp(key.g, block_to_bn(block, *blocksize), key.rsa.N)
+	r1 := ModMul(fdh_hash, r0, key.rsa.N)
+
+	return new(big.Int).Exp(r1, key.rsa.D, key.rsa.N)
+}
+
+func BenchmarkTagBlock(b *testing.B) {
+	var core = NewPDPCore()
+
+	data := make([]byte, PDP_BLOCKSIZE)
+	rand.Read(data)
+	block := string(data)
+	size := uint64(len(block))
+
+	for _, bits := range []int{1024, 2048} {
+		key := new_crt_test_key(b, bits)
+		v := string(make([]byte, PRF_KEY_SIZE))
+		key.v = &v
+
+		/* Both paths make the same tag */
+		if core.pdp_tag_block(key, &block, &size, 7).Tim.Cmp(tag_block_without_crt(core, key, &block, &size, 7)) != 0 {
+			b.Fatal("CRT and plain tags differ")
+		}
+
+		b.Run(fmt.Sprintf("crt/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				core.pdp_tag_block(key, &block, &size, uint(i))
+			}
+		})
+		b.Run(fmt.Sprintf("no-crt/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tag_block_without_crt(core, key, &block, &size, uint(i))
+			}
+		})
+	}
+}
@@ -0,0 +1,498 @@
+package gopdp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+/* Dynamic files support modifying, inserting and deleting single blocks without re-tagging the rest.
+ * A block's tag binds a tag index, W = w_v(tag index), instead of its position, and the verifier keeps
+ * a PDP_index_table mapping each position to its tag index.  Every new or rewritten block gets a fresh
+ * tag index that is never reused, so a server cannot answer with the tag of an older version of a block.
+ *
+ * Challenges and proofs are the ordinary ones over positions: the prover and the tag file layout are
+ * unchanged, and only the verifier maps the challenged positions through the table, see
+ * pdp_verify_proof_table.  A tagged file starts out with the identity table, so any tag file can be
+ * changed this way.
+ *
+ * Inserting or deleting a block moves the data and tag records after it, which costs I/O but no
+ * tagging.  The three files are updated in turn, data, tags and table, and not atomically together.
+ * A modify that fails part way can be repeated, as the table still holds the tag index it used.  An
+ * insert or delete that fails part way leaves the file to be tagged again; once the tag file records
+ * the new block count, the table no longer matches it and is refused with ErrBadIndexTable.
+ */
+
+const (
+	PDP_INDEX_TABLE_MAGIC       = "PDPI"
+	PDP_INDEX_TABLE_VERSION     = 1
+	PDP_INDEX_TABLE_HEADER_SIZE = 24
+	PDP_INDEX_TABLE_SUFFIX      = ".idx"
+
+	/* Bytes moved per read and write when a block is inserted or deleted */
+	PDP_SHIFT_CHUNK = 1 << 20
+)
+
+var ErrBadIndexTable = errors.New("pdp: malformed index table")
+var ErrBadPosition = errors.New("pdp: block position out of range")
+var ErrBadBlockSize = errors.New("pdp: only the last block may be shorter than the block size")
+
+/* PDP_index_table maps block positions to the tag indices they were tagged under.  On disk it is
+ * "PDPI" | version u32 | next u64 | count u64 followed by count tag indices, all big-endian.
+ * It is safe for concurrent use, so an auditor can verify while the owner changes the file.
+ */
+type PDP_index_table struct {
+	mutex sync.RWMutex
+
+	/* The next unused tag index */
+	next    uint64
+	indices []uint64
+}
+
+/* new_pdp_index_table: Returns the identity table of a file tagged with pdp_tag_file. */
+func new_pdp_index_table(numfileblocks uint64) *PDP_index_table {
+	var table = &PDP_index_table{next: numfileblocks, indices: make([]uint64, numfileblocks)}
+
+	for i := range table.indices {
+		table.indices[i] = uint64(i)
+	}
+
+	return table
+}
+
+/* LoadPDPIndexTable: Reads the index table at path. */
+func LoadPDPIndexTable(path string) (*PDP_index_table, error) {
+	var table = &PDP_index_table{}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < PDP_INDEX_TABLE_HEADER_SIZE || string(data[0:4]) != PDP_INDEX_TABLE_MAGIC ||
+		binary.BigEndian.Uint32(data[4:8]) != PDP_INDEX_TABLE_VERSION {
+		return nil, ErrBadIndexTable
+	}
+	table.next = binary.BigEndian.Uint64(data[8:16])
+	count := binary.BigEndian.Uint64(data[16:24])
+	if count != uint64(len(data)-PDP_INDEX_TABLE_HEADER_SIZE)/8 || (len(data)-PDP_INDEX_TABLE_HEADER_SIZE)%8 != 0 {
+		return nil, ErrBadIndexTable
+	}
+
+	table.indices = make([]uint64, count)
+	for i := range table.indices {
+		offset := PDP_INDEX_TABLE_HEADER_SIZE + 8*i
+		table.indices[i] = binary.BigEndian.Uint64(data[offset : offset+8])
+		if table.indices[i] >= table.next {
+			return nil, ErrBadIndexTable
+		}
+	}
+
+	return table, nil
+}
+
+/* Save writes the table to path, replacing it only once the new copy is on disk. */
+func (table *PDP_index_table) Save(path string) error {
+	table.mutex.RLock()
+	var data = make([]byte, PDP_INDEX_TABLE_HEADER_SIZE+8*len(table.indices))
+	copy(data[0:4], PDP_INDEX_TABLE_MAGIC)
+	binary.BigEndian.PutUint32(data[4:8], PDP_INDEX_TABLE_VERSION)
+	binary.BigEndian.PutUint64(data[8:16], table.next)
+	binary.BigEndian.PutUint64(data[16:24], uint64(len(table.indices)))
+	for i, index := range table.indices {
+		offset := PDP_INDEX_TABLE_HEADER_SIZE + 8*i
+		binary.BigEndian.PutUint64(data[offset:offset+8], index)
+	}
+	table.mutex.RUnlock()
+
+	temp := path + ".tmp"
+	file, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	return os.Rename(temp, path)
+}
+
+/* NumBlocks returns the number of blocks in the file the table describes. */
+func (table *PDP_index_table) NumBlocks() uint64 {
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	return uint64(len(table.indices))
+}
+
+/* tag_index: Returns the tag index of the block at position. */
+func (table *PDP_index_table) tag_index(position uint) (uint, bool) {
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	if uint64(position) >= uint64(len(table.indices)) {
+		return 0, false
+	}
+	return uint(table.indices[position]), true
+}
+
+/* pdp_verify_proof_table: pdp_verify_proof for a file changed with the dynamic block operations, whose
+ * blocks are tagged under the indices in table.  The challenge must cover the table's block count.
+ * Returns a 1 if verified, 0 otherwise.
+ */
+func (pdpCore *PDPCore) pdp_verify_proof_table(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof,
+	table *PDP_index_table) int {
+
+	if table == nil || challenge == nil || uint64(challenge.numfileblocks) != table.NumBlocks() {
+		return 0
+	}
+
+	return pdpCore.verify_proof(key, challenge, proof, table.tag_index)
+}
+
+/* Block operations, for the dynamic schemes */
+const (
+	PDP_BLOCK_MODIFY = 1
+	PDP_BLOCK_INSERT = 2
+	PDP_BLOCK_DELETE = 3
+)
+
+/* pdp_dynamic_file holds the open files of a dynamic block operation. */
+type pdp_dynamic_file struct {
+	file    *os.File
+	tagfile *os.File
+	header  *PDP_tag_header
+	size    int64
+}
+
+/* open_dynamic_file: Opens a tagged file and its tag file, filepath + ".tag" by default, for a block
+ * operation.  key, if not nil, must match the tag file's record size.
+ */
+func open_dynamic_file(key *PDP_key, filepath string, tagFilepath string) (*pdp_dynamic_file, error) {
+	var dynamic = &pdp_dynamic_file{}
+	var err error
+
+	if tagFilepath == "" {
+		tagFilepath = filepath + PDP_TAG_FILE_SUFFIX
+	}
+
+	dynamic.file, err = os.OpenFile(filepath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	dynamic.tagfile, err = os.OpenFile(tagFilepath, os.O_RDWR, 0)
+	if err != nil {
+		dynamic.close()
+		return nil, err
+	}
+
+	dynamic.header, err = read_pdp_tag_header(dynamic.tagfile)
+	if err == nil && (dynamic.header.block_size != PDP_BLOCKSIZE || dynamic.header.tagged != dynamic.header.numfileblocks ||
+		(key != nil && !tag_header_matches(dynamic.header, key))) {
+		err = ErrBadTagFile
+	}
+	if err == nil {
+		var info os.FileInfo
+		if info, err = dynamic.file.Stat(); err == nil {
+			dynamic.size = info.Size()
+		}
+	}
+	if err != nil {
+		dynamic.close()
+		return nil, err
+	}
+
+	return dynamic, nil
+}
+
+func (dynamic *pdp_dynamic_file) close() {
+	if dynamic.file != nil {
+		dynamic.file.Close()
+	}
+	if dynamic.tagfile != nil {
+		dynamic.tagfile.Close()
+	}
+}
+
+/* check_update: Checks that op may be applied at position with block.  Only the last block may be short,
+ * so a modified or inserted block must be full unless it is or becomes the last one, and a block may only
+ * be added after the last one if that is full.
+ */
+func (dynamic *pdp_dynamic_file) check_update(op int, position uint64, block []byte) error {
+	var numfileblocks = dynamic.header.numfileblocks
+
+	switch op {
+	case PDP_BLOCK_MODIFY:
+		if position >= numfileblocks {
+			return ErrBadPosition
+		}
+		if len(block) == 0 || len(block) > PDP_BLOCKSIZE || (position < numfileblocks-1 && len(block) != PDP_BLOCKSIZE) {
+			return ErrBadBlockSize
+		}
+	case PDP_BLOCK_INSERT:
+		if position > numfileblocks {
+			return ErrBadPosition
+		}
+		if len(block) == 0 || len(block) > PDP_BLOCKSIZE || (position < numfileblocks && len(block) != PDP_BLOCKSIZE) ||
+			(position == numfileblocks && dynamic.size != int64(numfileblocks)*PDP_BLOCKSIZE) {
+			return ErrBadBlockSize
+		}
+	case PDP_BLOCK_DELETE:
+		if position >= numfileblocks {
+			return ErrBadPosition
+		}
+	default:
+		return fmt.Errorf("pdp: unknown block operation %d", op)
+	}
+
+	return nil
+}
+
+/* apply_update: Applies a checked operation to the data and tag files, writing tag as the record of a
+ * modified or inserted block.  The header is left to commit.
+ */
+func (dynamic *pdp_dynamic_file) apply_update(op int, position uint64, block []byte, tag *PDP_tag) error {
+	var header = dynamic.header
+	var offset = int64(position) * PDP_BLOCKSIZE
+	var err error
+
+	switch op {
+	case PDP_BLOCK_MODIFY:
+		if _, err = dynamic.file.WriteAt(block, offset); err != nil {
+			return err
+		}
+		if position == header.numfileblocks-1 {
+			if err = dynamic.file.Truncate(offset + int64(len(block))); err != nil {
+				return err
+			}
+		}
+		return write_pdp_tag_at(dynamic.tagfile, header, uint(position), tag)
+
+	case PDP_BLOCK_INSERT:
+		if err = shift_file(dynamic.file, offset, PDP_BLOCKSIZE); err != nil {
+			return err
+		}
+		if _, err = dynamic.file.WriteAt(block, offset); err != nil {
+			return err
+		}
+		if err = shift_file(dynamic.tagfile, tag_offset(header, uint(position)), int64(header.tag_size)); err != nil {
+			return err
+		}
+		return write_pdp_tag_at(dynamic.tagfile, header, uint(position), tag)
+
+	case PDP_BLOCK_DELETE:
+		if position == header.numfileblocks-1 {
+			err = dynamic.file.Truncate(offset)
+		} else {
+			err = shift_file(dynamic.file, offset+PDP_BLOCKSIZE, -PDP_BLOCKSIZE)
+		}
+		if err != nil {
+			return err
+		}
+		return shift_file(dynamic.tagfile, tag_offset(header, uint(position+1)), -int64(header.tag_size))
+	}
+
+	return fmt.Errorf("pdp: unknown block operation %d", op)
+}
+
+/* commit: Syncs the data and records the new block count in the tag file header. */
+func (dynamic *pdp_dynamic_file) commit(numfileblocks uint64) error {
+
+	if err := dynamic.file.Sync(); err != nil {
+		return err
+	}
+	dynamic.header.numfileblocks = numfileblocks
+
+	return checkpoint_pdp_tag_file(dynamic.tagfile, dynamic.header, numfileblocks)
+}
+
+/* open_index_table: Loads the index table at tablePath, filepath + ".idx" by default.  A missing table
+ * is the identity table of a file not yet changed, and is saved before the first change so that one cut
+ * short still leaves a table to refuse the new block count.
+ */
+func open_index_table(filepath string, tablePath string, numfileblocks uint64) (*PDP_index_table, string, error) {
+
+	if tablePath == "" {
+		tablePath = filepath + PDP_INDEX_TABLE_SUFFIX
+	}
+	table, err := LoadPDPIndexTable(tablePath)
+	if os.IsNotExist(err) {
+		table = new_pdp_index_table(numfileblocks)
+		err = table.Save(tablePath)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if table.NumBlocks() != numfileblocks {
+		return nil, "", ErrBadIndexTable
+	}
+
+	return table, tablePath, nil
+}
+
+/* tag_dynamic_block: Tags block under index.  Returns the tag or nil on failure. */
+func (pdpCore *PDPCore) tag_dynamic_block(key *PDP_key, block []byte, index uint64) *PDP_tag {
+	var data = string(block)
+	var blocksize = uint64(len(block))
+
+	return pdpCore.pdp_tag_block(key, &data, &blocksize, uint(index))
+}
+
+/* update_block: Applies op to a file and its index table, tagging a new block under the table's next
+ * tag index.
+ */
+func (pdpCore *PDPCore) update_block(key *PDP_key, filepath string, tagFilepath string, tablePath string,
+	op int, position uint64, block []byte) error {
+
+	var tag *PDP_tag
+
+	dynamic, err := open_dynamic_file(key, filepath, tagFilepath)
+	if err != nil {
+		return err
+	}
+	defer dynamic.close()
+	if err = dynamic.check_update(op, position, block); err != nil {
+		return err
+	}
+	table, tablePath, err := open_index_table(filepath, tablePath, dynamic.header.numfileblocks)
+	if err != nil {
+		return err
+	}
+	key = tag_file_key(key, dynamic.header)
+
+	index := table.next
+	if op != PDP_BLOCK_DELETE {
+		if tag = pdpCore.tag_dynamic_block(key, block, index); tag == nil {
+			return errors.New("pdp: could not tag block")
+		}
+	}
+	if err = dynamic.apply_update(op, position, block, tag); err != nil {
+		return err
+	}
+
+	table.mutex.Lock()
+	switch op {
+	case PDP_BLOCK_MODIFY:
+		table.indices[position] = index
+		table.next++
+	case PDP_BLOCK_INSERT:
+		table.indices = append(table.indices, 0)
+		copy(table.indices[position+1:], table.indices[position:])
+		table.indices[position] = index
+		table.next++
+	case PDP_BLOCK_DELETE:
+		table.indices = append(table.indices[:position], table.indices[position+1:]...)
+	}
+	table.mutex.Unlock()
+
+	if err = dynamic.commit(table.NumBlocks()); err != nil {
+		return err
+	}
+
+	return table.Save(tablePath)
+}
+
+/* pdp_modify_block: Client-side function that replaces the block at position with block and tags it
+ * under a fresh tag index.  block must be a full block unless it replaces the last one.
+ * Returns nil on success.
+ */
+func (pdpCore *PDPCore) pdp_modify_block(key *PDP_key, filepath string, tagFilepath string, tablePath string,
+	position uint64, block []byte) error {
+	return pdpCore.update_block(key, filepath, tagFilepath, tablePath, PDP_BLOCK_MODIFY, position, block)
+}
+
+/* pdp_insert_block: Client-side function that inserts block before the block at position, or after the
+ * last block if position is the block count, and tags it under a fresh tag index.  block must be a full
+ * block unless it becomes the last one, and then the block before it must be full.
+ * Returns nil on success.
+ */
+func (pdpCore *PDPCore) pdp_insert_block(key *PDP_key, filepath string, tagFilepath string, tablePath string,
+	position uint64, block []byte) error {
+	return pdpCore.update_block(key, filepath, tagFilepath, tablePath, PDP_BLOCK_INSERT, position, block)
+}
+
+/* pdp_delete_block: Client-side function that removes the block at position and its tag.  Only the
+ * tag file and table change besides the data, so no key is needed.
+ * Returns nil on success.
+ */
+func (pdpCore *PDPCore) pdp_delete_block(filepath string, tagFilepath string, tablePath string, position uint64) error {
+	return pdpCore.update_block(nil, filepath, tagFilepath, tablePath, PDP_BLOCK_DELETE, position, nil)
+}
+
+/* shift_file: Moves the bytes of file from offset from to its end by delta bytes.  Moving up copies from
+ * the end down so nothing is overwritten before it is read; moving down copies from the start up and then
+ * truncates the file.
+ */
+func shift_file(file *os.File, from int64, delta int64) error {
+	var buf = make([]byte, PDP_SHIFT_CHUNK)
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if from > size {
+		from = size
+	}
+
+	if delta > 0 {
+		for end := size; end > from; {
+			start := end - PDP_SHIFT_CHUNK
+			if start < from {
+				start = from
+			}
+			chunk := buf[:end-start]
+			if _, err = file.ReadAt(chunk, start); err != nil && err != io.EOF {
+				return err
+			}
+			if _, err = file.WriteAt(chunk, start+delta); err != nil {
+				return err
+			}
+			end = start
+		}
+		return nil
+	}
+
+	for start := from; start < size; start += PDP_SHIFT_CHUNK {
+		end := start + PDP_SHIFT_CHUNK
+		if end > size {
+			end = size
+		}
+		chunk := buf[:end-start]
+		if _, err = file.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return err
+		}
+		if _, err = file.WriteAt(chunk, start+delta); err != nil {
+			return err
+		}
+	}
+
+	return file.Truncate(size + delta)
+}
+
+/* ModifyBlock: Exported form of pdp_modify_block for the command line tool. */
+func (pdpCore *PDPCore) ModifyBlock(key *PDP_key, filepath string, tagFilepath string, tablePath string,
+	position uint64, block []byte) error {
+	return pdpCore.pdp_modify_block(key, filepath, tagFilepath, tablePath, position, block)
+}
+
+/* InsertBlock: Exported form of pdp_insert_block for the command line tool. */
+func (pdpCore *PDPCore) InsertBlock(key *PDP_key, filepath string, tagFilepath string, tablePath string,
+	position uint64, block []byte) error {
+	return pdpCore.pdp_insert_block(key, filepath, tagFilepath, tablePath, position, block)
+}
+
+/* DeleteBlock: Exported form of pdp_delete_block for the command line tool. */
+func (pdpCore *PDPCore) DeleteBlock(filepath string, tagFilepath string, tablePath string, position uint64) error {
+	return pdpCore.pdp_delete_block(filepath, tagFilepath, tablePath, position)
+}
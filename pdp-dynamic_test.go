@@ -0,0 +1,200 @@
+package gopdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+/* dynamic_test_blocks: Splits data into its blocks, the last one short if data is not a whole number of them. */
+func dynamic_test_blocks(data []byte) [][]byte {
+	var blocks [][]byte
+
+	for i := 0; i*PDP_BLOCKSIZE < len(data); i++ {
+		blocks = append(blocks, append([]byte(nil), test_block(data, i)...))
+	}
+
+	return blocks
+}
+
+/* prove_test_table: Challenges every block of the file at path, proved from the data and tag files given,
+ * and verifies the proof under the file's index table.  Returns pdp_verify_proof_table's result.
+ */
+func prove_test_table(t *testing.T, key *PDP_key, path string, datapath string, tagpath string) int {
+	t.Helper()
+
+	var core = NewPDPCore()
+
+	table, err := LoadPDPIndexTable(path + PDP_INDEX_TABLE_SUFFIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := core.pdp_challenge(key, uint(table.NumBlocks()))
+	proof, err := core.pdp_prove_file(context.Background(), datapath, tagpath, core.sanitize_pdp_challenge(challenge),
+		public_test_key(key), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return core.pdp_verify_proof_table(key, challenge, proof, table)
+}
+
+/* check_test_dynamic: Fails the test unless the file at path holds blocks and a proof of all of them verifies. */
+func check_test_dynamic(t *testing.T, key *PDP_key, path string, blocks [][]byte) {
+	t.Helper()
+
+	data, _ := ioutil.ReadFile(path)
+	if !bytes.Equal(data, bytes.Join(blocks, nil)) {
+		t.Fatalf("file holds %d bytes, not the %d blocks expected", len(data), len(blocks))
+	}
+	if prove_test_table(t, key, path, path, "") != 1 {
+		t.Fatal("proof did not verify")
+	}
+}
+
+func TestDynamicBlocks(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	path, data := tag_test_file(t, key, 5*PDP_BLOCKSIZE+100)
+	blocks := dynamic_test_blocks(data)
+	full := func() []byte {
+		block, _ := GenerateRandomBytes(PDP_BLOCKSIZE)
+		return block
+	}
+
+	/* Modify: the server's copy of the replaced block and its tag no longer verify */
+	stale, _ := ioutil.ReadFile(path)
+	stale_tags, _ := ioutil.ReadFile(path + PDP_TAG_FILE_SUFFIX)
+	ioutil.WriteFile(path+".stale", stale, 0644)
+	ioutil.WriteFile(path+".stale"+PDP_TAG_FILE_SUFFIX, stale_tags, 0644)
+	blocks[2] = full()
+	if err := core.pdp_modify_block(key, path, "", "", 2, blocks[2]); err != nil {
+		t.Fatal(err)
+	}
+	check_test_dynamic(t, key, path, blocks)
+	if prove_test_table(t, key, path, path+".stale", "") == 1 {
+		t.Error("proof from the replaced block verified")
+	}
+	if prove_test_table(t, key, path, path+".stale", path+".stale"+PDP_TAG_FILE_SUFFIX) == 1 {
+		t.Error("proof from the replaced block and its tag verified")
+	}
+	if table, _ := LoadPDPIndexTable(path + PDP_INDEX_TABLE_SUFFIX); table.indices[2] != 6 || table.next != 7 {
+		t.Fatalf("modified block has tag index %d, next %d", table.indices[2], table.next)
+	}
+
+	/* Insert at the front */
+	blocks = append([][]byte{full()}, blocks...)
+	if err := core.pdp_insert_block(key, path, "", "", 0, blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+	check_test_dynamic(t, key, path, blocks)
+
+	/* Insert after the last block, once that is full */
+	if err := core.pdp_insert_block(key, path, "", "", uint64(len(blocks)), full()); !errors.Is(err, ErrBadBlockSize) {
+		t.Fatalf("insert after a short last block: %v", err)
+	}
+	blocks[len(blocks)-1] = full()
+	if err := core.pdp_modify_block(key, path, "", "", uint64(len(blocks)-1), blocks[len(blocks)-1]); err != nil {
+		t.Fatal(err)
+	}
+	blocks = append(blocks, []byte("a new short last block"))
+	if err := core.pdp_insert_block(key, path, "", "", uint64(len(blocks)-1), blocks[len(blocks)-1]); err != nil {
+		t.Fatal(err)
+	}
+	check_test_dynamic(t, key, path, blocks)
+	if err := core.pdp_insert_block(key, path, "", "", uint64(len(blocks)+1), full()); !errors.Is(err, ErrBadPosition) {
+		t.Fatalf("insert past the end: %v", err)
+	}
+
+	/* Delete the last block and then one in the middle */
+	if err := core.pdp_delete_block(path, "", "", uint64(len(blocks)-1)); err != nil {
+		t.Fatal(err)
+	}
+	blocks = blocks[:len(blocks)-1]
+	check_test_dynamic(t, key, path, blocks)
+	if err := core.pdp_delete_block(path, "", "", 3); err != nil {
+		t.Fatal(err)
+	}
+	blocks = append(blocks[:3], blocks[4:]...)
+	check_test_dynamic(t, key, path, blocks)
+	if err := core.pdp_delete_block(path, "", "", uint64(len(blocks))); !errors.Is(err, ErrBadPosition) {
+		t.Fatalf("delete past the end: %v", err)
+	}
+	if table, _ := LoadPDPIndexTable(path + PDP_INDEX_TABLE_SUFFIX); table.NumBlocks() != 6 || table.next != 10 {
+		t.Fatalf("table of %d blocks, next %d", table.NumBlocks(), table.next)
+	}
+}
+
+/* crash_test_update: Applies op to the file at path and commits its tag file, but does not save the
+ * index table, as if the process died just before it could.
+ */
+func crash_test_update(t *testing.T, key *PDP_key, path string, op int, position uint64, block []byte) {
+	t.Helper()
+
+	var core = NewPDPCore()
+	var tag *PDP_tag
+
+	dynamic, err := open_dynamic_file(key, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dynamic.close()
+	if err = dynamic.check_update(op, position, block); err != nil {
+		t.Fatal(err)
+	}
+	table, _, err := open_index_table(path, "", dynamic.header.numfileblocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != PDP_BLOCK_DELETE {
+		tag = core.tag_dynamic_block(key, block, table.next)
+	}
+	if err = dynamic.apply_update(op, position, block, tag); err != nil {
+		t.Fatal(err)
+	}
+	numfileblocks := dynamic.header.numfileblocks
+	switch op {
+	case PDP_BLOCK_INSERT:
+		numfileblocks++
+	case PDP_BLOCK_DELETE:
+		numfileblocks--
+	}
+	if err = dynamic.commit(numfileblocks); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDynamicBlockCrash(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	path, data := tag_test_file(t, key, 4*PDP_BLOCKSIZE)
+	blocks := dynamic_test_blocks(data)
+	block, _ := GenerateRandomBytes(PDP_BLOCKSIZE)
+
+	/* A modify is repeated from the unchanged table under the same tag index, which finishes it */
+	blocks[1] = block
+	crash_test_update(t, key, path, PDP_BLOCK_MODIFY, 1, block)
+	if err := core.pdp_modify_block(key, path, "", "", 1, block); err != nil {
+		t.Fatal(err)
+	}
+	check_test_dynamic(t, key, path, blocks)
+
+	/* An insert or delete cannot be repeated, and the stale table is refused rather than misused */
+	crash_test_update(t, key, path, PDP_BLOCK_INSERT, 0, block)
+	if err := core.pdp_insert_block(key, path, "", "", 0, block); !errors.Is(err, ErrBadIndexTable) {
+		t.Fatalf("insert after an interrupted insert: %v", err)
+	}
+	if err := core.pdp_delete_block(path, "", "", 0); !errors.Is(err, ErrBadIndexTable) {
+		t.Fatalf("delete after an interrupted insert: %v", err)
+	}
+
+	path, _ = tag_test_file(t, key, 4*PDP_BLOCKSIZE)
+	crash_test_update(t, key, path, PDP_BLOCK_DELETE, 2, nil)
+	if err := core.pdp_delete_block(path, "", "", 2); !errors.Is(err, ErrBadIndexTable) {
+		t.Fatalf("delete after an interrupted delete: %v", err)
+	}
+}
@@ -0,0 +1,1315 @@
+package gopdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	PDP_TAG_FILE_MAGIC     = "PDPT"
+	PDP_TAG_FILE_VERSION   = 3
+	PDP_TAG_HEADER_SIZE    = 56
+	PDP_TAG_FILE_SUFFIX    = ".tag"
+	PDP_TAG_FILE_NAME_SIZE = 16
+
+	/* Version 1 tag files have no scheme field and are always RSA; version 2 files have no name */
+	PDP_TAG_FILE_VERSION_1 = 1
+	PDP_TAG_HEADER_SIZE_1  = 32
+	PDP_TAG_FILE_VERSION_2 = 2
+	PDP_TAG_HEADER_SIZE_2  = 40
+
+	/* How many tagged blocks may wait for an earlier block before the dispatcher stalls, per worker */
+	PDP_TAG_WINDOW_PER_WORKER = 4
+
+	/* Checkpoint the tag file header every 4096 blocks (16MB of data at the default block size), and
+	 * whenever tagging stops early */
+	PDP_TAG_CHECKPOINT_BLOCKS = 4096
+)
+
+/* The tag file starts with a fixed-size header followed by one fixed-size record per block,
+ * so the tag of block i lives at PDP_TAG_HEADER_SIZE + i * tag_size.
+ *
+ *   magic[4] | version u32 | block_size u32 | tag_size u32 | numfileblocks u64 | tagged u64 |
+ *   scheme u32 | reserved u32 | name[16]
+ *
+ * A record is T_im as a big-endian number left-padded to tag_size (the modulus size in bytes), or the
+ * tag of the scheme the file was tagged under, PDP_SCHEME_RSA, PDP_SCHEME_BLS or PDP_SCHEME_MAC.
+ * tagged is the checkpoint: records [0, tagged) are known to be written and synced.  A tag file is
+ * complete when tagged == numfileblocks.  name is the random name a BLS or MAC file was tagged
+ * with, which keys its block hashes or PRF so its tags answer for no other file under the same key; it
+ * is zero for RSA.
+ *
+ * A version 1 header stops after tagged, PDP_TAG_HEADER_SIZE_1 bytes, and its file is RSA; a version 2
+ * header stops after reserved, PDP_TAG_HEADER_SIZE_2 bytes, and has no name.  Such RSA files are still
+ * read, appended to and proved, and keep their version when their header is rewritten.  A version 2 BLS or
+ * MAC file was tagged without a name and is refused; it is tagged again.
+ */
+type PDP_tag_header struct {
+	version       uint32
+	scheme        uint32
+	block_size    uint32
+	tag_size      uint32
+	numfileblocks uint64
+	tagged        uint64
+	name          []byte
+}
+
+/* PDP_tag_progress is handed to PDP_tag_options.Progress as blocks are written to the tag file. */
+type PDP_tag_progress struct {
+	Blocks      uint64
+	TotalBlocks uint64
+	Bytes       uint64
+	BytesPerSec float64
+}
+
+/* PDP_tag_options controls pdp_tag_file.  Workers defaults to 1 and Progress may be nil.
+ * With Resume set, an existing tag file for the same data is continued from its checkpoint
+ * instead of being truncated.
+ */
+type PDP_tag_options struct {
+	Workers  int
+	Resume   bool
+	Progress func(progress PDP_tag_progress)
+}
+
+var ErrBadTagFile = errors.New("pdp: malformed tag file")
+var ErrFileShrunk = errors.New("pdp: file is shorter than its tag file")
+var ErrDynamicFile = errors.New("pdp: file has an index table; extend it with InsertBlock, not by appending")
+
+type pdp_tag_result struct {
+	index uint
+	size  uint64
+	tag   *PDP_tag
+	err   error
+}
+
+/* pdp_tag_file: Client-side function that tags every block of the file at filepath and writes the tags,
+ * in index order, to tagFilepath (filepath + ".tag" if empty).  Blocks are read and tagged by
+ * opts.Workers goroutines; the calling goroutine reorders their results and writes the tag file.
+ * The header is checkpointed as tagging progresses so an interrupted run can be resumed with opts.Resume.
+ * A BLS or MAC file is tagged under key's name, see pdp_named_key, or a new random one, recorded in
+ * the header; pdp_tag_file_name reads it back for the verifier.
+ * Returns nil on success, ctx.Err() if the context is cancelled or the first error encountered.
+ */
+func (pdpCore *PDPCore) pdp_tag_file(ctx context.Context, key *PDP_key, filepath string, tagFilepath string, opts *PDP_tag_options) error {
+	var file *os.File
+	var tagfile *os.File
+	var header *PDP_tag_header
+	var numfileblocks uint64
+	var first uint64 = 0
+	var workers int = 1
+	var err error
+
+	if !valid_pdp_key(key) {
+		return errors.New("pdp: invalid key")
+	}
+	if opts != nil && opts.Workers > 0 {
+		workers = opts.Workers
+	}
+	if tagFilepath == "" {
+		tagFilepath = filepath + PDP_TAG_FILE_SUFFIX
+	}
+
+	file, err = os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	numfileblocks = (uint64(info.Size()) + PDP_BLOCKSIZE - 1) / PDP_BLOCKSIZE
+
+	if opts != nil && opts.Resume {
+		tagfile, header, first = pdpCore.resume_tag_file(key, file, tagFilepath, numfileblocks)
+	}
+	if tagfile == nil {
+		tagfile, err = os.OpenFile(tagFilepath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		header = &PDP_tag_header{
+			version:       PDP_TAG_FILE_VERSION,
+			scheme:        pdp_key_scheme(key),
+			block_size:    PDP_BLOCKSIZE,
+			tag_size:      uint32(modulus_size(key)),
+			numfileblocks: numfileblocks,
+			name:          key.name,
+		}
+		if scheme_names_files(header.scheme) && header.name == nil {
+			header.name, err = GenerateRandomBytes(PDP_TAG_FILE_NAME_SIZE)
+		}
+		if err == nil {
+			err = write_pdp_tag_header(tagfile, header)
+		}
+		if err != nil {
+			tagfile.Close()
+			return err
+		}
+	}
+	defer tagfile.Close()
+	key = tag_file_key(key, header)
+
+	if err = pdpCore.tag_blocks(ctx, key, file, tagfile, header, first, workers, true, opts); err != nil {
+		return err
+	}
+
+	return checkpoint_pdp_tag_file(tagfile, header, header.numfileblocks)
+}
+
+/* pdp_append_file: Client-side function that extends the tag file of a file that has grown since it was
+ * tagged.  If data is not nil it is first appended to the file; otherwise the file is expected to have
+ * grown already, e.g. a log written by another process.  Only the new blocks are tagged, plus the old
+ * last block if it was partial and the append changed it.  The new records are synced before the
+ * header's block count is rewritten, so a prover sees either the old tag file or the extended one, and an
+ * interrupted append is finished by running it again with a nil data.  Files changed with the dynamic
+ * block operations are tagged by tag index rather than position and are extended with pdp_insert_block:
+ * if the file has an index table, <file>.idx, nothing is appended and ErrDynamicFile is returned.
+ * Returns the new block count, or ErrBadTagFile if the tag file is incomplete or for another key.
+ */
+func (pdpCore *PDPCore) pdp_append_file(ctx context.Context, key *PDP_key, filepath string, tagFilepath string,
+	data io.Reader, opts *PDP_tag_options) (uint64, error) {
+
+	var file *os.File
+	var tagfile *os.File
+	var header *PDP_tag_header
+	var extended PDP_tag_header
+	var numfileblocks uint64
+	var first uint64
+	var workers int = 1
+	var err error
+
+	if !valid_pdp_key(key) {
+		return 0, errors.New("pdp: invalid key")
+	}
+	if opts != nil && opts.Workers > 0 {
+		workers = opts.Workers
+	}
+	if tagFilepath == "" {
+		tagFilepath = filepath + PDP_TAG_FILE_SUFFIX
+	}
+	if _, err = os.Stat(filepath + PDP_INDEX_TABLE_SUFFIX); err == nil {
+		return 0, fmt.Errorf("%w: %s", ErrDynamicFile, filepath+PDP_INDEX_TABLE_SUFFIX)
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	file, err = os.OpenFile(filepath, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	tagfile, err = os.OpenFile(tagFilepath, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer tagfile.Close()
+
+	header, err = read_pdp_tag_header(tagfile)
+	if err != nil {
+		return 0, err
+	}
+	if header.block_size != PDP_BLOCKSIZE || !tag_header_matches(header, key) || header.tagged != header.numfileblocks {
+		return 0, ErrBadTagFile
+	}
+	key = tag_file_key(key, header)
+
+	if data != nil {
+		if _, err = file.Seek(0, io.SeekEnd); err != nil {
+			return 0, err
+		}
+		if _, err = io.Copy(file, data); err != nil {
+			return 0, err
+		}
+		if err = file.Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	numfileblocks = (uint64(info.Size()) + PDP_BLOCKSIZE - 1) / PDP_BLOCKSIZE
+	if numfileblocks < header.numfileblocks {
+		return 0, ErrFileShrunk
+	}
+
+	/* A partial last block has grown if its tag no longer matches; it is tagged again with the new blocks */
+	first = header.numfileblocks
+	if first > 0 {
+		tag := pdpCore.read_pdp_tag_at(tagfile, header, uint(first-1))
+		if tag == nil || pdpCore.check_pdp_tag(key, file, make([]byte, header.block_size), tag) == 0 {
+			first--
+		}
+	}
+	if first == numfileblocks {
+		return numfileblocks, nil
+	}
+
+	/* Tag into the records past the old end without checkpointing, so the header keeps the old count */
+	extended = *header
+	extended.numfileblocks = numfileblocks
+	if err = pdpCore.tag_blocks(ctx, key, file, tagfile, &extended, first, workers, false, opts); err != nil {
+		return 0, err
+	}
+
+	if err = checkpoint_pdp_tag_file(tagfile, &extended, numfileblocks); err != nil {
+		return 0, err
+	}
+
+	return numfileblocks, nil
+}
+
+/* resume_tag_file: Opens an existing tag file for resuming.  The header must match the data file and key.
+ * The tags before the checkpoint were synced before it was written and are trusted; the tags written
+ * after it by a run that died before its next checkpoint are checked against the data, and tagging
+ * restarts at the first one that is missing or does not verify.
+ * Returns the open tag file, its header and the index to resume from, or a nil file if the tag file
+ * cannot be resumed and must be recreated.
+ */
+func (pdpCore *PDPCore) resume_tag_file(key *PDP_key, file io.ReaderAt, tagFilepath string, numfileblocks uint64) (*os.File, *PDP_tag_header, uint64) {
+	var first uint64
+	var block = make([]byte, PDP_BLOCKSIZE)
+
+	tagfile, err := os.OpenFile(tagFilepath, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, nil, 0
+	}
+	header, err := read_pdp_tag_header(tagfile)
+	if err != nil || header.block_size != PDP_BLOCKSIZE || header.numfileblocks != numfileblocks ||
+		!tag_header_matches(header, key) || header.tagged > numfileblocks {
+		tagfile.Close()
+		return nil, nil, 0
+	}
+	key = tag_file_key(key, header)
+
+	/* read_pdp_tag_at stops at the checkpoint; read the tail as if the whole file were tagged */
+	tail := *header
+	tail.tagged = header.numfileblocks
+	for first = header.tagged; first < header.numfileblocks; first++ {
+		tag := pdpCore.read_pdp_tag_at(tagfile, &tail, uint(first))
+		if tag == nil || pdpCore.check_pdp_tag(key, file, block, tag) == 0 {
+			break
+		}
+	}
+
+	/* Record the verified tail so a second interruption does not check it again */
+	if first != header.tagged {
+		if checkpoint_pdp_tag_file(tagfile, header, first) != nil {
+			tagfile.Close()
+			return nil, nil, 0
+		}
+	}
+
+	return tagfile, header, first
+}
+
+/* check_pdp_tag: Checks a stored tag against its data block using the public key,
+ * T_im^e == h(W_i) * g^m mod N.  Returns 1 if the tag matches, 0 otherwise.
+ */
+func (pdpCore *PDPCore) check_pdp_tag(key *PDP_key, file io.ReaderAt, block []byte, tag *PDP_tag) int {
+
+	n, err := file.ReadAt(block, int64(tag.index)*int64(len(block)))
+	if err != nil && err != io.EOF {
+		return 0
+	}
+
+	return pdpCore.check_pdp_tag_block(key, block[:n], tag)
+}
+
+/* check_pdp_tag_block: check_pdp_tag for a block already in memory. */
+func (pdpCore *PDPCore) check_pdp_tag_block(key *PDP_key, block []byte, tag *PDP_tag) int {
+	var index_prf_size uint64 = 0
+	var blocksize = uint64(len(block))
+	var data = string(block)
+
+	if key.bls != nil {
+		return bls_check_tag_block(key, block, tag)
+	}
+	if key.mac != nil {
+		return mac_check_tag_block(key, block, tag)
+	}
+
+	index_prf := pdpCore.generate_prf_w(key, tag.index, &index_prf_size)
+	if index_prf == nil {
+		return 0
+	}
+	fdh_hash := pdpCore.generate_fdh_h(key, index_prf, index_prf_size)
+	if fdh_hash == nil {
+		return 0
+	}
+
+	expected := ModMul(fdh_hash, generator_exp(key, block_to_bn(&data, blocksize)), key.rsa.N)
+	actual := crt_exp(key, tag.Tim, pdp_key_exponent(key))
+	if expected.Cmp(actual) != 0 {
+		return 0
+	}
+
+	return 1
+}
+
+/* checkpoint_pdp_tag_file: Syncs the tag records and then records tagged in the header, so the header
+ * never claims more tags than are on disk.
+ */
+func checkpoint_pdp_tag_file(tagfile *os.File, header *PDP_tag_header, tagged uint64) error {
+
+	if err := tagfile.Sync(); err != nil {
+		return err
+	}
+	header.tagged = tagged
+	if err := write_pdp_tag_header(tagfile, header); err != nil {
+		return err
+	}
+
+	return tagfile.Sync()
+}
+
+/* tag_blocks: Tags blocks [first, header.numfileblocks) of file on a pool of workers and writes each tag
+ * to its record in tagfile in index order.  With checkpoint set, the header's tagged count is updated
+ * every PDP_TAG_CHECKPOINT_BLOCKS blocks and, if tagging stops early because ctx was cancelled or a block
+ * failed, once more for the tags written so far; otherwise the header on disk is left alone.
+ */
+func (pdpCore *PDPCore) tag_blocks(ctx context.Context, key *PDP_key, file io.ReaderAt, tagfile *os.File,
+	header *PDP_tag_header, first uint64, workers int, checkpoint bool, opts *PDP_tag_options) error {
+
+	var wg sync.WaitGroup
+	var indices = make(chan uint)
+	var results = make(chan pdp_tag_result, workers)
+	var window = make(chan struct{}, workers*PDP_TAG_WINDOW_PER_WORKER)
+	var pending = make(map[uint]pdp_tag_result)
+	var next = first
+	var bytes uint64 = 0
+	var start = time.Now()
+	var err error
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	/* Dispatch indices in order; the window bounds how far ahead of the writer the workers can get */
+	go func() {
+		defer close(indices)
+		for i := first; i < header.numfileblocks; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case indices <- uint(i):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var block = make([]byte, header.block_size)
+			for index := range indices {
+				result := pdpCore.tag_one_block(key, file, block, index)
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		if err != nil {
+			continue
+		}
+		if result.err != nil {
+			err = result.err
+			cancel()
+			continue
+		}
+		pending[result.index] = result
+
+		/* Write out every tag that is now contiguous with what's already written */
+		for {
+			r, ok := pending[uint(next)]
+			if !ok {
+				break
+			}
+			delete(pending, uint(next))
+			if err = write_pdp_tag(tagfile, header, r.tag); err != nil {
+				cancel()
+				break
+			}
+			<-window
+			next++
+			bytes += r.size
+			pdp_tag_blocks.WithLabelValues(pdp_scheme(key)).Inc()
+			pdp_tag_bytes.WithLabelValues(pdp_scheme(key)).Add(float64(r.size))
+
+			if checkpoint && next%PDP_TAG_CHECKPOINT_BLOCKS == 0 {
+				if err = checkpoint_pdp_tag_file(tagfile, header, next); err != nil {
+					cancel()
+					break
+				}
+			}
+
+			if opts != nil && opts.Progress != nil {
+				elapsed := time.Since(start).Seconds()
+				progress := PDP_tag_progress{
+					Blocks:      next,
+					TotalBlocks: header.numfileblocks,
+					Bytes:       bytes,
+				}
+				if elapsed > 0 {
+					progress.BytesPerSec = float64(bytes) / elapsed
+				}
+				opts.Progress(progress)
+			}
+		}
+	}
+
+	/* Keep the progress of an interrupted run; a checkpoint that fails leaves the older one */
+	if checkpoint && next != header.numfileblocks && next > header.tagged {
+		checkpoint_pdp_tag_file(tagfile, header, next)
+	}
+
+	if err != nil {
+		return err
+	}
+	if next != header.numfileblocks {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("pdp: tagged %d of %d blocks", next, header.numfileblocks)
+	}
+
+	return nil
+}
+
+/* tag_one_block: Reads block index from file into block and tags it. */
+func (pdpCore *PDPCore) tag_one_block(key *PDP_key, file io.ReaderAt, block []byte, index uint) pdp_tag_result {
+	var blocksize uint64
+	var data string
+
+	n, err := file.ReadAt(block, int64(index)*int64(len(block)))
+	if err != nil && err != io.EOF {
+		return pdp_tag_result{index: index, err: err}
+	}
+	blocksize = uint64(n)
+	data = string(block[:n])
+
+	tag := pdpCore.pdp_tag_block(key, &data, &blocksize, index)
+	if tag == nil {
+		return pdp_tag_result{index: index, err: fmt.Errorf("pdp: could not tag block %d", index)}
+	}
+
+	return pdp_tag_result{index: index, size: blocksize, tag: tag}
+}
+
+/* write_pdp_tag_header: Writes the tag file header at the start of tagfile, in the header's version. */
+func write_pdp_tag_header(tagfile io.WriterAt, header *PDP_tag_header) error {
+	var buf [PDP_TAG_HEADER_SIZE]byte
+
+	if header.version == PDP_TAG_FILE_VERSION_1 && header.scheme != PDP_SCHEME_RSA {
+		return ErrBadTagFile
+	}
+	copy(buf[0:4], PDP_TAG_FILE_MAGIC)
+	binary.BigEndian.PutUint32(buf[4:8], header.version)
+	binary.BigEndian.PutUint32(buf[8:12], header.block_size)
+	binary.BigEndian.PutUint32(buf[12:16], header.tag_size)
+	binary.BigEndian.PutUint64(buf[16:24], header.numfileblocks)
+	binary.BigEndian.PutUint64(buf[24:32], header.tagged)
+	binary.BigEndian.PutUint32(buf[32:36], header.scheme)
+	if scheme_names_files(header.scheme) {
+		if header.version != PDP_TAG_FILE_VERSION || len(header.name) != PDP_TAG_FILE_NAME_SIZE {
+			return ErrBadTagFile
+		}
+		copy(buf[40:56], header.name)
+	}
+
+	_, err := tagfile.WriteAt(buf[:tag_header_size(header)], 0)
+	return err
+}
+
+/* read_pdp_tag_header: Reads and checks the tag file header, of any version.  Returns the header or
+ * an error.
+ */
+func read_pdp_tag_header(tagfile io.ReaderAt) (*PDP_tag_header, error) {
+	var buf [PDP_TAG_HEADER_SIZE]byte
+	var header PDP_tag_header
+
+	if _, err := tagfile.ReadAt(buf[:PDP_TAG_HEADER_SIZE_1], 0); err != nil {
+		return nil, err
+	}
+	if string(buf[0:4]) != PDP_TAG_FILE_MAGIC {
+		return nil, ErrBadTagFile
+	}
+	header.version = binary.BigEndian.Uint32(buf[4:8])
+	header.block_size = binary.BigEndian.Uint32(buf[8:12])
+	header.tag_size = binary.BigEndian.Uint32(buf[12:16])
+	header.numfileblocks = binary.BigEndian.Uint64(buf[16:24])
+	header.tagged = binary.BigEndian.Uint64(buf[24:32])
+	header.scheme = PDP_SCHEME_RSA
+	if header.version != PDP_TAG_FILE_VERSION && header.version != PDP_TAG_FILE_VERSION_2 &&
+		header.version != PDP_TAG_FILE_VERSION_1 {
+		return nil, ErrBadTagFile
+	}
+	if header.version != PDP_TAG_FILE_VERSION_1 {
+		if _, err := tagfile.ReadAt(buf[PDP_TAG_HEADER_SIZE_1:tag_header_size(&header)], PDP_TAG_HEADER_SIZE_1); err != nil {
+			return nil, err
+		}
+		header.scheme = binary.BigEndian.Uint32(buf[32:36])
+		if header.scheme > PDP_SCHEME_MAC || binary.BigEndian.Uint32(buf[36:40]) != 0 {
+			return nil, ErrBadTagFile
+		}
+	}
+
+	/* Only a version 3 BLS or MAC header has a name, and it has one; any other name must be zero */
+	name := buf[40:56]
+	if scheme_names_files(header.scheme) {
+		if header.version != PDP_TAG_FILE_VERSION || bytes.Equal(name, make([]byte, PDP_TAG_FILE_NAME_SIZE)) {
+			return nil, ErrBadTagFile
+		}
+		header.name = append([]byte{}, name...)
+	} else if !bytes.Equal(name, make([]byte, PDP_TAG_FILE_NAME_SIZE)) {
+		return nil, ErrBadTagFile
+	}
+	if header.block_size == 0 || header.tag_size == 0 {
+		return nil, ErrBadTagFile
+	}
+
+	return &header, nil
+}
+
+/* tag_header_size: The size of header on disk, PDP_TAG_HEADER_SIZE_1 or PDP_TAG_HEADER_SIZE_2 for an
+ * older file.
+ */
+func tag_header_size(header *PDP_tag_header) int64 {
+	switch header.version {
+	case PDP_TAG_FILE_VERSION_1:
+		return PDP_TAG_HEADER_SIZE_1
+	case PDP_TAG_FILE_VERSION_2:
+		return PDP_TAG_HEADER_SIZE_2
+	}
+	return PDP_TAG_HEADER_SIZE
+}
+
+/* pdp_key_scheme: The scheme of key, as recorded in tag file headers. */
+func pdp_key_scheme(key *PDP_key) uint32 {
+	if key.bls != nil {
+		return PDP_SCHEME_BLS
+	}
+	if key.mac != nil {
+		return PDP_SCHEME_MAC
+	}
+	return PDP_SCHEME_RSA
+}
+
+/* scheme_names_files: Returns whether files of scheme are tagged under a name, see pdp_named_key. */
+func scheme_names_files(scheme uint32) bool {
+	return scheme == PDP_SCHEME_BLS || scheme == PDP_SCHEME_MAC
+}
+
+/* tag_header_matches: Returns whether a tag file with header was tagged under key's scheme and size,
+ * and under key's name if it has one.
+ */
+func tag_header_matches(header *PDP_tag_header, key *PDP_key) bool {
+	if key.name != nil && !bytes.Equal(key.name, header.name) {
+		return false
+	}
+	return header.scheme == pdp_key_scheme(key) && int(header.tag_size) == modulus_size(key)
+}
+
+/* tag_file_key: key named after the file with header, for tagging or checking its blocks. */
+func tag_file_key(key *PDP_key, header *PDP_tag_header) *PDP_key {
+	if !scheme_names_files(header.scheme) {
+		return key
+	}
+	return pdp_named_key(key, header.name)
+}
+
+/* pdp_named_key: A copy of the BLS or MAC key key, which may hold only its public components, for the
+ * file named name.  Block hashes or PRFs are keyed with the name, H(name | i) or f(name | i), so a file's
+ * tags and proofs verify for no other file under the same key.  Returns nil if key is not a BLS or MAC
+ * key or name is not PDP_TAG_FILE_NAME_SIZE bytes.
+ */
+func pdp_named_key(key *PDP_key, name []byte) *PDP_key {
+	var named PDP_key
+
+	if key == nil || !scheme_names_files(pdp_key_scheme(key)) || len(name) != PDP_TAG_FILE_NAME_SIZE {
+		return nil
+	}
+	named = *key
+	named.name = append([]byte{}, name...)
+
+	return &named
+}
+
+/* pdp_tag_file_name: The name the file with the tag file at tagFilepath was tagged under, nil for an
+ * RSA file.
+ */
+func pdp_tag_file_name(tagFilepath string) ([]byte, error) {
+
+	tagfile, err := os.Open(tagFilepath)
+	if err != nil {
+		return nil, err
+	}
+	defer tagfile.Close()
+	header, err := read_pdp_tag_header(tagfile)
+	if err != nil {
+		return nil, err
+	}
+
+	return header.name, nil
+}
+
+/* write_pdp_tag: Writes tag into its record in tagfile. */
+func write_pdp_tag(tagfile *os.File, header *PDP_tag_header, tag *PDP_tag) error {
+	if tag == nil {
+		return ErrBadTagFile
+	}
+	return write_pdp_tag_at(tagfile, header, tag.index, tag)
+}
+
+/* write_pdp_tag_at: Writes tag into the record of block position, which differs from the tag's own
+ * index for files changed with the dynamic block operations.
+ */
+func write_pdp_tag_at(tagfile *os.File, header *PDP_tag_header, position uint, tag *PDP_tag) error {
+	var record = make([]byte, header.tag_size)
+
+	if tag == nil || tag.Tim == nil || (tag.Tim.BitLen()+7)/8 > int(header.tag_size) {
+		return ErrBadTagFile
+	}
+	tag.Tim.FillBytes(record)
+
+	_, err := tagfile.WriteAt(record, tag_offset(header, position))
+	return err
+}
+
+/* read_pdp_tag: Reads the tag of block index from tagfile.  Returns an allocated tag or nil on failure. */
+func (pdpCore *PDPCore) read_pdp_tag(tagfile *os.File, index uint) *PDP_tag {
+
+	header, err := read_pdp_tag_header(tagfile)
+	if err != nil {
+		return nil
+	}
+
+	return pdpCore.read_pdp_tag_at(tagfile, header, index)
+}
+
+/* read_pdp_tag_at: Like read_pdp_tag for callers that already hold the tag file header. */
+func (pdpCore *PDPCore) read_pdp_tag_at(tagfile io.ReaderAt, header *PDP_tag_header, index uint) *PDP_tag {
+	var record = make([]byte, header.tag_size)
+	var tag *PDP_tag
+
+	if uint64(index) >= header.tagged {
+		return nil
+	}
+	if _, err := tagfile.ReadAt(record, tag_offset(header, index)); err != nil {
+		return nil
+	}
+
+	tag = pdpCore.generate_pdp_tag()
+	tag.index = index
+	tag.Tim = new(big.Int).SetBytes(record)
+
+	return tag
+}
+
+func tag_offset(header *PDP_tag_header, index uint) int64 {
+	return tag_header_size(header) + int64(index)*int64(header.tag_size)
+}
+
+/* TagFile: Exported form of pdp_tag_file for the command line tool. */
+func (pdpCore *PDPCore) TagFile(ctx context.Context, key *PDP_key, filepath string, tagFilepath string, opts *PDP_tag_options) error {
+	return pdpCore.pdp_tag_file(ctx, key, filepath, tagFilepath, opts)
+}
+
+/* TagFileName: Exported form of pdp_tag_file_name for the command line tool. */
+func TagFileName(tagFilepath string) ([]byte, error) {
+	return pdp_tag_file_name(tagFilepath)
+}
+
+/* NamedKey: Exported form of pdp_named_key. */
+func NamedKey(key *PDP_key, name []byte) *PDP_key {
+	return pdp_named_key(key, name)
+}
+
+/* AppendFile: Exported form of pdp_append_file for the command line tool. */
+func (pdpCore *PDPCore) AppendFile(ctx context.Context, key *PDP_key, filepath string, tagFilepath string,
+	data io.Reader, opts *PDP_tag_options) (uint64, error) {
+	return pdpCore.pdp_append_file(ctx, key, filepath, tagFilepath, data, opts)
+}
+
+const (
+	/* Longest run of adjacent challenged blocks read with a single call */
+	PDP_PROVE_MAX_RUN_BLOCKS = 64
+
+	/* Number of blocks a prove worker gathers before folding them into its partial proof */
+	PDP_PROVE_BATCH_BLOCKS = 64
+
+	/* Default memory budget for blocks and tags buffered by the prover */
+	PDP_PROVE_MEMORY = 64 << 20
+)
+
+/* PDP_prove_options controls pdp_prove_file.  Readers is the number of goroutines prefetching
+ * blocks and Workers the number doing the exponentiations; both default to 1.  MaxMemory caps the
+ * bytes of blocks and tags read but not yet folded into the proof (PDP_PROVE_MEMORY if 0).  Mask
+ * has public proofs masked with pdp_mask_proof so the auditor learns nothing about the blocks.
+ */
+type PDP_prove_options struct {
+	Readers   int
+	Workers   int
+	MaxMemory uint64
+	Mask      bool
+}
+
+var ErrBadChallenge = errors.New("pdp: challenge does not match the file")
+var ErrMemoryBudget = errors.New("pdp: memory budget is too small for one block")
+
+/* PDP_prove_aborted is returned by pdp_prove_file when its context is cancelled or times out.
+ * Err is the context's error, so errors.Is(err, context.DeadlineExceeded) works as expected.
+ */
+type PDP_prove_aborted struct {
+	Err error
+}
+
+func (e *PDP_prove_aborted) Error() string {
+	return "pdp: proof generation aborted: " + e.Err.Error()
+}
+
+func (e *PDP_prove_aborted) Unwrap() error {
+	return e.Err
+}
+
+/* Timeout reports whether the proof was abandoned because the context's deadline passed. */
+func (e *PDP_prove_aborted) Timeout() bool {
+	return e.Err == context.DeadlineExceeded
+}
+
+/* A run of adjacent challenged blocks, first to first+len(js)-1.  js[k] is the position in the
+ * challenge of block first+k, which selects its coefficient a_j = f_k2(j).
+ */
+type pdp_prove_run struct {
+	first uint
+	js    []uint
+}
+
+type pdp_prove_item struct {
+	js     []uint
+	tags   []*PDP_tag
+	blocks []*string
+	sizes  []uint64
+	err    error
+}
+
+/* pdp_budget hands out a fixed number of block slots.  Acquisitions are serialised so two readers
+ * each holding part of what they need cannot starve each other.
+ */
+type pdp_budget struct {
+	slots chan struct{}
+	lock  chan struct{}
+}
+
+func new_pdp_budget(slots int) *pdp_budget {
+	return &pdp_budget{
+		slots: make(chan struct{}, slots),
+		lock:  make(chan struct{}, 1),
+	}
+}
+
+func (budget *pdp_budget) acquire(ctx context.Context, n int) error {
+
+	select {
+	case budget.lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-budget.lock }()
+
+	for k := 0; k < n; k++ {
+		select {
+		case budget.slots <- struct{}{}:
+		case <-ctx.Done():
+			budget.release(k)
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func (budget *pdp_budget) release(n int) {
+	for k := 0; k < n; k++ {
+		<-budget.slots
+	}
+}
+
+/* pdp_prove_file: Server-side function that generates a proof for challenge over the file at filepath
+ * and its tags in tagFilepath (filepath + ".tag" if empty).  The challenged blocks are read in file
+ * order, adjacent blocks with one read, by opts.Readers goroutines while opts.Workers goroutines fold
+ * them into partial proofs; the partial proofs are combined and finalised here.  Each block keeps the
+ * coefficient of its position in the challenge, so the result is identical to calling
+ * pdp_generate_proof_update in challenge order.  Buffered blocks and tags never exceed opts.MaxMemory.
+ * NOTE: The key and challenge should only contain the public components.
+ * Returns the final proof, a *PDP_prove_aborted if ctx ends first, or another error on failure.
+ */
+func (pdpCore *PDPCore) pdp_prove_file(ctx context.Context, filepath string, tagFilepath string, challenge *PDP_challenge,
+	key *PDP_key, opts *PDP_prove_options) (*PDP_proof, error) {
+
+	var start = time.Now()
+	var read_bytes uint64 = 0
+
+	ctx, span := start_span(ctx, "pdp.prove_file")
+	proof, err := pdpCore.prove_file(ctx, filepath, tagFilepath, challenge, key, opts, &read_bytes)
+	observe_proof(key, start, read_bytes, err)
+	if challenge != nil {
+		span.SetAttributes(attribute.Int64("pdp.challenged", int64(challenge.c)),
+			attribute.Int64("pdp.numfileblocks", int64(challenge.numfileblocks)))
+	}
+	span.SetAttributes(attribute.Int64("pdp.read_bytes", int64(read_bytes)))
+	end_span(span, err)
+
+	return proof, err
+}
+
+/* prove_file: pdp_prove_file without the metrics.  Sets read_bytes to the bytes of blocks and tags the
+ * proof reads.
+ */
+func (pdpCore *PDPCore) prove_file(ctx context.Context, filepath string, tagFilepath string, challenge *PDP_challenge,
+	key *PDP_key, opts *PDP_prove_options, read_bytes *uint64) (*PDP_proof, error) {
+
+	var file *os.File
+	var tagfile *os.File
+	var header *PDP_tag_header
+	var indices []uint
+	var runs []*pdp_prove_run
+	var readers int = 1
+	var workers int = 1
+	var memory uint64 = PDP_PROVE_MEMORY
+	var block_cost uint64
+	var max_run uint64
+	var err error
+
+	if !valid_pdp_key(key) {
+		return nil, errors.New("pdp: invalid key")
+	}
+	if challenge == nil || challenge.c == 0 {
+		return nil, ErrBadChallenge
+	}
+	if opts != nil && opts.Readers > 0 {
+		readers = opts.Readers
+	}
+	if opts != nil && opts.Workers > 0 {
+		workers = opts.Workers
+	}
+	if opts != nil && opts.MaxMemory > 0 {
+		memory = opts.MaxMemory
+	}
+	if tagFilepath == "" {
+		tagFilepath = filepath + PDP_TAG_FILE_SUFFIX
+	}
+
+	file, err = os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	tagfile, err = os.Open(tagFilepath)
+	if err != nil {
+		return nil, err
+	}
+	defer tagfile.Close()
+
+	header, err = read_pdp_tag_header(tagfile)
+	if err != nil {
+		return nil, err
+	}
+	if header.tagged != header.numfileblocks || !tag_header_matches(header, key) {
+		return nil, ErrBadTagFile
+	}
+	if uint64(challenge.numfileblocks) > header.numfileblocks || challenge.c > challenge.numfileblocks {
+		return nil, ErrBadChallenge
+	}
+	if !bytes.Equal(challenge.name, header.name) {
+		return nil, fmt.Errorf("%w: it names another file", ErrBadChallenge)
+	}
+
+	/* A buffered block costs its data twice (read buffer and message copy) and its tag twice (record and BIGNUM) */
+	block_cost = 2 * (uint64(header.block_size) + uint64(header.tag_size))
+	max_run = memory / block_cost
+	if max_run == 0 {
+		return nil, ErrMemoryBudget
+	}
+	if max_run > PDP_PROVE_MAX_RUN_BLOCKS {
+		max_run = PDP_PROVE_MAX_RUN_BLOCKS
+	}
+
+	/* Compute the indices i_j = pi_k1(j) and sort them into runs of adjacent blocks */
+	indices = pdpCore.generate_prp_pi(challenge)
+	if uint(len(indices)) < challenge.c {
+		return nil, ErrBadChallenge
+	}
+	runs = sort_prove_runs(indices[:challenge.c], header.numfileblocks, int(max_run))
+	if runs == nil {
+		return nil, ErrBadChallenge
+	}
+	*read_bytes = uint64(challenge.c) * (uint64(header.block_size) + uint64(header.tag_size))
+
+	proof, err := pdpCore.prove_runs(ctx, key, challenge, file, tagfile, header, runs, readers, workers,
+		new_pdp_budget(int(memory/block_cost)))
+	if err != nil || challenge.g_s != nil || key.rsa == nil || opts == nil || !opts.Mask {
+		return proof, err
+	}
+
+	/* Mask a public proof so the auditor learns nothing about the blocks */
+	if proof = pdpCore.pdp_mask_proof(key, challenge, proof); proof == nil {
+		return nil, errors.New("pdp: could not mask proof")
+	}
+
+	return proof, nil
+}
+
+/* prove_runs: Reads runs on readers goroutines and folds them into a proof on workers goroutines.
+ * Readers take a budget slot per block before reading and workers give it back once the block is folded.
+ */
+func (pdpCore *PDPCore) prove_runs(ctx context.Context, key *PDP_key, challenge *PDP_challenge, file io.ReaderAt,
+	tagfile io.ReaderAt, header *PDP_tag_header, runs []*pdp_prove_run, readers int, workers int,
+	budget *pdp_budget) (*PDP_proof, error) {
+
+	var read_wg sync.WaitGroup
+	var work_wg sync.WaitGroup
+	var run_queue = make(chan *pdp_prove_run)
+	var items = make(chan *pdp_prove_item, readers)
+	var partials = make([]*PDP_proof, workers)
+	var failed sync.Once
+	var err error
+	var proof *PDP_proof
+	var read_nanos int64 = 0
+	var fold_nanos int64 = 0
+	var folds int64 = 0
+
+	parent := ctx
+	_, read_span := start_span(parent, "pdp.read_blocks", attribute.Int("pdp.runs", len(runs)),
+		attribute.Int("pdp.readers", readers))
+	_, update_span := start_span(parent, "pdp.generate_proof_update", attribute.Int("pdp.workers", workers))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fail := func(e error) {
+		failed.Do(func() {
+			err = e
+			cancel()
+		})
+	}
+
+	go func() {
+		defer close(run_queue)
+		for _, run := range runs {
+			select {
+			case run_queue <- run:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for r := 0; r < readers; r++ {
+		read_wg.Add(1)
+		go func() {
+			defer read_wg.Done()
+			for run := range run_queue {
+				if e := budget.acquire(ctx, len(run.js)); e != nil {
+					return
+				}
+				read_start := time.Now()
+				item := pdpCore.read_prove_run(file, tagfile, header, run)
+				atomic.AddInt64(&read_nanos, int64(time.Since(read_start)))
+				select {
+				case items <- item:
+				case <-ctx.Done():
+					budget.release(len(run.js))
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		read_wg.Wait()
+		read_span.SetAttributes(attribute.Float64("pdp.read_seconds", time.Duration(atomic.LoadInt64(&read_nanos)).Seconds()))
+		read_span.End()
+		close(items)
+	}()
+
+	for w := 0; w < workers; w++ {
+		work_wg.Add(1)
+		go func(w int) {
+			defer work_wg.Done()
+			var batch = &pdp_prove_item{}
+
+			fold := func() bool {
+				fold_start := time.Now()
+				partials[w] = pdpCore.prove_batch(key, challenge, partials[w], batch)
+				atomic.AddInt64(&fold_nanos, int64(time.Since(fold_start)))
+				atomic.AddInt64(&folds, 1)
+				budget.release(len(batch.js))
+				batch = &pdp_prove_item{}
+				if partials[w] == nil {
+					fail(errors.New("pdp: could not generate proof"))
+					return false
+				}
+				return true
+			}
+
+			for {
+				var item *pdp_prove_item
+				var ok bool
+
+				/* Fold a partial batch whenever the queue runs dry, so readers waiting on the
+				 * budget are never blocked behind blocks sitting in a batch */
+				select {
+				case item, ok = <-items:
+				default:
+					if len(batch.js) > 0 && !fold() {
+						return
+					}
+					select {
+					case item, ok = <-items:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if !ok {
+					break
+				}
+				if item.err != nil {
+					budget.release(len(item.js))
+					fail(item.err)
+					return
+				}
+				if ctx.Err() != nil {
+					budget.release(len(item.js))
+					return
+				}
+
+				batch.js = append(batch.js, item.js...)
+				batch.tags = append(batch.tags, item.tags...)
+				batch.blocks = append(batch.blocks, item.blocks...)
+				batch.sizes = append(batch.sizes, item.sizes...)
+				if len(batch.js) >= PDP_PROVE_BATCH_BLOCKS && !fold() {
+					return
+				}
+			}
+			if len(batch.js) > 0 {
+				fold()
+			}
+		}(w)
+	}
+
+	work_wg.Wait()
+	for item := range items {
+		budget.release(len(item.js))
+	}
+	update_span.SetAttributes(attribute.Float64("pdp.fold_seconds", time.Duration(fold_nanos).Seconds()),
+		attribute.Int64("pdp.batches", folds))
+	end_span(update_span, err)
+
+	if err != nil {
+		return nil, err
+	}
+	if parent.Err() != nil {
+		return nil, &PDP_prove_aborted{Err: parent.Err()}
+	}
+
+	/* Combine the partial proofs, T = prod T_w mod N and rho_temp = sum rho_w */
+	for _, partial := range partials {
+		if partial == nil {
+			continue
+		}
+		if proof == nil {
+			proof = partial
+			continue
+		}
+		if key.mac != nil {
+			if proof = mac_combine_proofs(proof, partial); proof == nil {
+				return nil, errors.New("pdp: could not generate proof")
+			}
+			continue
+		}
+		if key.bls != nil {
+			if proof = bls_combine_proofs(proof, partial); proof == nil {
+				return nil, errors.New("pdp: could not generate proof")
+			}
+			continue
+		}
+		proof.T = ModMul(proof.T, partial.T, key.rsa.N)
+		proof.rho_temp.Add(proof.rho_temp, partial.rho_temp)
+	}
+	if proof == nil {
+		return nil, errors.New("pdp: could not generate proof")
+	}
+
+	_, final_span := start_span(parent, "pdp.generate_proof_final")
+	proof = pdpCore.pdp_generate_proof_final(key, challenge, proof)
+	final_span.End()
+	if proof == nil {
+		return nil, errors.New("pdp: could not generate proof")
+	}
+
+	return proof, nil
+}
+
+/* prove_batch: Folds a batch of challenged blocks into the partial proof, creating it if nil. */
+func (pdpCore *PDPCore) prove_batch(key *PDP_key, challenge *PDP_challenge, partial *PDP_proof, batch *pdp_prove_item) *PDP_proof {
+	var bases = make([]*big.Int, len(batch.js))
+	var coefficients = make([]*big.Int, len(batch.js))
+	var prf_result *string
+	var prf_result_size uint64 = 0
+	var T *big.Int
+
+	if len(batch.tags) != len(batch.js) || len(batch.blocks) != len(batch.js) || len(batch.sizes) != len(batch.js) {
+		return nil
+	}
+	if key.bls != nil || key.mac != nil {
+		var blocks = make([][]byte, len(batch.js))
+		for k := range batch.js {
+			if batch.blocks[k] == nil {
+				return nil
+			}
+			blocks[k] = block_data(batch.blocks[k], batch.sizes[k])
+		}
+		if key.mac != nil {
+			return pdpCore.mac_generate_proof_update(challenge, partial, batch.js, batch.tags, blocks)
+		}
+		return pdpCore.bls_generate_proof_update(challenge, partial, batch.js, batch.tags, blocks)
+	}
+
+	if partial == nil {
+		partial = pdpCore.generate_pdp_proof()
+		partial.T = big.NewInt(1)
+		partial.rho_temp = new(big.Int)
+	}
+
+	for k, j := range batch.js {
+		if batch.tags[k] == nil {
+			return nil
+		}
+		bases[k] = batch.tags[k].Tim
+
+		if USE_E_PDP == 1 { /* Use E-PDP */
+			coefficients[k] = bigOne
+		} else { /* Use S-PDP */
+			/* The coefficient belongs to the block's position in the challenge, a_j = f_k2(j) */
+			prf_result = pdpCore.generate_prf_f(challenge, j, &prf_result_size)
+			if prf_result == nil {
+				return nil
+			}
+			coefficients[k] = prf_to_bn(prf_result, prf_result_size)
+		}
+
+		r0 := new(big.Int).Mul(coefficients[k], block_to_bn(batch.blocks[k], batch.sizes[k]))
+		partial.rho_temp.Add(partial.rho_temp, r0)
+	}
+
+	T = multi_exp(bases, coefficients, key.rsa.N)
+	partial.T = ModMul(partial.T, T, key.rsa.N)
+
+	return partial
+}
+
+/* read_prove_run: Reads the blocks and tags of a run with one read from each file.  Every block the header
+ * says exists must be read in full, except the last block of the file, which only needs to be non-empty;
+ * a file shorter than that fails the run with ErrFileShrunk rather than proving over missing data.
+ */
+func (pdpCore *PDPCore) read_prove_run(file io.ReaderAt, tagfile io.ReaderAt, header *PDP_tag_header, run *pdp_prove_run) *pdp_prove_item {
+	var count = len(run.js)
+	var data = make([]byte, count*int(header.block_size))
+	var records = make([]byte, count*int(header.tag_size))
+	var item = &pdp_prove_item{js: run.js}
+
+	n, err := file.ReadAt(data, int64(run.first)*int64(header.block_size))
+	if err != nil && err != io.EOF {
+		item.err = err
+		return item
+	}
+	data = data[:n]
+	if _, err = tagfile.ReadAt(records, tag_offset(header, run.first)); err != nil {
+		item.err = err
+		return item
+	}
+
+	for k := 0; k < count; k++ {
+		start := k * int(header.block_size)
+		end := start + int(header.block_size)
+		if start > len(data) {
+			start = len(data)
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		if end-start < int(header.block_size) && (uint64(run.first)+uint64(k)+1 < header.numfileblocks || end == start) {
+			item.err = fmt.Errorf("%w: block %d has %d bytes", ErrFileShrunk, run.first+uint(k), end-start)
+			return item
+		}
+		block := string(data[start:end])
+
+		tag := pdpCore.generate_pdp_tag()
+		tag.index = run.first + uint(k)
+		tag.Tim = new(big.Int).SetBytes(records[k*int(header.tag_size) : (k+1)*int(header.tag_size)])
+
+		item.tags = append(item.tags, tag)
+		item.blocks = append(item.blocks, &block)
+		item.sizes = append(item.sizes, uint64(end-start))
+	}
+
+	return item
+}
+
+/* sort_prove_runs: Sorts the challenged indices and groups adjacent ones into runs of at most
+ * max_run blocks.  Returns nil if an index is outside the file.
+ */
+func sort_prove_runs(indices []uint, numfileblocks uint64, max_run int) []*pdp_prove_run {
+	var order = make([]uint, len(indices))
+	var runs []*pdp_prove_run
+	var run *pdp_prove_run
+
+	for j := range order {
+		if uint64(indices[j]) >= numfileblocks {
+			return nil
+		}
+		order[j] = uint(j)
+	}
+	sort.Slice(order, func(a, b int) bool { return indices[order[a]] < indices[order[b]] })
+
+	for _, j := range order {
+		index := indices[j]
+		if run == nil || index != run.first+uint(len(run.js)) || len(run.js) == max_run {
+			run = &pdp_prove_run{first: index}
+			runs = append(runs, run)
+		}
+		run.js = append(run.js, j)
+	}
+
+	return runs
+}
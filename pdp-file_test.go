@@ -0,0 +1,321 @@
+package gopdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* read_test_header: Reads the header of the tag file at path. */
+func read_test_header(t *testing.T, path string) *PDP_tag_header {
+	t.Helper()
+
+	tagfile, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tagfile.Close()
+	header, err := read_pdp_tag_header(tagfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return header
+}
+
+/* check_test_tags: Fails the test unless every tag in the tag file of path matches its block. */
+func check_test_tags(t *testing.T, key *PDP_key, path string) {
+	t.Helper()
+
+	var core = NewPDPCore()
+	var block = make([]byte, PDP_BLOCKSIZE)
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	tagfile, _ := os.Open(path + PDP_TAG_FILE_SUFFIX)
+	defer tagfile.Close()
+	header, err := read_pdp_tag_header(tagfile)
+	if err != nil || header.tagged != header.numfileblocks {
+		t.Fatalf("tag file incomplete: %v", err)
+	}
+	for i := uint64(0); i < header.numfileblocks; i++ {
+		tag := core.read_pdp_tag_at(tagfile, header, uint(i))
+		if tag == nil || core.check_pdp_tag(key, file, block, tag) != 1 {
+			t.Fatalf("tag %d does not match", i)
+		}
+	}
+}
+
+func TestTagFileCheckpointsOnCancel(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	path, _ := write_test_file(t, 40*PDP_BLOCKSIZE)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var written uint64
+	opts := &PDP_tag_options{Workers: 4, Progress: func(p PDP_tag_progress) {
+		written = p.Blocks
+		if p.Blocks == 10 {
+			cancel()
+		}
+	}}
+	if err := core.pdp_tag_file(ctx, key, path, "", opts); err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	/* Every tag written before the run stopped is kept */
+	header := read_test_header(t, path+PDP_TAG_FILE_SUFFIX)
+	if header.tagged < 10 || header.tagged != written {
+		t.Fatalf("checkpoint at %d, wrote %d tags", header.tagged, written)
+	}
+
+	var resumed uint64
+	opts = &PDP_tag_options{Workers: 4, Resume: true, Progress: func(p PDP_tag_progress) {
+		if resumed == 0 {
+			resumed = p.Blocks
+		}
+	}}
+	if err := core.pdp_tag_file(context.Background(), key, path, "", opts); err != nil {
+		t.Fatal(err)
+	}
+	if resumed != written+1 {
+		t.Fatalf("resumed at block %d, checkpoint was %d", resumed-1, written)
+	}
+	check_test_tags(t, key, path)
+}
+
+func TestParallelTagFileMatchesSerial(t *testing.T) {
+	var core = NewPDPCore()
+	var ctx = context.Background()
+	var progress []PDP_tag_progress
+
+	key := new_test_key(t)
+	path, data := write_test_file(t, 300*PDP_BLOCKSIZE+100)
+	if err := core.pdp_tag_file(ctx, key, path, path+".serial", &PDP_tag_options{Workers: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	/* Many workers finish blocks out of order; the writer must still emit them by index */
+	opts := &PDP_tag_options{Workers: 16, Progress: func(p PDP_tag_progress) {
+		progress = append(progress, p)
+	}}
+	if err := core.pdp_tag_file(ctx, key, path, "", opts); err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := ioutil.ReadFile(path + ".serial")
+	parallel, _ := ioutil.ReadFile(path + PDP_TAG_FILE_SUFFIX)
+	if len(serial) == 0 || !bytes.Equal(serial, parallel) {
+		t.Fatal("tag file written by 16 workers differs from the one written by 1")
+	}
+
+	/* Progress is reported once per block written, in order, with the bytes tagged so far */
+	if len(progress) != 301 {
+		t.Fatalf("%d progress reports for 301 blocks", len(progress))
+	}
+	for i, p := range progress {
+		want := uint64(i+1) * PDP_BLOCKSIZE
+		if i == 300 {
+			want = uint64(len(data))
+		}
+		if p.Blocks != uint64(i+1) || p.TotalBlocks != 301 || p.Bytes != want || p.BytesPerSec < 0 {
+			t.Fatalf("progress report %d is %+v", i, p)
+		}
+	}
+}
+
+func TestResumeVerifiesTail(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	path, _ := tag_test_file(t, key, 40*PDP_BLOCKSIZE)
+
+	/* A run that died after writing all tags but before its checkpoint */
+	tagfile, err := os.OpenFile(path+PDP_TAG_FILE_SUFFIX, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _ := read_pdp_tag_header(tagfile)
+	if err := checkpoint_pdp_tag_file(tagfile, header, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	/* ... and tore the record of block 30 */
+	record := make([]byte, header.tag_size)
+	if _, err := tagfile.WriteAt(record, tag_offset(header, 30)); err != nil {
+		t.Fatal(err)
+	}
+	tagfile.Close()
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	tagfile, header, first := core.resume_tag_file(key, file, path+PDP_TAG_FILE_SUFFIX, 40)
+	if tagfile == nil {
+		t.Fatal("could not resume")
+	}
+	tagfile.Close()
+	if first != 30 || header.tagged != 30 {
+		t.Fatalf("resume at %d with checkpoint %d, want 30", first, header.tagged)
+	}
+
+	if err := core.pdp_tag_file(context.Background(), key, path, "", &PDP_tag_options{Resume: true}); err != nil {
+		t.Fatal(err)
+	}
+	check_test_tags(t, key, path)
+}
+
+func TestProveShortFile(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	path, _ := tag_test_file(t, key, 20*PDP_BLOCKSIZE+100)
+
+	/* The file lost its last block and part of the one before */
+	if err := os.Truncate(path, 19*PDP_BLOCKSIZE-10); err != nil {
+		t.Fatal(err)
+	}
+
+	challenge := core.pdp_challenge(key, 21)
+	_, err := core.pdp_prove_file(context.Background(), path, "", core.sanitize_pdp_challenge(challenge),
+		public_test_key(key), nil)
+	if !errors.Is(err, ErrFileShrunk) {
+		t.Fatalf("got %v, want ErrFileShrunk", err)
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	var core = NewPDPCore()
+	var ctx = context.Background()
+
+	key := new_test_key(t)
+	path, _ := tag_test_file(t, key, 10*PDP_BLOCKSIZE+100)
+
+	more, _ := GenerateRandomBytes(3 * PDP_BLOCKSIZE)
+	numfileblocks, err := core.pdp_append_file(ctx, key, path, "", bytes.NewReader(more), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numfileblocks != 14 {
+		t.Fatalf("%d blocks after the append, want 14", numfileblocks)
+	}
+	check_test_tags(t, key, path)
+
+	/* Once a block is inserted the file is tagged by tag index and may not be appended to */
+	block, _ := GenerateRandomBytes(PDP_BLOCKSIZE)
+	if err := core.pdp_insert_block(key, path, "", "", 2, block); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+	_, err = core.pdp_append_file(ctx, key, path, "", bytes.NewReader(more), nil)
+	if !errors.Is(err, ErrDynamicFile) {
+		t.Fatalf("append to a file with an index table: %v", err)
+	}
+	if after, _ := os.Stat(path); after.Size() != info.Size() {
+		t.Fatal("data was appended to a file with an index table")
+	}
+}
+
+/* cancel_test_reader cancels a context on its first read, so a proof is cut off while blocks are in flight. */
+type cancel_test_reader struct {
+	file   *os.File
+	cancel context.CancelFunc
+}
+
+func (reader *cancel_test_reader) ReadAt(p []byte, offset int64) (int, error) {
+	reader.cancel()
+	return reader.file.ReadAt(p, offset)
+}
+
+/* wait_test_goroutines: Fails the test if more than want goroutines are still running after a second. */
+func wait_test_goroutines(t *testing.T, want int) {
+	t.Helper()
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > want; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > want {
+		t.Fatalf("%d goroutines running, %d before the proof", n, want)
+	}
+}
+
+func TestProveMemoryBudget(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	path, _ := tag_test_file(t, key, 20*PDP_BLOCKSIZE)
+	challenge := core.sanitize_pdp_challenge(core.pdp_challenge(key, 20))
+
+	/* A budget below one block and its tag cannot buffer anything */
+	opts := &PDP_prove_options{MaxMemory: PDP_BLOCKSIZE}
+	_, err := core.pdp_prove_file(context.Background(), path, "", challenge, public_test_key(key), opts)
+	if err != ErrMemoryBudget {
+		t.Fatalf("got %v, want ErrMemoryBudget", err)
+	}
+	if http_status(err) != http.StatusServiceUnavailable || status.Code(grpc_status(err)) != codes.ResourceExhausted {
+		t.Errorf("exhausted budget is reported as %d and %v", http_status(err), status.Code(grpc_status(err)))
+	}
+
+	/* One block at a time is enough, however many readers and workers there are */
+	opts = &PDP_prove_options{MaxMemory: 2 * (PDP_BLOCKSIZE + 128), Readers: 4, Workers: 4}
+	if _, err := core.pdp_prove_file(context.Background(), path, "", challenge, public_test_key(key), opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProveAborted(t *testing.T) {
+	var core = NewPDPCore()
+	var aborted *PDP_prove_aborted
+
+	key := new_test_key(t)
+	path, _ := tag_test_file(t, key, 200*PDP_BLOCKSIZE)
+	challenge := core.sanitize_pdp_challenge(core.pdp_challenge(key, 200))
+	opts := &PDP_prove_options{Readers: 4, Workers: 4}
+	goroutines := runtime.NumGoroutine()
+
+	/* A cancelled context aborts the proof without a timeout */
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := core.pdp_prove_file(ctx, path, "", challenge, public_test_key(key), opts)
+	if !errors.As(err, &aborted) || aborted.Timeout() || !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled proof: %v", err)
+	}
+	if http_status(err) != http.StatusServiceUnavailable || status.Code(grpc_status(err)) != codes.Canceled {
+		t.Errorf("cancelled proof is reported as %d and %v", http_status(err), status.Code(grpc_status(err)))
+	}
+
+	/* A passed deadline aborts it with one */
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = core.pdp_prove_file(ctx, path, "", challenge, public_test_key(key), opts)
+	if !errors.As(err, &aborted) || !aborted.Timeout() || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("timed out proof: %v", err)
+	}
+	if http_status(err) != http.StatusGatewayTimeout || status.Code(grpc_status(err)) != codes.DeadlineExceeded {
+		t.Errorf("timed out proof is reported as %d and %v", http_status(err), status.Code(grpc_status(err)))
+	}
+
+	/* Cancelling while readers and workers are busy stops them all */
+	file, _ := os.Open(path)
+	defer file.Close()
+	tagfile, _ := os.Open(path + PDP_TAG_FILE_SUFFIX)
+	defer tagfile.Close()
+	header, _ := read_pdp_tag_header(tagfile)
+	runs := sort_prove_runs(core.generate_prp_pi(challenge)[:challenge.c], header.numfileblocks, 4)
+	ctx, cancel = context.WithCancel(context.Background())
+	_, err = core.prove_runs(ctx, public_test_key(key), challenge, &cancel_test_reader{file, cancel}, tagfile, header,
+		runs, 4, 4, new_pdp_budget(8))
+	if !errors.As(err, &aborted) || aborted.Timeout() {
+		t.Fatalf("proof cancelled while reading: %v", err)
+	}
+
+	wait_test_goroutines(t, goroutines)
+}
@@ -0,0 +1,122 @@
+package gopdp
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kebohan1/go-pdp/pdppb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* PDPGRPCServer serves a PDPServer as the pdppb.PDPAudit gRPC service. */
+type PDPGRPCServer struct {
+	pdppb.UnimplementedPDPAuditServer
+	server *PDPServer
+}
+
+/* RegisterPDPGRPCServer: Registers server as the PDPAudit service of grpcServer. */
+func RegisterPDPGRPCServer(grpcServer *grpc.Server, server *PDPServer) *PDPGRPCServer {
+	var service = &PDPGRPCServer{server: server}
+
+	pdppb.RegisterPDPAuditServer(grpcServer, service)
+
+	return service
+}
+
+func (service *PDPGRPCServer) Challenge(ctx context.Context, request *pdppb.ChallengeRequest) (*pdppb.ProofResponse, error) {
+
+	proof, err := service.server.Prove(extract_grpc(grpc_client_cert(ctx)), request.GetFileId(), request.GetChallenge())
+	if err != nil {
+		return nil, grpc_status(err)
+	}
+
+	return &pdppb.ProofResponse{Proof: proof}, nil
+}
+
+func (service *PDPGRPCServer) GetFileInfo(ctx context.Context, request *pdppb.FileInfoRequest) (*pdppb.FileInfoResponse, error) {
+
+	info, err := service.server.FileInfo(extract_grpc(grpc_client_cert(ctx)), request.GetFileId())
+	if err != nil {
+		return nil, grpc_status(err)
+	}
+
+	return &pdppb.FileInfoResponse{
+		NumBlocks:      info.NumBlocks,
+		BlockSize:      info.BlockSize,
+		KeyFingerprint: info.KeyFingerprint,
+	}, nil
+}
+
+/* grpc_status: Maps the server's errors onto gRPC status codes. */
+func grpc_status(err error) error {
+	var aborted *PDP_prove_aborted
+
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrUnknownFile):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrBadWireFormat), errors.Is(err, ErrBadChallenge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrMemoryBudget):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.As(err, &aborted) && aborted.Timeout():
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.As(err, &aborted):
+		return status.Error(codes.Canceled, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+/* PDPGRPCClient audits files held by a remote PDPAudit service.  It holds the verifier's private key. */
+type PDPGRPCClient struct {
+	core   *PDPCore
+	key    *PDP_key
+	client pdppb.PDPAuditClient
+}
+
+/* NewPDPGRPCClient: Returns a client auditing over conn with the verifier's key. */
+func NewPDPGRPCClient(conn grpc.ClientConnInterface, key *PDP_key) *PDPGRPCClient {
+	return &PDPGRPCClient{
+		core:   NewPDPCore(),
+		key:    key,
+		client: pdppb.NewPDPAuditClient(conn),
+	}
+}
+
+/* Audit challenges fileID, which the verifier tagged with numfileblocks blocks, and verifies the proof.
+ * Returns nil if it verified, ErrProofRejected if not.
+ */
+func (client *PDPGRPCClient) Audit(ctx context.Context, fileID string, numfileblocks uint64) error {
+	return client.core.pdp_audit_remote(ctx, client.key, client, fileID, numfileblocks)
+}
+
+/* FileInfo implements PDP_remote. */
+func (client *PDPGRPCClient) FileInfo(ctx context.Context, fileID string) (*PDP_file_info, error) {
+
+	response, err := client.client.GetFileInfo(inject_grpc(ctx), &pdppb.FileInfoRequest{FileId: fileID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PDP_file_info{
+		NumBlocks:      response.GetNumBlocks(),
+		BlockSize:      response.GetBlockSize(),
+		KeyFingerprint: response.GetKeyFingerprint(),
+	}, nil
+}
+
+/* Prove implements PDP_remote. */
+func (client *PDPGRPCClient) Prove(ctx context.Context, fileID string, challenge *PDP_challenge) (*PDP_proof, error) {
+
+	request := &pdppb.ChallengeRequest{FileId: fileID, Challenge: encode_pdp_challenge(challenge)}
+	response, err := client.client.Challenge(inject_grpc(ctx), request)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode_pdp_proof(response.GetProof(), client.key)
+}
@@ -0,0 +1,100 @@
+package gopdp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+/* serve_test_file: Tags a random file of blocks blocks under a new key and serves it as "data" from a
+ * PDPServer holding only the public key.  Returns the key pair, the server, the file's path and contents.
+ */
+func serve_test_file(t testing.TB, blocks int) (*PDP_key, *PDPServer, string, []byte) {
+	t.Helper()
+
+	key := new_test_key(t)
+	path, data := tag_test_file(t, key, (blocks-1)*PDP_BLOCKSIZE+100)
+	server := NewPDPServer(public_test_key(key), nil)
+	if err := server.AddFile("data", path, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	return key, server, path, data
+}
+
+/* corrupt_test_file: Flips a byte of every block of the file at path behind its tags, so any challenge
+ * catches it.
+ */
+func corrupt_test_file(t testing.TB, path string, data []byte) {
+	t.Helper()
+
+	corrupt := append([]byte(nil), data...)
+	for i := 0; i < len(corrupt); i += PDP_BLOCKSIZE {
+		corrupt[i] ^= 1
+	}
+	if err := ioutil.WriteFile(path, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/* grpc_test_client: Serves server over an in-memory gRPC connection and returns a client auditing it
+ * with key.
+ */
+func grpc_test_client(t testing.TB, server *PDPServer, key *PDP_key) *PDPGRPCClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	RegisterPDPGRPCServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return NewPDPGRPCClient(conn, key)
+}
+
+func TestGRPCAudit(t *testing.T) {
+	var ctx = context.Background()
+
+	key, server, path, data := serve_test_file(t, 25)
+	client := grpc_test_client(t, server, key)
+
+	if err := client.Audit(ctx, "data", 25); err != nil {
+		t.Fatal(err)
+	}
+
+	/* The remote's block count is never trusted */
+	if err := client.Audit(ctx, "data", 0); !errors.Is(err, ErrNoBlockCount) {
+		t.Fatalf("audit without a block count: %v", err)
+	}
+	if err := client.Audit(ctx, "data", 26); !errors.Is(err, ErrBlockCountMismatch) {
+		t.Fatalf("audit with another block count: %v", err)
+	}
+
+	if err := client.Audit(ctx, "other", 25); status.Code(err) != codes.NotFound {
+		t.Fatalf("audit of an unknown file: %v", err)
+	}
+	if err := grpc_test_client(t, server, new_test_key(t)).Audit(ctx, "data", 25); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("audit under another key: %v", err)
+	}
+
+	corrupt_test_file(t, path, data)
+	if err := client.Audit(ctx, "data", 25); !errors.Is(err, ErrProofRejected) {
+		t.Fatalf("audit of a corrupted file: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package gopdp
+
+import (
+	"math/big"
+)
+
+const (
+	/* Default memory budget for the generator table, split between the mod p and mod q halves */
+	PDP_GENERATOR_TABLE_SIZE = 4 << 20
+
+	PDP_GENERATOR_TABLE_MAX_WINDOW = 12
+)
+
+/* PDP_generator_table holds fixed-base precomputations of g modulo each private prime, so g^m can be
+ * computed with one multiplication per window of the exponent and no squarings.
+ * p_table[i][j-1] = g^(j * 2^(window*i)) mod p, and likewise for q.
+ */
+type PDP_generator_table struct {
+	window  uint
+	p_table [][]*big.Int
+	q_table [][]*big.Int
+}
+
+/* pdp_precompute_generator: Builds a fixed-base table for key.g and attaches it to the key.  The widest
+ * window whose table fits in max_bytes is used (PDP_GENERATOR_TABLE_SIZE if max_bytes is 0).  The table
+ * needs the private primes and is only used by the client-side tagging functions.
+ * Returns 1 on success, 0 if the key has no primes or max_bytes is too small for any table.
+ */
+func pdp_precompute_generator(key *PDP_key, max_bytes uint64) int {
+	var table *PDP_generator_table
+	var window uint
+
+	if key == nil || key.g == nil || crt_precomputed(key) == 0 {
+		return 0
+	}
+	if max_bytes == 0 {
+		max_bytes = PDP_GENERATOR_TABLE_SIZE
+	}
+
+	for window = PDP_GENERATOR_TABLE_MAX_WINDOW; window > 0; window-- {
+		if generator_table_size(key.rsa.Primes[0], window)+generator_table_size(key.rsa.Primes[1], window) <= max_bytes {
+			break
+		}
+	}
+	if window == 0 {
+		return 0
+	}
+
+	table = &PDP_generator_table{window: window}
+	table.p_table = build_generator_table(key.g, key.rsa.Primes[0], window)
+	table.q_table = build_generator_table(key.g, key.rsa.Primes[1], window)
+	key.g_table = table
+
+	return 1
+}
+
+/* PrecomputeGenerator: Exported form of pdp_precompute_generator for the command line tool. */
+func PrecomputeGenerator(key *PDP_key, maxBytes uint64) bool {
+	return pdp_precompute_generator(key, maxBytes) == 1
+}
+
+/* generator_exp: Computes g^exponent mod N for the key's generator, using the fixed-base table if one
+ * has been attached with pdp_precompute_generator and crt_exp otherwise.
+ */
+func generator_exp(key *PDP_key, exponent *big.Int) *big.Int {
+	var p *big.Int
+	var q *big.Int
+	var m1 *big.Int
+	var m2 *big.Int
+
+	if key.g_table == nil || crt_precomputed(key) == 0 {
+		return crt_exp(key, key.g, exponent)
+	}
+	p = key.rsa.Primes[0]
+	q = key.rsa.Primes[1]
+
+	m1 = fixed_base_exp(key.g_table.p_table, key.g_table.window, new(big.Int).Mod(exponent, new(big.Int).Sub(p, bigOne)), p)
+	m2 = fixed_base_exp(key.g_table.q_table, key.g_table.window, new(big.Int).Mod(exponent, new(big.Int).Sub(q, bigOne)), q)
+
+	return crt_combine(key, m1, m2)
+}
+
+/* generator_table_size: The number of bytes a table for modulus m and the given window takes. */
+func generator_table_size(m *big.Int, window uint) uint64 {
+	var bits = uint64(m.BitLen())
+	var rows = (bits + uint64(window) - 1) / uint64(window)
+
+	return rows * ((1 << window) - 1) * ((bits + 7) / 8)
+}
+
+/* build_generator_table: Builds the rows g^(j * 2^(window*i)) mod m covering exponents up to m's size. */
+func build_generator_table(g *big.Int, m *big.Int, window uint) [][]*big.Int {
+	var rows = (uint(m.BitLen()) + window - 1) / window
+	var table = make([][]*big.Int, rows)
+	var base = new(big.Int).Mod(g, m)
+
+	for i := uint(0); i < rows; i++ {
+		row := make([]*big.Int, (1<<window)-1)
+		row[0] = base
+		for j := 1; j < len(row); j++ {
+			row[j] = ModMul(row[j-1], base, m)
+		}
+		table[i] = row
+
+		/* The next row's base is base^(2^window) */
+		base = ModMul(row[len(row)-1], base, m)
+	}
+
+	return table
+}
+
+/* fixed_base_exp: Computes the table's base to the power exponent mod m, one window of exponent bits per row. */
+func fixed_base_exp(table [][]*big.Int, window uint, exponent *big.Int, m *big.Int) *big.Int {
+	var result = big.NewInt(1)
+	var bits = exponent.BitLen()
+
+	for i := 0; i*int(window) < bits; i++ {
+		var digit uint = 0
+		for k := int(window) - 1; k >= 0; k-- {
+			digit = (digit << 1) | exponent.Bit(i*int(window)+k)
+		}
+		if digit != 0 {
+			result = ModMul(result, table[i][digit-1], m)
+		}
+	}
+
+	return result
+}
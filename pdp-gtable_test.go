@@ -0,0 +1,66 @@
+package gopdp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+/* random_block_bn: A random exponent the size of a full data block. */
+func random_block_bn() *big.Int {
+	block := make([]byte, PDP_BLOCKSIZE)
+	rand.Read(block)
+	return new(big.Int).SetBytes(block)
+}
+
+func TestGeneratorTable(t *testing.T) {
+	key := new_crt_test_key(t, 1024)
+
+	if pdp_precompute_generator(key, 1024) != 0 {
+		t.Fatal("built a table in 1KB")
+	}
+	if pdp_precompute_generator(key, 0) != 1 {
+		t.Fatal("pdp_precompute_generator failed")
+	}
+	for i := 0; i < 4; i++ {
+		m := random_block_bn()
+		if generator_exp(key, m).Cmp(new(big.Int).Exp(key.g, m, key.rsa.N)) != 0 {
+			t.Fatal("generator_exp differs from Exp")
+		}
+	}
+
+	/* A public key has no primes and no table */
+	public := public_test_key(key)
+	if pdp_precompute_generator(public, 0) != 0 {
+		t.Fatal("built a table without the primes")
+	}
+}
+
+func BenchmarkGeneratorExp(b *testing.B) {
+	for _, bits := range []int{1024, 2048} {
+		key := new_crt_test_key(b, bits)
+		m := random_block_bn()
+
+		b.Run(fmt.Sprintf("Exp/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				new(big.Int).Exp(key.g, m, key.rsa.N)
+			}
+		})
+		b.Run(fmt.Sprintf("crt_exp/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				crt_exp(key, key.g, m)
+			}
+		})
+
+		table := *key
+		if pdp_precompute_generator(&table, 0) != 1 {
+			b.Fatal("pdp_precompute_generator failed")
+		}
+		b.Run(fmt.Sprintf("table/%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				generator_exp(&table, m)
+			}
+		})
+	}
+}
@@ -1,16 +1,9 @@
 package gopdp
 
-// #include <openssl/evp.h>
-// #include <openssl/ssl.h>
-// #include <openssl/conf.h>
-//
-
-import "C"
-
 import (
+	"context"
 	"crypto/rand"
 	RSA "crypto/rsa"
-	"github.com/spacemonkeygo/openssl"
 	"math/big"
 	"os"
 )
@@ -29,6 +22,9 @@ const (
 )
 
 type PDP_params struct {
+	/* PDP_SCHEME_RSA, PDP_SCHEME_BLS or PDP_SCHEME_MAC */
+	scheme uint
+
 	prf_key_size uint
 	prp_key_size uint
 	rsa_key_size uint
@@ -44,6 +40,21 @@ type PDP_key struct {
 	rsa *RSA.PrivateKey
 	v   *string
 	g   *big.Int
+
+	/* The public exponent of a per-file key, which replaces rsa.E; nil otherwise.  See pdp_file_key */
+	e *big.Int
+
+	/* Optional fixed-base table for g, see pdp_precompute_generator */
+	g_table *PDP_generator_table
+
+	/* The Shacham-Waters key of a PDP_SCHEME_BLS key pair, which has no rsa, v or g */
+	bls *PDP_bls_key
+
+	/* The name of the file a BLS or MAC key tags, which its block hashes or PRF are keyed with; see pdp_named_key */
+	name []byte
+
+	/* The secret of a PDP_SCHEME_MAC key, or only its ID for a prover */
+	mac *PDP_mac_key
 }
 
 type PDP_tag struct {
@@ -60,6 +71,12 @@ type PDP_challenge struct {
 	s             *big.Int
 	k1            *[]byte
 	k2            *[]byte
+
+	/* The replica audited, for MR-PDP; nil for the file itself.  It is not sent to the server */
+	replica *uint32
+
+	/* The name of the file a BLS or MAC challenge is for, checked by the server against its tag file */
+	name []byte
 }
 
 type PDP_proof struct {
@@ -67,17 +84,24 @@ type PDP_proof struct {
 	rho_temp *big.Int
 	rho      *string
 	rho_size uint64
+
+	/* The commitment g^r of a masked public proof, whose rho_temp is r + gamma * M; nil if unmasked */
+	R *big.Int
+
+	/* The sector sums of a BLS proof, whose T is sigma; see pdp-bls.go */
+	mu []*big.Int
 }
 
 type PDP interface {
 	/* PDP file operations in pdp-file.go */
-	pdp_tag_file(filepath *string, filePathLen *uint64, tagFilepath *string, tagFilepathLen *uint64)
+	pdp_tag_file(ctx context.Context, key *PDP_key, filepath string, tagFilepath string, opts *PDP_tag_options) error
 
 	pdp_challenge_file(numfileblocks uint) *PDP_challenge
 
 	/* NOTE: It's important that challenge->s must be kept secret from the server.  A server challenge is <c, k1, k2, g_s>.
 	 * Also, the key structures should only contain the public components.  See: pdp_get_pubkey() */
-	pdp_prove_file(filepath *string, filePathLen uint64, tagFilepath *string, tagFilepathLen *uint64, challenge *PDP_challenge, key *PDP_key) *PDP_proof
+	pdp_prove_file(ctx context.Context, filepath string, tagFilepath string, challenge *PDP_challenge,
+		key *PDP_key, opts *PDP_prove_options) (*PDP_proof, error)
 
 	pdp_verify_file(challenge *PDP_challenge, proof *PDP_proof) int
 
@@ -87,13 +111,16 @@ type PDP interface {
 
 	/* PDP core primatives in pdp-core.c*/
 
-	pdp_tag_block(key *PDP_key, block *string, blocksize uint64,
-		index uint) PDP_tag
+	pdp_tag_block(key *PDP_key, block *string, blocksize *uint64,
+		index uint) *PDP_tag
 
 	pdp_challenge(key *PDP_key, numfileblocks uint64) PDP_challenge
 
 	pdp_generate_proof_update(key *PDP_key, challenge *PDP_challenge, tag *PDP_tag,
-		proof *PDP_proof, block *string, blocksize uint64, j uint) *PDP_proof
+		proof *PDP_proof, block *string, blocksize *uint64, j uint) *PDP_proof
+
+	pdp_generate_proof_batch(key *PDP_key, challenge *PDP_challenge, tags []*PDP_tag,
+		blocks []*string, blocksizes []uint64) *PDP_proof
 
 	pdp_generate_proof_final(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof) *PDP_proof
 
@@ -101,9 +128,9 @@ type PDP interface {
 
 	/* PDP keying functions pdp-key.c */
 
-	pdp_create_new_keypair() *PDP_key
+	pdp_create_new_keypair(read_password PDP_password_func) *PDP_key
 
-	pdp_get_keypair() *PDP_key
+	pdp_get_keypair(read_password PDP_password_func) *PDP_key
 
 	pdp_get_pubkey() *PDP_key
 
@@ -116,7 +143,7 @@ type PDP interface {
 
 	sanitize_pdp_challenge(challenge *PDP_challenge) *PDP_challenge
 
-	generate_prp_pi(challenge *PDP_challenge) *uint
+	generate_prp_pi(challenge *PDP_challenge) []uint
 	generate_H(input *big.Int, H_result_size *uint64) *string
 	generate_prf_f(challenge *PDP_challenge, j uint, prf_result_size *uint64) *string
 	generate_prf_w(key *PDP_key, index uint, prf_result_size *uint64) *string
@@ -149,3 +176,22 @@ func ModMul(a *big.Int, b *big.Int, m *big.Int) *big.Int {
 	var temp = new(big.Int).Mul(a, b)
 	return new(big.Int).Mod(temp, m)
 }
+
+/* block_to_bn: Turns the first blocksize bytes of a data block into a big-endian BIGNUM. */
+func block_to_bn(block *string, blocksize uint64) *big.Int {
+	return new(big.Int).SetBytes(block_data(block, blocksize))
+}
+
+/* block_data: The first blocksize bytes of a data block, or all of it if it is shorter. */
+func block_data(block *string, blocksize uint64) []byte {
+	var data = *block
+	if uint64(len(data)) > blocksize {
+		data = data[:blocksize]
+	}
+	return []byte(data)
+}
+
+/* prf_to_bn: Turns a prf result into the big-endian BIGNUM used as a challenge coefficient. */
+func prf_to_bn(prf_result *string, prf_result_size uint64) *big.Int {
+	return block_to_bn(prf_result, prf_result_size)
+}
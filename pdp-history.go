@@ -0,0 +1,267 @@
+package gopdp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+/* The history database has one bucket per file ID inside the audits bucket.  Records are keyed by
+ * audit time in Unix nanoseconds followed by the bucket's sequence number, both u64 big-endian, so
+ * a cursor walks them in time order.  Only audits from 1970 on are recorded, since an earlier time
+ * would be negative and sort after every other.  Values are pdp_history_record JSON.
+ */
+var pdp_history_bucket = []byte("audits")
+
+const PDP_HISTORY_KEY_SIZE = 16
+
+/* pdp_history_record is the stored form of a PDP_audit_result. */
+type pdp_history_record struct {
+	FileID         string `json:"file_id"`
+	Endpoint       string `json:"endpoint,omitempty"`
+	KeyFingerprint string `json:"key_fingerprint"`
+	Time           int64  `json:"time"`
+	Latency        int64  `json:"latency_ns"`
+	Challenged     uint64 `json:"challenged"`
+	NumFileBlocks  uint64 `json:"numfileblocks"`
+	Passed         bool   `json:"passed"`
+	Error          string `json:"error,omitempty"`
+}
+
+/* Errors that keep their identity through the history database, so errors.Is works on results read back */
+var pdp_history_errors = []error{
+	ErrProofRejected, ErrKeyMismatch, ErrBlockCountMismatch, ErrNoBlockCount, ErrUnknownFile, ErrPermissionDenied,
+	ErrBadWireFormat, ErrBadChallenge, ErrMemoryBudget,
+}
+
+/* PDP_history_query selects audit records made from From up to, not including, To.  Zero fields do
+ * not restrict the query.
+ */
+type PDP_history_query struct {
+	FileID       string
+	From         time.Time
+	To           time.Time
+	FailuresOnly bool
+
+	/* Return at most Limit records, the most recent ones */
+	Limit int
+}
+
+/* PDPHistory is an embedded database of audit results.  It implements PDP_audit_recorder. */
+type PDPHistory struct {
+	db *bolt.DB
+}
+
+/* OpenPDPHistory: Opens or creates the history database at path.  Only one process may have it open. */
+func OpenPDPHistory(path string) (*PDPHistory, error) {
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(pdp_history_bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PDPHistory{db: db}, nil
+}
+
+func (history *PDPHistory) Close() error {
+	return history.db.Close()
+}
+
+/* RecordAudit stores result. */
+func (history *PDPHistory) RecordAudit(result *PDP_audit_result) error {
+	var record = pdp_history_record{
+		FileID:         result.FileID,
+		Endpoint:       result.Endpoint,
+		KeyFingerprint: hex.EncodeToString(result.KeyFingerprint),
+		Time:           result.Time.UnixNano(),
+		Latency:        int64(result.Latency),
+		Challenged:     result.Challenged,
+		NumFileBlocks:  result.NumFileBlocks,
+		Passed:         result.Passed,
+	}
+
+	if result.FileID == "" {
+		return errors.New("pdp: audit result has no file ID")
+	}
+	if record.Time < 0 {
+		return errors.New("pdp: audit result is dated before 1970")
+	}
+	if result.Err != nil {
+		record.Error = result.Err.Error()
+	}
+	value, err := json.Marshal(&record)
+	if err != nil {
+		return err
+	}
+
+	return history.db.Update(func(tx *bolt.Tx) error {
+		files, err := tx.Bucket(pdp_history_bucket).CreateBucketIfNotExists([]byte(result.FileID))
+		if err != nil {
+			return err
+		}
+		sequence, err := files.NextSequence()
+		if err != nil {
+			return err
+		}
+		return files.Put(history_key(record.Time, sequence), value)
+	})
+}
+
+/* Files returns the IDs of all files with recorded audits, sorted. */
+func (history *PDPHistory) Files() ([]string, error) {
+	var fileIDs []string
+
+	err := history.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(pdp_history_bucket).ForEach(func(name []byte, value []byte) error {
+			if value == nil {
+				fileIDs = append(fileIDs, string(name))
+			}
+			return nil
+		})
+	})
+
+	return fileIDs, err
+}
+
+/* Query returns the audits matching query, oldest first. */
+func (history *PDPHistory) Query(query *PDP_history_query) ([]*PDP_audit_result, error) {
+	var results []*PDP_audit_result
+	var fileIDs []string
+	var err error
+
+	if query.FileID != "" {
+		fileIDs = []string{query.FileID}
+	} else if fileIDs, err = history.Files(); err != nil {
+		return nil, err
+	}
+
+	err = history.db.View(func(tx *bolt.Tx) error {
+		for _, fileID := range fileIDs {
+			files := tx.Bucket(pdp_history_bucket).Bucket([]byte(fileID))
+			if files == nil {
+				continue
+			}
+			found, err := query_file(files, query)
+			if err != nil {
+				return err
+			}
+			results = append(results, found...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Time.Before(results[j].Time) })
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[len(results)-query.Limit:]
+	}
+
+	return results, nil
+}
+
+/* query_file: The records in one file's bucket matching query, oldest first.  With a limit, only the
+ * most recent Limit matches are kept.
+ */
+func query_file(files *bolt.Bucket, query *PDP_history_query) ([]*PDP_audit_result, error) {
+	var results []*PDP_audit_result
+	var end []byte
+
+	cursor := files.Cursor()
+	key, value := cursor.First()
+	if !query.From.IsZero() {
+		key, value = cursor.Seek(history_key(history_nanos(query.From), 0))
+	}
+	if !query.To.IsZero() {
+		end = history_key(history_nanos(query.To), 0)
+	}
+
+	for ; key != nil; key, value = cursor.Next() {
+		if end != nil && bytes.Compare(key, end) >= 0 {
+			break
+		}
+		result, err := decode_history_record(value)
+		if err != nil {
+			return nil, err
+		}
+		if query.FailuresOnly && result.Passed {
+			continue
+		}
+		results = append(results, result)
+		if query.Limit > 0 && len(results) > query.Limit {
+			results = results[1:]
+		}
+	}
+
+	return results, nil
+}
+
+func decode_history_record(value []byte) (*PDP_audit_result, error) {
+	var record pdp_history_record
+
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, err
+	}
+	fingerprint, err := hex.DecodeString(record.KeyFingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PDP_audit_result{
+		FileID:         record.FileID,
+		Endpoint:       record.Endpoint,
+		KeyFingerprint: fingerprint,
+		Time:           time.Unix(0, record.Time),
+		Latency:        time.Duration(record.Latency),
+		Challenged:     record.Challenged,
+		NumFileBlocks:  record.NumFileBlocks,
+		Passed:         record.Passed,
+		Err:            history_error(record.Error),
+	}, nil
+}
+
+/* history_error: Turns a stored error message back into an error, the package's own where it matches. */
+func history_error(message string) error {
+	if message == "" {
+		return nil
+	}
+	for _, err := range pdp_history_errors {
+		if err.Error() == message {
+			return err
+		}
+	}
+	return errors.New(message)
+}
+
+/* history_nanos: t in Unix nanoseconds, or 0 if it is before 1970, when no record can be. */
+func history_nanos(t time.Time) int64 {
+	if nanos := t.UnixNano(); nanos > 0 {
+		return nanos
+	}
+	return 0
+}
+
+/* history_key: The record key for an audit at time nanos, which must not be negative. */
+func history_key(nanos int64, sequence uint64) []byte {
+	var key = make([]byte, PDP_HISTORY_KEY_SIZE)
+
+	binary.BigEndian.PutUint64(key[0:8], uint64(nanos))
+	binary.BigEndian.PutUint64(key[8:16], sequence)
+
+	return key
+}
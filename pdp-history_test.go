@@ -0,0 +1,124 @@
+package gopdp
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryQuery(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "history.db")
+	var start = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	var failure = errors.New("pdp: remote hung up")
+
+	history, err := OpenPDPHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Audits of two files, a minute apart and interleaved; every third one fails */
+	for i := 0; i < 12; i++ {
+		result := &PDP_audit_result{
+			FileID:         []string{"a", "b"}[i%2],
+			Endpoint:       "grpc://store.example.com",
+			KeyFingerprint: []byte{byte(i)},
+			Time:           start.Add(time.Duration(i) * time.Minute),
+			Latency:        time.Duration(i) * time.Millisecond,
+			Challenged:     460,
+			NumFileBlocks:  1000,
+			Passed:         i%3 != 0,
+		}
+		if !result.Passed {
+			result.Err = ErrProofRejected
+			if i == 9 {
+				result.Err = failure
+			}
+		}
+		if err := history.RecordAudit(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	/* An audit dated before 1970 would sort after every other and is refused */
+	if err := history.RecordAudit(&PDP_audit_result{FileID: "a", Time: time.Unix(-1, 0)}); err == nil {
+		t.Fatal("recorded an audit dated before 1970")
+	}
+	history.Close()
+
+	/* Everything holds after a reopen */
+	if history, err = OpenPDPHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	minutes := func(results []*PDP_audit_result) []int {
+		var found []int
+		for _, result := range results {
+			found = append(found, int(result.Time.Sub(start)/time.Minute))
+		}
+		return found
+	}
+	for _, test := range []struct {
+		name  string
+		query PDP_history_query
+		want  []int
+	}{
+		{"all", PDP_history_query{}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"one file", PDP_history_query{FileID: "b"}, []int{1, 3, 5, 7, 9, 11}},
+		{"unknown file", PDP_history_query{FileID: "c"}, nil},
+		{"from", PDP_history_query{From: start.Add(9 * time.Minute)}, []int{9, 10, 11}},
+		{"to is exclusive", PDP_history_query{To: start.Add(3 * time.Minute)}, []int{0, 1, 2}},
+		{"from and to", PDP_history_query{From: start.Add(4 * time.Minute), To: start.Add(6 * time.Minute)}, []int{4, 5}},
+		{"from before 1970", PDP_history_query{From: time.Unix(-100, 0), To: start.Add(2 * time.Minute)}, []int{0, 1}},
+		{"to before 1970", PDP_history_query{To: time.Unix(-100, 0)}, nil},
+		{"failures", PDP_history_query{FailuresOnly: true}, []int{0, 3, 6, 9}},
+		{"most recent across files", PDP_history_query{Limit: 3}, []int{9, 10, 11}},
+		{"most recent failures", PDP_history_query{FailuresOnly: true, Limit: 2}, []int{6, 9}},
+		{"most recent of one file", PDP_history_query{FileID: "a", Limit: 2, To: start.Add(10 * time.Minute)}, []int{6, 8}},
+	} {
+		results, err := history.Query(&test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := minutes(results); len(got) != len(test.want) || (len(got) > 0 && !equal_ints(got, test.want)) {
+			t.Errorf("%s: got audits %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	/* Results read back keep their fields and the package's errors keep their identity */
+	results, err := history.Query(&PDP_history_query{FileID: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := results[0]
+	if first.FileID != "a" || first.Endpoint != "grpc://store.example.com" || first.KeyFingerprint[0] != 0 ||
+		!first.Time.Equal(start) || first.Challenged != 460 || first.NumFileBlocks != 1000 || first.Passed {
+		t.Fatalf("read back %+v", first)
+	}
+	if !errors.Is(first.Err, ErrProofRejected) {
+		t.Errorf("stored ErrProofRejected read back as %v", first.Err)
+	}
+	if results[2].Err != nil || results[2].Latency != 4*time.Millisecond {
+		t.Errorf("passed audit read back as %+v", results[2])
+	}
+	results, _ = history.Query(&PDP_history_query{FileID: "b", To: start.Add(10 * time.Minute), Limit: 1})
+	if len(results) != 1 || results[0].Err == nil || results[0].Err.Error() != failure.Error() ||
+		errors.Is(results[0].Err, ErrProofRejected) {
+		t.Errorf("other error read back as %v", results)
+	}
+
+	files, err := history.Files()
+	if err != nil || len(files) != 2 || files[0] != "a" || files[1] != "b" {
+		t.Errorf("files %v, %v", files, err)
+	}
+}
+
+func equal_ints(a []int, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
@@ -0,0 +1,288 @@
+package gopdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	PDP_HTTP_PREFIX = "/v1/files/"
+
+	/* Largest request or response body accepted; a JSON challenge or proof is well under 4KB */
+	PDP_HTTP_MAX_BODY = 64 << 10
+
+	PDP_HTTP_CONTENT_TYPE = "application/json"
+)
+
+/* The HTTP API, described in api/openapi.yaml:
+ *
+ *   GET  /v1/files/{id}        file metadata, pdp_file_info_json
+ *   POST /v1/files/{id}/prove  body: a sanitized challenge, pdp_challenge_json; returns pdp_proof_json
+ *
+ * Errors are returned as {"error": "..."} with a 4xx or 5xx status.  File IDs are path-escaped.
+ */
+
+type pdp_file_info_json struct {
+	NumBlocks      uint64 `json:"num_blocks"`
+	BlockSize      uint32 `json:"block_size"`
+	KeyFingerprint string `json:"key_fingerprint"`
+}
+
+type pdp_error_json struct {
+	Error string `json:"error"`
+}
+
+/* PDPHTTPServer serves a PDPServer over HTTP/JSON. */
+type PDPHTTPServer struct {
+	server *PDPServer
+}
+
+/* NewPDPHTTPServer: Returns an http.Handler for server.  Mount it at the root of the mux. */
+func NewPDPHTTPServer(server *PDPServer) *PDPHTTPServer {
+	return &PDPHTTPServer{server: server}
+}
+
+func (service *PDPHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var fileID string
+	var action string
+
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, PDP_HTTP_PREFIX) {
+		http_error(w, http.StatusNotFound, errors.New("pdp: not found"))
+		return
+	}
+	parts := strings.Split(path[len(PDP_HTTP_PREFIX):], "/")
+	if len(parts) > 2 || parts[0] == "" {
+		http_error(w, http.StatusNotFound, errors.New("pdp: not found"))
+		return
+	}
+	fileID, err := url.PathUnescape(parts[0])
+	if err != nil {
+		http_error(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		service.file_info(w, r, fileID)
+	case action == "prove" && r.Method == http.MethodPost:
+		service.prove(w, r, fileID)
+	case action == "":
+		w.Header().Set("Allow", http.MethodGet)
+		http_error(w, http.StatusMethodNotAllowed, errors.New("pdp: method not allowed"))
+	case action == "prove":
+		w.Header().Set("Allow", http.MethodPost)
+		http_error(w, http.StatusMethodNotAllowed, errors.New("pdp: method not allowed"))
+	default:
+		http_error(w, http.StatusNotFound, errors.New("pdp: not found"))
+	}
+}
+
+func (service *PDPHTTPServer) file_info(w http.ResponseWriter, r *http.Request, fileID string) {
+
+	info, err := service.server.FileInfo(request_context(r), fileID)
+	if err != nil {
+		http_error(w, http_status(err), err)
+		return
+	}
+
+	http_reply(w, &pdp_file_info_json{
+		NumBlocks:      info.NumBlocks,
+		BlockSize:      info.BlockSize,
+		KeyFingerprint: hex.EncodeToString(info.KeyFingerprint),
+	})
+}
+
+func (service *PDPHTTPServer) prove(w http.ResponseWriter, r *http.Request, fileID string) {
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, PDP_HTTP_MAX_BODY))
+	if err != nil {
+		http_error(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	proof, err := service.server.prove(request_context(r), fileID, func(numfileblocks uint64) (*PDP_challenge, error) {
+		return decode_pdp_challenge_json(body, service.server.key, numfileblocks)
+	})
+	if err != nil {
+		http_error(w, http_status(err), err)
+		return
+	}
+	response, err := encode_pdp_proof_json(proof)
+	if err != nil {
+		http_error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", PDP_HTTP_CONTENT_TYPE)
+	w.Write(response)
+}
+
+/* request_context: The request's context with the client's names and the caller's trace context. */
+func request_context(r *http.Request) context.Context {
+	return extract_http(with_client_cert(r.Context(), r.TLS), r.Header)
+}
+
+/* http_status: Maps the server's errors onto HTTP status codes, as grpc_status does for gRPC. */
+func http_status(err error) int {
+	var aborted *PDP_prove_aborted
+
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, ErrUnknownFile):
+		return http.StatusNotFound
+	case errors.Is(err, ErrBadWireFormat), errors.Is(err, ErrBadChallenge):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrMemoryBudget):
+		return http.StatusServiceUnavailable
+	case errors.As(err, &aborted) && aborted.Timeout():
+		return http.StatusGatewayTimeout
+	case errors.As(err, &aborted):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+func http_reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", PDP_HTTP_CONTENT_TYPE)
+	json.NewEncoder(w).Encode(v)
+}
+
+func http_error(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", PDP_HTTP_CONTENT_TYPE)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(&pdp_error_json{Error: err.Error()})
+}
+
+/* PDPHTTPClient audits files held by a remote PDP HTTP server.  It holds the verifier's private key. */
+type PDPHTTPClient struct {
+	core   *PDPCore
+	key    *PDP_key
+	base   string
+	client *http.Client
+}
+
+/* NewPDPHTTPClient: Returns a client auditing the server at baseURL (scheme and host, optionally a path
+ * prefix) with the verifier's key.  httpClient may be nil to use http.DefaultClient.
+ */
+func NewPDPHTTPClient(baseURL string, httpClient *http.Client, key *PDP_key) *PDPHTTPClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &PDPHTTPClient{
+		core:   NewPDPCore(),
+		key:    key,
+		base:   strings.TrimRight(baseURL, "/"),
+		client: httpClient,
+	}
+}
+
+/* Audit challenges fileID, which the verifier tagged with numfileblocks blocks, and verifies the proof.
+ * Returns nil if it verified, ErrProofRejected if not.
+ */
+func (client *PDPHTTPClient) Audit(ctx context.Context, fileID string, numfileblocks uint64) error {
+	return client.core.pdp_audit_remote(ctx, client.key, client, fileID, numfileblocks)
+}
+
+/* FileInfo implements PDP_remote. */
+func (client *PDPHTTPClient) FileInfo(ctx context.Context, fileID string) (*PDP_file_info, error) {
+	var response pdp_file_info_json
+
+	body, err := client.do(ctx, http.MethodGet, client.file_url(fileID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(body, &response); err != nil {
+		return nil, ErrBadWireFormat
+	}
+	fingerprint, err := hex.DecodeString(response.KeyFingerprint)
+	if err != nil {
+		return nil, ErrBadWireFormat
+	}
+
+	return &PDP_file_info{
+		NumBlocks:      response.NumBlocks,
+		BlockSize:      response.BlockSize,
+		KeyFingerprint: fingerprint,
+	}, nil
+}
+
+/* Prove implements PDP_remote. */
+func (client *PDPHTTPClient) Prove(ctx context.Context, fileID string, challenge *PDP_challenge) (*PDP_proof, error) {
+
+	request, err := encode_pdp_challenge_json(challenge)
+	if err != nil {
+		return nil, err
+	}
+	body, err := client.do(ctx, http.MethodPost, client.file_url(fileID)+"/prove", request)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode_pdp_proof_json(body, client.key)
+}
+
+func (client *PDPHTTPClient) file_url(fileID string) string {
+	return client.base + PDP_HTTP_PREFIX + url.PathEscape(fileID)
+}
+
+/* do: Sends one request and returns the response body, or an error built from the server's error reply.
+ * A 404 is returned as ErrUnknownFile and a 403 as ErrPermissionDenied.
+ */
+func (client *PDPHTTPClient) do(ctx context.Context, method string, target string, body []byte) ([]byte, error) {
+	var reader io.Reader
+
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", PDP_HTTP_CONTENT_TYPE)
+	inject_http(ctx, request.Header)
+	if body != nil {
+		request.Header.Set("Content-Type", PDP_HTTP_CONTENT_TYPE)
+	}
+
+	response, err := client.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, PDP_HTTP_MAX_BODY))
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrUnknownFile
+	}
+	if response.StatusCode == http.StatusForbidden {
+		return nil, ErrPermissionDenied
+	}
+	if response.StatusCode != http.StatusOK {
+		var reply pdp_error_json
+		if json.Unmarshal(data, &reply) != nil || reply.Error == "" {
+			reply.Error = http.StatusText(response.StatusCode)
+		}
+		return nil, fmt.Errorf("pdp: HTTP %d: %s", response.StatusCode, reply.Error)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,82 @@
+package gopdp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPAudit(t *testing.T) {
+	var ctx = context.Background()
+
+	key, server, path, data := serve_test_file(t, 25)
+	if err := server.AddFile("dir/data file", path, ""); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(NewPDPHTTPServer(server))
+	defer ts.Close()
+	client := NewPDPHTTPClient(ts.URL+"/", ts.Client(), key)
+
+	for _, fileID := range []string{"data", "dir/data file"} {
+		if err := client.Audit(ctx, fileID, 25); err != nil {
+			t.Fatalf("%q: %v", fileID, err)
+		}
+	}
+
+	if err := client.Audit(ctx, "data", 24); !errors.Is(err, ErrBlockCountMismatch) {
+		t.Fatalf("audit with another block count: %v", err)
+	}
+	if err := client.Audit(ctx, "other", 25); !errors.Is(err, ErrUnknownFile) {
+		t.Fatalf("audit of an unknown file: %v", err)
+	}
+	if err := NewPDPHTTPClient(ts.URL, ts.Client(), new_test_key(t)).Audit(ctx, "data", 25); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("audit under another key: %v", err)
+	}
+
+	corrupt_test_file(t, path, data)
+	if err := client.Audit(ctx, "data", 25); !errors.Is(err, ErrProofRejected) {
+		t.Fatalf("audit of a corrupted file: %v", err)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	_, server, _, _ := serve_test_file(t, 3)
+	ts := httptest.NewServer(NewPDPHTTPServer(server))
+	defer ts.Close()
+
+	for _, test := range []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "/v1/files/data", "", http.StatusOK},
+		{http.MethodGet, "/v1/files/other", "", http.StatusNotFound},
+		{http.MethodPost, "/v1/files/data", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/v1/files/data/prove", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/v1/files/data/prove", "{", http.StatusBadRequest},
+		{http.MethodPost, "/v1/files/data/prove", `{"c": 1}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/files/data/verify", "{}", http.StatusNotFound},
+		{http.MethodGet, "/v1/files/", "", http.StatusNotFound},
+		{http.MethodGet, "/v2/files/data", "", http.StatusNotFound},
+	} {
+		request, err := http.NewRequest(test.method, ts.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := ts.Client().Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != test.status {
+			t.Errorf("%s %s: status %d, want %d", test.method, test.path, response.StatusCode, test.status)
+		}
+		if response.Header.Get("Content-Type") != PDP_HTTP_CONTENT_TYPE {
+			t.Errorf("%s %s: content type %q", test.method, test.path, response.Header.Get("Content-Type"))
+		}
+	}
+}
@@ -32,859 +32,507 @@
 package gopdp
 
 import (
-	RSA "crypto/rsa"
+	"bytes"
 	"crypto/aes"
-	"crypto/cipher"
-	"encoding/hex"
-	openssl "github.com/spacemonkeygo/openssl"
+	"crypto/rand"
+	RSA "crypto/rsa"
+	"crypto/sha1"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
 	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/pbkdf2"
 )
 
 const (
 	PATH_PDP_USER_DIR    = ".pdp"
 	PATH_PDP_PRIVATE_KEY = ".pdp/pdp.pri"
 	PATH_PDP_PUBLIC_KEY  = ".pdp/pdp.pub"
+
+	/* The command line tool takes the passphrase of the private key from this environment variable if it is set */
+	PDP_PASSPHRASE_ENV = "PDP_PASSPHRASE"
+
+	/* PEM type of the PKCS#8 private key NIST-wrapped under the passphrase */
+	PDP_PRIVATE_KEY_PEM_TYPE = "PDP WRAPPED PRIVATE KEY"
+
+	/* PBKDF2 iterations deriving the key that wraps v */
+	PDP_PBKDF2_ITERATIONS = 10000
+
+	/* v is padded to 32 bytes before it is wrapped, which adds 8 bytes */
+	PDP_WRAPPED_V_SIZE = 40
 )
 
+/* The initial value A6A6A6A6A6A6A6A6 of the NIST key wrap */
+var nist_key_wrap_iv = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
 /* nist_key_wrap: Performs the NIST AES Key Wrap used to securely and authentically encrypt a key for storage
 * on an unstrusted medium, e.g. disk.
-* It takes in the key to be encrypted and its size in bytes (a minimum of 128 bits) and the key-encryption-key (kek) and
-* its size in bytes.  The kek must be 128, 192 or 256 bits.
-* Returns an allocted buffer containing the encrypted key, which will be key_size + 8 bytes in size.
+* It takes in the key to be encrypted (a multiple of 64 bits and a minimum of 128 bits) and the
+* key-encryption-key (kek).  The kek must be 128, 192 or 256 bits.
+* Returns an allocted buffer containing the encrypted key, which will be len(key) + 8 bytes in size.
  */
-func nist_key_wrap(key *string, key_size uint64, kek *string, kek_size uint64) *string {
-
-	aes_key := nil
-	var A string
-	var i uint = 0
-	var j uint = 0
-	var n uint = 0
-	var t uint = 0
-	var r_array *string
-	var c_array *string
-	var aes_input string
-	var aes_output string
-
-	if key == nil || kek == nil {
-		return nil
-	}
-
-	/* set n - the number of 64 bit values in key*/
-	n = (key_size / 8)
+func nist_key_wrap(key []byte, kek []byte) []byte {
+	var A [8]byte
+	var B [aes.BlockSize]byte
 
-	/* Set up the R array - n 64 bit blocks */
-	r_array = key[:n*8]
-
-	/* Convert string to byte */
-	kekHex, _ = hex.DecodeString(kek)
-	b := []byte(kekHex)
-
-	/* Setup the AES key */
-	aes_key, err = aes.NewCipher(b)
-	if err != nil {
+	if len(key) < 16 || len(key)%8 != 0 {
 		return nil
 	}
-
-	gcm, err := cipher.NewGCM(aes_key)
+	aes_key, err := aes.NewCipher(kek)
 	if err != nil {
 		return nil
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil
-	}
-
-	for j = 0; j < 6; j++ {
-		for i = 0; i < n; i++ {
-
-			/* Copy A into the first 64 bits of the input */
-			aes_input = A[:8]
+	/* set n - the number of 64 bit values in key */
+	n := len(key) / 8
 
-			/* Copy R_i into the second 64 bits of the input */
-			aes_input = aes_input + r_array[i*8:(i+1)*8]
+	/* Set up A and the R array - n 64 bit blocks */
+	copy(A[:], nist_key_wrap_iv)
+	r_array := append([]byte{}, key...)
 
-			/* encrypt  */
-			aes_output = gcm.Seal(nonce, nonce, aes_input, nil)
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			/* B = AES(A | R_i) */
+			copy(B[:8], A[:])
+			copy(B[8:], r_array[i*8:(i+1)*8])
+			aes_key.Encrypt(B[:], B[:])
 
-			/* Get the 64 most significant bits from the output and put them in A*/
-			A = aes_output[:8]
+			/* A gets the 64 most significant bits of B XOR t, where t = (n * j) + i */
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(A[:], binary.BigEndian.Uint64(B[:8])^t)
 
-			/* XOR A and t, where t = (n * j) + i */
-			t = ((n * j) + (i + 1))
-			A[7] = A[7] ^ t
-
-			/* R_i gets the least significat 64 bits of the aes_output */
-			r_array = r_array + aes_output[8:16]
+			/* R_i gets the least significat 64 bits of B */
+			copy(r_array[i*8:(i+1)*8], B[8:])
 		}
 	}
 
-	/* C_0 gets A */
-	c_array = A[:8]
-
-	/* C_i gets R_i */
-	c_array = c_array + r_array[:(8*n)]
-
-	return c_array
+	/* C_0 gets A, C_i gets R_i */
+	return append(A[:], r_array...)
 }
 
 /* nist_key_unwrap: Performs the NIST AES Key Wrap unwraping function used to securely and authentically decrypt a key
 * that has been wrapped.
-* It takes in the encrypted key to be decrypted and its size in bytes (which will be original key size plus 8 bytes)
-* and the key-encryption-key (kek) and its size in bytes.  The kek must be 128, 192 or 256 bits.
-* Returns an allocted buffer containing the decrypted key, which will be (enckey_size - 8) bytes in size.
+* It takes in the encrypted key to be decrypted (which will be original key size plus 8 bytes)
+* and the key-encryption-key (kek).  The kek must be 128, 192 or 256 bits.
+* Returns an allocted buffer containing the decrypted key, or nil if it does not unwrap under kek.
  */
-func nist_key_unwrap(enckey *string, enckey_size uint64, kek *string, kek_size uint64) *string {
-
-	aes_key := nil
-	var A string
-	var i uint = 0
-	var j uint = 0
-	var n uint = 0
-	var t uint = 0
-	var r_array *string
-	var c_array *string
-	var aes_input string
-	var aes_output string
-
-	if key == nil || kek == nil {
+func nist_key_unwrap(enckey []byte, kek []byte) []byte {
+	var A [8]byte
+	var B [aes.BlockSize]byte
+
+	if len(enckey) < 24 || len(enckey)%8 != 0 {
+		return nil
+	}
+	aes_key, err := aes.NewCipher(kek)
+	if err != nil {
 		return nil
 	}
 
-	/* set n - the number of 64 bit values in key*/
-	n = (enckey_size / 8) - 1
+	/* set n - the number of 64 bit values in key */
+	n := len(enckey)/8 - 1
 
-	/* Convert string to byte */
-	kekHex, _ = hex.DecodeString(kek)
-	enc, _ = hex.DecodeString(enckey)
+	/* Initialize A and the R array */
+	copy(A[:], enckey[:8])
+	r_array := append([]byte{}, enckey[8:]...)
+
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			/* B = AES-1((A XOR t) | R_i), where t = (n * j) + i */
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(B[:8], binary.BigEndian.Uint64(A[:])^t)
+			copy(B[8:], r_array[i*8:(i+1)*8])
+			aes_key.Decrypt(B[:], B[:])
+
+			/* A gets the 64 most significant bits of B, R_i the least significant */
+			copy(A[:], B[:8])
+			copy(r_array[i*8:(i+1)*8], B[8:])
+		}
+	}
 
-	/* Setup the AES key */
-	block, err = aes.NewCipher(kekHex)
-	if err != nil {
+	/* The key is authentic if A is the initial value again */
+	if subtle.ConstantTimeCompare(A[:], nist_key_wrap_iv) != 1 {
 		return nil
 	}
 
-	aesGCM, err := cipher.NewGCM(block)
+	/* P_i gets R_i */
+	return r_array
+}
+
+/* PDP_password_func asks the user prompt, for a passphrase or a y/N answer, and returns the reply or ""
+ * on failure.  The key store never reads the terminal itself; the command line tool supplies one.
+ */
+type PDP_password_func func(prompt string) string
+
+/* wrap_pdp_private_key: Encodes the RSA key as PKCS#8, pads it with zeros to a multiple of 64 bits and
+*  NIST-wraps it under the passphrase-derived key dk.  Returns the wrapped key or nil on failure.
+ */
+func wrap_pdp_private_key(rsa *RSA.PrivateKey, dk []byte) []byte {
+
+	der, err := x509.MarshalPKCS8PrivateKey(rsa)
 	if err != nil {
 		return nil
 	}
+	if len(der)%8 != 0 {
+		der = append(der, make([]byte, 8-len(der)%8)...)
+	}
 
-	nonceSize := aesGCM.NonceSize()
-	nonce, ciphertext := enc[:nonceSize], enc[nonceSize:]
+	return nist_key_wrap(der, dk)
+}
 
-	/* Initialize A and the R array */
-	A = enckey[:8]
-	r_array = enckey[8 : n*8]
+/* unwrap_pdp_private_key: Unwraps a key written by wrap_pdp_private_key and strips the padding after
+*  the PKCS#8 structure.  Returns the RSA key, with its CRT values precomputed, or nil on failure.
+ */
+func unwrap_pdp_private_key(wrapped []byte, dk []byte) *RSA.PrivateKey {
+	var raw asn1.RawValue
 
-	for j = 5; j >= 0; j-- {
-		for i = (n - 1); i >= 0; i-- {
+	der := nist_key_unwrap(wrapped, dk)
+	if der == nil {
+		return nil
+	}
+	padding, err := asn1.Unmarshal(der, &raw)
+	if err != nil || len(padding) >= 8 || !bytes.Equal(padding, make([]byte, len(padding))) {
+		return nil
+	}
+	/* ParsePKCS8PrivateKey validates the key and precomputes its CRT values */
+	parsed, err := x509.ParsePKCS8PrivateKey(raw.FullBytes)
+	if err != nil {
+		return nil
+	}
+	rsa, ok := parsed.(*RSA.PrivateKey)
+	if !ok {
+		return nil
+	}
 
-			/* XOR A and t, where t = (n * j) + i */
-			t = ((n * j) + (i + 1))
-			A[7] = A[7] ^ t
+	return rsa
+}
 
-			/* Copy A XOR t into the first 64 bits of the input */
-			aes_input = A[:8]
+/* pdp_key_paths: Returns the paths of the private and public key files in the user's home directory. */
+func pdp_key_paths() (string, string, error) {
 
-			/* Copy R_i into the second 64 bits of the input */
-			aes_input = aes_input + r_array[i*8+(i+1)*8]
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
 
-			/* AES-1(A | R_i) */
-			aes_output, err = aesGCM.Open(nil, nonce, aes_input, nil)
-			if err != nil {
-				return nil
-			}
+	return filepath.Join(home, PATH_PDP_PRIVATE_KEY), filepath.Join(home, PATH_PDP_PUBLIC_KEY), nil
+}
 
-			/* Get the 64 most significant bits from the output and put them in A*/
-			A = aes_output[:8]
+/* read_pdp_pubkey: Parses a public key file, a PEM encoded RSA public key followed by the size of the
+*  generator (64 bits, little endian) and the raw BIGNUM generator.
+*  Returns an allocated PDP_key with only the public components or nil on failure.
+ */
+func read_pdp_pubkey(pub_key []byte) *PDP_key {
 
-			/* R_i gets the least significat 64 bits of the aes_output */
-			r_array = r_array + aes_output[8:16]
-		}
+	block, rest := pem.Decode(pub_key)
+	if block == nil || block.Type != "RSA PUBLIC KEY" {
+		return nil
+	}
+	public, err := x509.ParsePKCS1PublicKey(block.Bytes)
+	if err != nil {
+		return nil
 	}
 
-	/* P_i gets R_i */
-	p_array = r_array[:8*n]
-
-	return p_array
+	/* Retreive the generator */
+	if len(rest) < 8 {
+		return nil
+	}
+	gen_size := binary.LittleEndian.Uint64(rest[:8])
+	rest = rest[8:]
+	if gen_size == 0 || gen_size != uint64(len(rest)) {
+		return nil
+	}
+	g := new(big.Int).SetBytes(rest)
+	if in_z_star_n(&PDP_key{rsa: &RSA.PrivateKey{PublicKey: *public}}, g) == 0 {
+		return nil
+	}
 
+	return &PDP_key{rsa: &RSA.PrivateKey{PublicKey: *public}, g: g}
 }
 
-/* read_password: Display the prompt and read a password off the terminal with echo off into the
-*  buffer, buf.  Return a pointer to the password or NULL on failure.  */
-// func read_password(prompt *string, buf *string, bufsize uint64) *string{
-
-// 	int termfd = -1;
-// 	sigset_t saved_signals;
-// 	sigset_t set_signals;
-// 	unsigned char *password = NULL;
-// 	struct termios saved_term;
-// 	struct termios set_term;
-// 	char ch = 0;
-// 	int ctr = 0;
-	
-// 	if(buf)
-// 		password = buf;
-// 	else{
-// 		if( ((password = malloc(1024)) == NULL)) goto cleanup;
-// 		bufsize = 1024;
-// 		memset(password, 0, 1024);
-// 	}
-	
-// 	/* Open the terminal */
-// 	term = os.Open()fopen(_PATH_TTY, "r+");
-// 	if(!term) return NULL;
-	
-// 	/* Get the file descriptor */
-// 	termfd = fileno(term);
-	
-// 	/* Display the prompt */
-// 	if(prompt){
-// 		fprintf(term, "%s", prompt);
-// 		fflush(term);
-// 	}
-	
-// 	/* Turn off interuption */
-// 	sigemptyset(&set_signals);
-// 	sigaddset(&set_signals, SIGINT);
-// 	sigaddset(&set_signals, SIGTSTP);
-// 	sigprocmask(SIG_BLOCK, &set_signals, &saved_signals);
-	
-// 	/* Save state and turn off echo */
-// 	tcgetattr(termfd, &saved_term);
-// 	set_term = saved_term;
-// 	set_term.c_lflag &= ~(ECHO|ECHOE|ECHOK|ECHONL);
-// 	tcsetattr(termfd, TCSAFLUSH, &set_term);
-	
-// 	/* Get the password off the terminal */
-// 	for(;;){
-// 		if(ctr >= bufsize) goto cleanup;
-// 		switch(read(termfd, &ch, 1)){
-// 			case 1:
-// 				if(ch != '\n') break;
-// 			case 0:
-// 				password[ctr] = '\0';
-// 				goto done;
-// 			default:
-// 				goto cleanup;
-// 		}
-// 		password[ctr] = ch;
-// 		ctr++;
-// 	}
-
-// done:
-// 	fprintf(term, "\n");
-
-// 	/* Re-set the state of the term */
-// 	tcsetattr(termfd, TCSAFLUSH, &saved_term);
-// 	sigprocmask(SIG_SETMASK, &saved_signals, 0);
-	
-// 	if(term) fclose(term);
-
-// 	return password;
-	
-// cleanup:
-// 	if(password && !buf) sfree(password, 1024);
-// 	if(term) fclose(term);
-	
-// 	return NULL;
-// }
-  
-
-/* PBKDF2_F: A support function for PBKDF2 below.  See the PKCS5 specification for details */
-// PBKDF2_F(unsigned char *T, unsigned char *password, size_t password_len, unsigned char *salt, size_t salt_len, int c, int i) int{
-
-// 	unsigned char U[SHA_DIGEST_LENGTH];
-// 	unsigned char *U1 = NULL;
-// 	unsigned int swapped_i = 0;
-// 	unsigned int U_len = 0;
-// 	int j = 0;
-// 	int k = 0;
-	
-// 	if(!T || !password || !password_len || !salt || !salt_len || !c) return 0;
-	
-// 	if( ((U1 = malloc(salt_len + sizeof(unsigned int))) == NULL)) return 0;
-	
-// 	memset(U, 0, SHA_DIGEST_LENGTH);
-// 	memset(U1, 0, salt_len + sizeof(int));
-	
-// 	/* Cat the salt and swapped byte-order of i */
-// 	swapped_i = htonl(i);
-// 	memcpy(U1, salt, salt_len);
-// 	memcpy(U1 + salt_len, &swapped_i, sizeof(unsigned int));
-	
-// 	/* Perform the initial PRF, U_1 = PRF(P, S | i) */
-// 	HMAC(EVP_sha1(), password, password_len, U1, salt_len + sizeof(unsigned int), U, &U_len);
-// 	if(U_len != SHA_DIGEST_LENGTH) goto cleanup;
-	
-// 	for(j = 0; j < c; j++){
-// 		/* XOR the last value of U into T, the final U value */
-// 		for(k = 0; k < SHA_DIGEST_LENGTH; k++) T[k] ^= U[k];
-// 		/* U_i = PRF(P, U_i-c) */
-// 		HMAC(EVP_sha1(), password, password_len, U, SHA_DIGEST_LENGTH, U, &U_len);
-// 		if(U_len != SHA_DIGEST_LENGTH) goto cleanup;
-// 	}
-// 	/* Perform the final XOR */
-// 	for(k = 0; k < SHA_DIGEST_LENGTH; k++) T[k] ^= U[k];
-	
-// 	if(U1) sfree(U1, salt_len + sizeof(unsigned int));
-	
-// 	return 1;
-
-// cleanup:
-// 	if(U1) sfree(U1, salt_len + sizeof(unsigned int));
-// 	return 0;
-// }
-
-/* PBKDF2: The PKCS5-based password-based key derivation function.  It takes a password, salt, iteration count and
-* desired key length, and returns a password-derived key of dkey_len size or NULL on error */
-// static unsigned char *PBKDF2(unsigned char *password, size_t password_len, unsigned char *salt, size_t salt_len, unsigned int c, size_t dkey_len){
-
-// 	unsigned int l = 0;
-// 	unsigned int r = 0;
-// 	unsigned char *dk = NULL;
-// 	unsigned char T[SHA_DIGEST_LENGTH];
-// 	size_t remaining_bytes = dkey_len;
-// 	int i = 0;
-
-// 	if(!password || !password_len || !salt || !salt_len || !c || !dkey_len) return NULL;
-
-// 	if ( ((dk = malloc(dkey_len)) == NULL)) return NULL;
-
-// 	memset(dk, 0, dkey_len);
-// 	memset(T, 0, SHA_DIGEST_LENGTH);
-
-// 	/* derive l, the number of SHA blocks in the derived key */
-// 	l = (dkey_len/SHA_DIGEST_LENGTH);
-// 	if(dkey_len%SHA_DIGEST_LENGTH) l++;
-	
-// 	/* derive r, the number of bytes in the last block */
-// 	r = dkey_len - ((l - 1) * SHA_DIGEST_LENGTH);
-	
-	
-// 	/* Compute T_i */
-// 	for(i = 0; i < l; i++){
-		
-// 		if(!PBKDF2_F(T, password, password_len, salt, salt_len, c, i)) goto cleanup;
-		
-// 		/* Add T_i to the derived key */
-// 		if(remaining_bytes >= SHA_DIGEST_LENGTH){
-// 			memcpy(dk + (i * SHA_DIGEST_LENGTH), T, SHA_DIGEST_LENGTH);
-// 			remaining_bytes -= SHA_DIGEST_LENGTH;
-// 		}else{
-// 			memcpy(dk + (i * SHA_DIGEST_LENGTH), T, remaining_bytes);
-// 			remaining_bytes -= remaining_bytes;
-// 		}
-// 	}
-	
-// 	return dk;
-	
-// cleanup:
-// 	if(dk) sfree(dk, dkey_len);
-
-// 	return NULL;
-// }
-
-
 /* read_pdp_keypair: Read a PDP-keypair from a file and return a PDP_key structure.
- * Takes in two open file pointers to the private and public keys.
- * Returns an allocated PDP_key or NULL on failure.
-*/
-func read_pdp_keypair(pri_key *File, pub_key *File) *PDP_key{
-
+ * Takes in the contents of the private and public key files and the user's passphrase.  The private
+ * key is a PKCS#8 key NIST-wrapped under the passphrase, see wrap_pdp_private_key; a key pair written
+ * before that, with a legacy encrypted PKCS#1 PEM block, is still read so files tagged under it stay
+ * verifiable, but is never written.
+ * Returns an allocated PDP_key or nil on failure.
+ */
+func read_pdp_keypair(pri_key []byte, pub_key []byte, password string) *PDP_key {
 	var key *PDP_key
-	var r1 *big.Int
-	var r2 *big.Int
-	var pkey pKey
-	var salt *string
-	var dk *string /* Derived key */
-	var enc_v *string
-	var gen_size uint64 = 0;
-	var gen *string
-	var password_len uint = 1024;
-	var password string
-	var key_v *string
-	var rsa RSA.PrivateKey
-	
-	if pri_key == nil || pub_key == nil {
+	var rsa *RSA.PrivateKey
+
+	/* Read in the public key and generator */
+	key = read_pdp_pubkey(pub_key)
+	if key == nil {
 		return nil
 	}
-	
-	password="z"
-	
-	pkey = C.PEM_read_PrivateKey(pri_key, NULL, NULL, password)
-	if pkey == nil {
+
+	block, rest := pem.Decode(pri_key)
+	if block == nil {
 		return nil
 	}
 
-	key->rsa = EVP_PKEY_get1_RSA(pkey);
-	if(!key->rsa) goto cleanup;
-	
-	if(!RSA_check_key(key->rsa)) goto cleanup;
-	
-	/* Get prf key v */
-	fread(salt, PRF_KEY_SIZE, 1, pri_key);
-	if(ferror(pri_key)) goto cleanup;
-	fread(enc_v, 40, 1, pri_key);
-	if(ferror(pri_key)) goto cleanup;
+	/* Get the salt and prf key v */
+	if len(rest) != PRF_KEY_SIZE+PDP_WRAPPED_V_SIZE {
+		return nil
+	}
+	salt, enc_v := rest[:PRF_KEY_SIZE], rest[PRF_KEY_SIZE:]
 
 	/* Generate a password-based key using PKCS5-PBKDF2 */
-	dk = PBKDF2((unsigned char *)password, strlen(password), salt, PRF_KEY_SIZE, 10000, PRP_KEY_SIZE);
-	if(!dk) goto cleanup;
-
-	/* Clear out password from memory */
-	memset(password, 0, password_len);
+	dk := pbkdf2.Key([]byte(password), salt, PDP_PBKDF2_ITERATIONS, PRP_KEY_SIZE, sha1.New)
+
+	switch block.Type {
+	case PDP_PRIVATE_KEY_PEM_TYPE:
+		rsa = unwrap_pdp_private_key(block.Bytes, dk)
+	case "RSA PRIVATE KEY":
+		der, err := x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return nil
+		}
+		/* ParsePKCS1PrivateKey validates the key and precomputes its CRT values */
+		if rsa, err = x509.ParsePKCS1PrivateKey(der); err != nil {
+			return nil
+		}
+	}
+	if rsa == nil || rsa.N.Cmp(key.rsa.N) != 0 || rsa.E != key.rsa.E {
+		return nil
+	}
+	key.rsa = rsa
 
 	/* NIST-unwrap and strip the padding off of the symetric key, v */
-	key_v = nist_key_unwrap(enc_v, 40, dk, PRP_KEY_SIZE);
-	if(!key_v) goto cleanup;
-
-	memcpy(key->v, key_v, PRF_KEY_SIZE);
-		
-	/* Read in the public key */
-	rsa = PEM_read_RSAPublicKey(pub_key, NULL, NULL, NULL);
-	if(!rsa) goto cleanup;
-	RSA_free(rsa);
-	
-	/* Retreive the generator */
-	fread(&gen_size, sizeof(size_t), 1, pub_key);
-	if( ((gen = malloc(gen_size)) == NULL)) goto cleanup;
-	fread(gen, gen_size, 1, pub_key);
-	if(!BN_bin2bn(gen, gen_size, key->g)) goto cleanup;
-
-	if(r1) BN_clear_free(r1);
-	if(r2) BN_clear_free(r2);
-	if(ctx) BN_CTX_free(ctx);
-	if(pkey) EVP_PKEY_free(pkey);
-	if(dk) sfree(dk, PRP_KEY_SIZE);
-	if(salt) sfree(salt, PRF_KEY_SIZE);
-	if(enc_v) sfree(enc_v, 40);
-	if(key_v) sfree(key_v, PRF_KEY_SIZE);
-	if(gen) sfree(gen, gen_size);
-	
-	return key;
-
-cleanup:
-	memset(password, 0, password_len);
-	if(key) destroy_pdp_key(key);
-	if(r1) BN_clear_free(r1);
-	if(r2) BN_clear_free(r2);
-	if(ctx) BN_CTX_free(ctx);
-	if(pkey) EVP_PKEY_free(pkey);
-	if(dk) sfree(dk, PRP_KEY_SIZE);
-	if(salt) sfree(salt, PRF_KEY_SIZE);
-	if(enc_v) sfree(enc_v, 40);
-	if(key_v) sfree(key_v, PRF_KEY_SIZE);
-	if(gen) sfree(gen, gen_size);
-	
-	return NULL;
+	key_v := nist_key_unwrap(enc_v, dk)
+	if key_v == nil {
+		return nil
+	}
+	v := string(key_v[:PRF_KEY_SIZE])
+	key.v = &v
+
+	return key
 }
 
 /* write_pdp_keypair: writes a PDP_key structure to disk.
-*  Takes in a populated PDP_key and the user's passphrase and writes a PEM-PKCS8 encoded private key
-*  and NIST-wrapped symmetric key to the private key file and a PEM encoded and raw BIGNUM generator to the
-*  public key file
+*  Takes in a populated PDP_key and the user's passphrase and writes a PEM encoded PKCS#8 private key and
+*  the symmetric key, both NIST-wrapped under a PBKDF2 key of the passphrase, to the private key file and
+*  a PEM encoded public key and raw BIGNUM generator to the public key file.
 *  returns 1 on success, 0 on failure.
-*/
-int write_pdp_keypair(PDP_key *key, char *password){
-
-	FILE *pub_key = NULL;
-	FILE *pri_key = NULL;
-	struct passwd *pw = NULL;
-	char pdpkeypath[MAXPATHLEN];
-	EVP_PKEY *pkey = NULL;
-	unsigned char *salt = NULL;
-	unsigned char *dk = NULL;
-	unsigned char *enc_v = NULL;
-	size_t gen_size = 0;
-	unsigned char *gen = NULL;
-	unsigned char key_v[32];
-	
-	if(!key || !password) return 0;
-	
-	if( ((pw = getpwuid(getuid())) == NULL) ) goto cleanup;
-	if( ((pkey = EVP_PKEY_new()) == NULL) ) goto cleanup;
-	if( ((salt = malloc(PRF_KEY_SIZE)) == NULL)) goto cleanup;
-		
-	memset(pdpkeypath, 0, MAXPATHLEN);
-	memset(salt, 0, PRF_KEY_SIZE);
-	memset(key_v, 0, 32);
-	
-	/* Create ~/.pdp directory if it doesn't already exist. */
-	snprintf(pdpkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_USER_DIR);
-	if( (access(pdpkeypath, F_OK) != 0)){
-		if (mkdir(pdpkeypath, 0700) < 0){
-			fprintf(stderr, "Could not create directory '%s'.\n", pdpkeypath);
-			goto cleanup;
-		}
+ */
+func write_pdp_keypair(key *PDP_key, password string) int {
+	var pri_key []byte
+	var pub_key []byte
+	var key_v [32]byte
+	var gen_size [8]byte
+
+	if key == nil || key.rsa == nil || key.v == nil || key.g == nil || password == "" {
+		return 0
+	}
+	pri_path, pub_path, err := pdp_key_paths()
+	if err != nil {
+		return 0
 	}
 
-	/* Open, create and truncate the key files */
-	if( snprintf(pdpkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PRIVATE_KEY) < 0 ) goto cleanup;
-	pri_key = fopen(pdpkeypath, "w");
-	if(!pri_key) goto cleanup;
-	
-	if( snprintf(pdpkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PUBLIC_KEY) < 0 ) goto cleanup;
-	pub_key = fopen(pdpkeypath, "w");
-	if(!pub_key) goto cleanup;
-
-	/* Turn RSA key into an EVP key and write it in PKCS8 password-protected format */
-	if(!EVP_PKEY_set1_RSA(pkey, key->rsa)) goto cleanup;
-	if(!PEM_write_PKCS8PrivateKey(pri_key, pkey, EVP_aes_256_cbc(), NULL, 0, 0, password)){
-		fprintf(stderr, "Did not write private key\n");
-		 goto cleanup;
+	/* Create ~/.pdp directory if it doesn't already exist. */
+	if err := os.MkdirAll(filepath.Dir(pri_path), 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create directory '%s'.\n", filepath.Dir(pri_path))
+		return 0
 	}
 
 	/* Generate some random bytes for a salt */
-	if(!RAND_bytes(salt, PRF_KEY_SIZE)) goto cleanup;
+	salt, err := GenerateRandomBytes(PRF_KEY_SIZE)
+	if err != nil {
+		return 0
+	}
 	/* Generate a password-based key using PKCS5-PBKDF2 */
-	dk = PBKDF2((unsigned char *)password, strlen(password), salt, PRF_KEY_SIZE, 10000, PRP_KEY_SIZE);
-	if(!dk) goto cleanup;
+	dk := pbkdf2.Key([]byte(password), salt, PDP_PBKDF2_ITERATIONS, PRP_KEY_SIZE, sha1.New)
+
+	/* Write the RSA key as PKCS#8 in PEM format, NIST-wrapped under the password */
+	enc_rsa := wrap_pdp_private_key(key.rsa, dk)
+	if enc_rsa == nil {
+		return 0
+	}
+	pri_key = pem.EncodeToMemory(&pem.Block{Type: PDP_PRIVATE_KEY_PEM_TYPE, Bytes: enc_rsa})
 
 	/* Pad and NIST-wrap the symetric key v */
-	memcpy(key_v, key->v, PRF_KEY_SIZE);
-	enc_v = nist_key_wrap(key_v, 32, dk, PRP_KEY_SIZE);
-	if(!enc_v) goto cleanup;
-	
+	copy(key_v[:], *key.v)
+	enc_v := nist_key_wrap(key_v[:], dk)
+	if enc_v == nil {
+		return 0
+	}
+
 	/* Write the salt and encypted value of v */
-	fwrite(salt, PRF_KEY_SIZE, 1, pri_key);
-	if(ferror(pri_key)) goto cleanup;
-	fwrite(enc_v, 40, 1, pri_key);
-	if(ferror(pri_key)) goto cleanup;
-	
-	/* Write the public key */
-	if(!PEM_write_RSAPublicKey(pub_key, key->rsa)) goto cleanup;
-
-	/* Write the generator */
-	gen_size = BN_num_bytes(key->g);
-	fwrite(&gen_size, sizeof(size_t), 1, pub_key);
-	if( ((gen = malloc(gen_size)) == NULL)) goto cleanup;
-	memset(gen, 0, gen_size);
-	if(!BN_bn2bin(key->g, gen)) goto cleanup;
-	fwrite(gen, gen_size, 1, pub_key);
-
-	endpwent();
-	if(pri_key) fclose(pri_key);
-	if(pub_key) fclose(pub_key);
-	if(pkey) EVP_PKEY_free(pkey);
-	if(dk) sfree(dk, PRP_KEY_SIZE);
-	if(salt) sfree(salt, PRF_KEY_SIZE);
-	if(enc_v) sfree(enc_v, PRF_KEY_SIZE + 8);
-	if(gen) sfree(gen, gen_size);
-	
-	return 1;
-	
-cleanup:
-	fprintf(stderr, "ERROR: Did not create key pair.\n");
-	endpwent();
-	if(pri_key) fclose(pri_key);
-	if( snprintf(pdpkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PRIVATE_KEY) < 0 ) goto cleanup;
-	unlink(pdpkeypath);
-	if(pub_key) fclose(pub_key);
-	if( snprintf(pdpkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PUBLIC_KEY) < 0 ) goto cleanup;
-	unlink(pdpkeypath);
-	if(pkey) EVP_PKEY_free(pkey);
-	if(dk) sfree(dk, PRP_KEY_SIZE);
-	if(salt) sfree(salt, PRF_KEY_SIZE);
-	if(enc_v) sfree(enc_v, PRF_KEY_SIZE + 8);
-	if(gen) sfree(gen, gen_size);
-		
-	return 0;
+	pri_key = append(pri_key, salt...)
+	pri_key = append(pri_key, enc_v...)
+
+	/* Write the public key and the generator */
+	pub_key = pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.rsa.PublicKey)})
+	binary.LittleEndian.PutUint64(gen_size[:], uint64(len(key.g.Bytes())))
+	pub_key = append(pub_key, gen_size[:]...)
+	pub_key = append(pub_key, key.g.Bytes()...)
+
+	if ioutil.WriteFile(pri_path, pri_key, 0600) != nil {
+		os.Remove(pri_path)
+		return 0
+	}
+	if ioutil.WriteFile(pub_path, pub_key, 0644) != nil {
+		os.Remove(pri_path)
+		os.Remove(pub_path)
+		return 0
+	}
+
+	return 1
 }
 
-/* pdp_create_new_keypair: Generates and writes a new PDP key pair to disk.
-*  returns an allocated and populated PDP_key structure or NULL on failure.
-*/
-PDP_key *pdp_create_new_keypair(){
-
-	PDP_key *key = NULL;
-	size_t password_len = 1024;
-	char password1[password_len];
-	char password2[password_len];
-	int got_password = 0;
-	char prikeypath[MAXPATHLEN];
-	char pubkeypath[MAXPATHLEN];
-	char yesorno = 0;
-	struct passwd *pw = NULL;
-		
-	memset(password1, 0, password_len);
-	memset(password2, 0, password_len);
-	memset(prikeypath, 0, MAXPATHLEN);
-	memset(pubkeypath, 0, MAXPATHLEN);
-	
-	if( ((pw = getpwuid(getuid())) == NULL) ) goto cleanup;
-	
-	snprintf(prikeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PRIVATE_KEY);
-	snprintf(pubkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PUBLIC_KEY);
-	if( (access(prikeypath, F_OK) == 0) && (access(pubkeypath, F_OK) == 0)){
-		fprintf(stdout, "WARNING: A PDP key pair already exists.  Creating a new key pair\n");
-		fprintf(stdout, "will make any previously tagged files unverifiable.\n");
-		fprintf(stdout, "Are you sure you want to continue? (y/N) ");
-		scanf("%c", &yesorno);
-		if(yesorno != 'y') goto cleanup;
-	}
-
-	fprintf(stdout, "Generating a new PDP key pair.\n");
-	
+/* pdp_create_new_keypair: Generates and writes a new PDP key pair to disk, asking read_password for the
+*  passphrase.
+*  returns an allocated and populated PDP_key structure or nil on failure.
+ */
+func pdp_create_new_keypair(read_password PDP_password_func) *PDP_key {
+	var key *PDP_key
+	var password string
+
+	pri_path, pub_path, err := pdp_key_paths()
+	if err != nil {
+		return nil
+	}
+	_, pri_err := os.Stat(pri_path)
+	_, pub_err := os.Stat(pub_path)
+	if pri_err == nil && pub_err == nil {
+		fmt.Fprintf(os.Stderr, "WARNING: A PDP key pair already exists.  Creating a new key pair\n")
+		fmt.Fprintf(os.Stderr, "will make any previously tagged files unverifiable.\n")
+		if read_password("Are you sure you want to continue? (y/N) ") != "y" {
+			return nil
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Generating a new PDP key pair.\n")
+
 	/* Get a passphrase from the user */
-	do{
-		if(!read_password("Enter passphrase:", (unsigned char *)password1, password_len)) goto cleanup;
-		if(!read_password("Re-enter passphrase:", (unsigned char *)password2, password_len)) goto cleanup;
-	
-		if (strcmp(password1, password2) != 0) {
-			/* Passwords don't match.  Clear them and try again */
-			memset(password1, 0, password_len);
-			memset(password2, 0, password_len);
-			fprintf(stdout, "Passphrases do not match.  Try again.\n");
-		}else
-			got_password = 1;
-	}while(!got_password);
-		
-	/* Got the password, clear the second one */
-	memset(password2, 0, password_len);
-	
+	for {
+		password = read_password("Enter passphrase:")
+		if password == "" {
+			return nil
+		}
+		if read_password("Re-enter passphrase:") == password {
+			break
+		}
+		/* Passwords don't match, try again */
+		fmt.Fprintf(os.Stderr, "Passphrases do not match.  Try again.\n")
+	}
+
 	/* Create a new set of PDP keys */
-	key = generate_pdp_key();
-	if(!key) goto cleanup;
+	key = generate_pdp_key()
+	if key == nil {
+		return nil
+	}
 
 	/* Write the new keys to disk */
-	if(!write_pdp_keypair(key, password1)) goto cleanup;
-
-	/* Kill the password from memory */
-	memset(password1, 0, password_len);
-	endpwent();
-
-	fprintf(stdout, "Your PDP keys have been stored.\n");
-
-	return key;
-	
-cleanup:
-	fprintf(stderr, "ERROR: Unable to create PDP key pair.\n");
-	endpwent();
-	memset(password1, 0, password_len);
-	memset(password2, 0, password_len);
-	if(key) destroy_pdp_key(key);
-	return NULL;
+	if write_pdp_keypair(key, password) == 0 {
+		fmt.Fprintf(os.Stderr, "ERROR: Unable to create PDP key pair.\n")
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Your PDP keys have been stored.\n")
+
+	return key
 }
 
 /* pdp_get_keypair: Returns an allocated PDP_key structure containing the private and public keys.
-* Keys are read from the private and public key files on disk.
-* Returns NULL on failure.
-*/
-PDP_key *pdp_get_keypair(){
-
-	PDP_key *key = NULL;
-	struct passwd *pw = NULL;
-	char pdpkeypath[MAXPATHLEN];
-	FILE *pri_key = NULL;
-	FILE *pub_key = NULL;
-	char yesorno = 0;
-
-	if( ((pw = getpwuid(getuid())) == NULL) ) goto cleanup;
-	
-	memset(pdpkeypath, 0, MAXPATHLEN);
-	
-	/* Create the paths to the PDP keys */
-	if( snprintf(pdpkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PRIVATE_KEY) < 0 ) goto cleanup;
-	pri_key = fopen(pdpkeypath, "r");
-	if( snprintf(pdpkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PUBLIC_KEY) < 0 ) goto cleanup;
-	pub_key = fopen(pdpkeypath, "r");	
-	
-	if(pri_key && pub_key){
-		key = read_pdp_keypair(pri_key, pub_key);
-		if(!key) goto cleanup;
-	}else if(!pri_key && !pub_key){
-		fprintf(stderr, "ERROR: PDP keys do not exist.\n");
-		fprintf(stdout, "Would you like to generate a new pair (y/N)?");
-		scanf("%c", &yesorno);
-		if(yesorno != 'y') goto cleanup;
-		key = pdp_create_new_keypair();
-		if(!key) goto cleanup;
-	}else if(!pub_key && pri_key){
-		/*TODO: Reconstruct public key from private, if it exists */
-		fprintf(stderr, "ERROR: PDP public key is missing.\n");
-		goto cleanup;
-
-		/* Get user passphrase */
-		//if(!readpassphrase("Enter passphrase:", password1, _PASSWORD_LEN + 1, RPP_ECHO_OFF)) goto cleanup;
-
-		/* Reconstrust the key */
-		//TODO 
-		//key = read_pdp_keypair(pri_key, pub_key, password1);
-		//memset(password1, 0, _PASSWORD_LEN + 1);
-		//if(!key) goto cleanup;
-		
-		/* Write the new public key to disk */
-		//if( snprintf(pdpkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PUBLIC_KEY) < 0 ) goto cleanup;
-		//pub_key = fopen(pdpkeypath, "w");
-		//if(!PEM_write_RSAPublicKey(pub_key, key->rsa)) goto cleanup;
-
-	}else{
-		fprintf(stderr, "ERROR: PDP private key is missing.\n");
-		goto cleanup;
-	}
-	
-	if(pri_key) fclose(pri_key);
-	if(pub_key) fclose(pub_key);
-	endpwent();
-	
-	return key;
-	
-cleanup:
-	fprintf(stderr, "ERROR: Unable to access your PDP keys.\n");
-	endpwent();
-	if(key) destroy_pdp_key(key);
-	if(pri_key) fclose(pri_key);
-	if(pub_key) fclose(pub_key);
-	
-	return NULL;
-	
-}
+* Keys are read from the private and public key files on disk, asking read_password for the passphrase.
+* Returns nil on failure.
+ */
+func pdp_get_keypair(read_password PDP_password_func) *PDP_key {
+	var key *PDP_key
 
-/* pdp_get_pubkey: Returns an PDP_key structure with only the public-key components allocated or NULL on failure.
-*/
-PDP_key *pdp_get_pubkey(){
-
-	PDP_key *key = NULL;
-	size_t gen_size = 0;
-	unsigned char *gen = NULL;
-	FILE *pub_key = NULL;
-	char pdpkeypath[MAXPATHLEN];
-	struct passwd *pw = NULL;
-	
-	if( ((pw = getpwuid(getuid())) == NULL) ) return NULL;
-	memset(pdpkeypath, 0, MAXPATHLEN);
-	
-	if( snprintf(pdpkeypath, MAXPATHLEN, "%s/%s", pw->pw_dir, PATH_PDP_PUBLIC_KEY) < 0 ) goto cleanup;
-	pub_key = fopen(pdpkeypath, "r");
-	if(!pub_key) return NULL;
-	
-	if( (key = malloc(sizeof(PDP_key))) == NULL) return NULL;
-	memset(key, 0, sizeof(PDP_key));
-	if( ((key->g=BN_new()) == NULL)) goto cleanup;
-	
-	/* Read in the public key */
-	key->rsa = PEM_read_RSAPublicKey(pub_key, NULL, NULL, NULL);
-	if(!key->rsa) goto cleanup;
-	
-	/* Retreive the generator */
-	fread(&gen_size, sizeof(size_t), 1, pub_key);
-	if( ((gen = malloc(gen_size)) == NULL)) goto cleanup;
-	fread(gen, gen_size, 1, pub_key);
-	if(!BN_bin2bn(gen, gen_size, key->g)) goto cleanup;
-	
-	if(gen) sfree(gen, gen_size);
-	endpwent();
-		
-	return key;
-	
-cleanup:
-	fprintf(stderr, "ERROR: Unable to access your PDP public key.\n");
-	if(key) destroy_pdp_key(key);
-	if(gen) sfree(gen, gen_size);
-	endpwent();
-	
-	return NULL;
+	pri_path, pub_path, err := pdp_key_paths()
+	if err != nil {
+		return nil
+	}
+	pri_key, pri_err := ioutil.ReadFile(pri_path)
+	pub_key, pub_err := ioutil.ReadFile(pub_path)
+
+	switch {
+	case pri_err == nil && pub_err == nil:
+		key = read_pdp_keypair(pri_key, pub_key, read_password("Enter passphrase:"))
+	case os.IsNotExist(pri_err) && os.IsNotExist(pub_err):
+		fmt.Fprintf(os.Stderr, "ERROR: PDP keys do not exist.\n")
+		if read_password("Would you like to generate a new pair (y/N)? ") != "y" {
+			return nil
+		}
+		return pdp_create_new_keypair(read_password)
+	case pub_err != nil:
+		fmt.Fprintf(os.Stderr, "ERROR: PDP public key is missing.\n")
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: PDP private key is missing.\n")
+	}
+	if key == nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Unable to access your PDP keys.\n")
+	}
+
+	return key
 }
 
-/* destroy_pdp_key: Zero and free the memory in a PDP_key structure */
-void destroy_pdp_key(PDP_key *key){
-	
-	if(!key) return;
-	if(key->rsa) RSA_free(key->rsa);
-	if(key->v)  sfree(key->v, PRF_KEY_SIZE);
-	if(key->g) destroy_pdp_generator(key->g);
-	if(key) sfree(key, sizeof(PDP_key));
-	key = NULL;
+/* pdp_get_pubkey: Returns an PDP_key structure with only the public-key components allocated or nil on failure.
+ */
+func pdp_get_pubkey() *PDP_key {
+	var key *PDP_key
+
+	_, pub_path, err := pdp_key_paths()
+	if err != nil {
+		return nil
+	}
+	pub_key, err := ioutil.ReadFile(pub_path)
+	if err != nil {
+		return nil
+	}
+	key = read_pdp_pubkey(pub_key)
+	if key == nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Unable to access your PDP public key.\n")
+	}
+
+	return key
 }
 
 /* generate_pdp_key: Generate a new PDP key pair and popular a PDP_key structure.
-*  Returns an allocated PDP_key strucutre or NULL on failure.
-*/
-PDP_key *generate_pdp_key(){
-
-	PDP_key *key = NULL;
-	BN_CTX *ctx = NULL;
-	BIGNUM *r1 = NULL;
-	BIGNUM *r2 = NULL;
-	BIGNUM *phi = NULL;
-
-	if( (key = malloc(sizeof(PDP_key))) == NULL) return NULL;
-	memset(key, 0, sizeof(PDP_key));
-	
-	/* Allocate memory */
-#ifdef USE_SAFE_PRIMES
-	if( ((key->rsa = RSA_new()) == NULL)) goto cleanup;
-	if( ((key->rsa->n=BN_new()) == NULL)) goto cleanup;
-	if( ((key->rsa->d=BN_new()) == NULL)) goto cleanup;
-	if( ((key->rsa->e=BN_new()) == NULL)) goto cleanup;
-	if( ((key->rsa->p=BN_new()) == NULL)) goto cleanup;
-	if( ((key->rsa->q=BN_new()) == NULL)) goto cleanup;
-	if( ((key->rsa->dmp1=BN_new()) == NULL)) goto cleanup;
-	if( ((key->rsa->dmq1=BN_new()) == NULL)) goto cleanup;
-	if( ((key->rsa->iqmp=BN_new()) == NULL)) goto cleanup;
-#else
-	if( ((key->rsa = RSA_generate_key(RSA_KEY_SIZE, RSA_E, NULL, NULL)) == NULL)) goto cleanup;
-#endif
-	if( ((r1=BN_new()) == NULL)) goto cleanup;
-	if( ((r2=BN_new()) == NULL)) goto cleanup;
-	if( ((ctx=BN_CTX_new()) == NULL)) goto cleanup;
-	if( ((phi=BN_new()) == NULL)) goto cleanup;
-	if( ((key->v = malloc(PRF_KEY_SIZE)) == NULL)) goto cleanup;
-	memset(key->v, 0, PRF_KEY_SIZE);
-	
-#ifdef USE_SAFE_PRIMES
-	/* Generate two safe primes p and q */
-  	if(!BN_generate_prime(key->rsa->p, (RSA_KEY_SIZE/2), 1, NULL, NULL, NULL, NULL)) goto cleanup;
-	if(!BN_is_prime(key->rsa->p, BN_prime_checks, NULL, ctx, NULL)) goto cleanup;
-  	if(!BN_generate_prime(key->rsa->q, (RSA_KEY_SIZE/2), 1, NULL, NULL, NULL, NULL)) goto cleanup;
-	if(!BN_is_prime(key->rsa->q, BN_prime_checks, NULL, ctx, NULL)) goto cleanup;
-	if(BN_cmp(key->rsa->p,key->rsa->q) == 0) goto cleanup;
-
-	/* Create an RSA modulus N*/
-	if(!BN_mul(key->rsa->n, key->rsa->p, key->rsa->q, ctx)) goto cleanup;
-		
-	/* Set e */
-	if(!BN_set_word(key->rsa->e, RSA_E)) goto cleanup;	
-
-	/* Generate phi and d */
-	if (!BN_sub(r1, key->rsa->p, BN_value_one())) goto cleanup;	/* p-1 */
-	if (!BN_sub(r2, key->rsa->q, BN_value_one())) goto cleanup;	/* q-1 */
-	if (!BN_mul(phi, r1, r2, ctx)) goto cleanup;	/* phi = (p-1)(q-1) */
-	if (!BN_mod_inverse(key->rsa->d, key->rsa->e, phi, ctx)) goto cleanup;	/* d */	
-	
-	/* Calculate d mod (p-1) */
-	if (!BN_mod(key->rsa->dmp1, key->rsa->d, r1, ctx)) goto cleanup;
-	
-	/* Calculate d mod (q-1) */
-	if (!BN_mod(key->rsa->dmq1, key->rsa->d, r2, ctx)) goto cleanup;
-	
-	/* Calculate the inverse of q mod p */
-	if (!BN_mod_inverse(key->rsa->iqmp, key->rsa->q, key->rsa->p, ctx)) goto cleanup;
-#endif
-	/* Check the RSA key pair */
-	if(!RSA_check_key(key->rsa)) goto cleanup;
-	
+*  Returns an allocated PDP_key strucutre or nil on failure.
+ */
+func generate_pdp_key() *PDP_key {
+	var key = &PDP_key{}
+	var err error
+
+	/* GenerateKey uses e = 65537 and precomputes the CRT values */
+	key.rsa, err = RSA.GenerateKey(rand.Reader, RSA_KEY_SIZE)
+	if err != nil {
+		return nil
+	}
+
 	/* Generate symmetric keys */
-	if(!RAND_bytes(key->v, PRF_KEY_SIZE)) goto cleanup;
+	v, err := GenerateRandomBytes(PRF_KEY_SIZE)
+	if err != nil {
+		return nil
+	}
+	key_v := string(v)
+	key.v = &key_v
 
 	/* Pick a PDP generator */
-	if( ((key->g = pick_pdp_generator(key->rsa->n)) == NULL)) goto cleanup;
-
-	/* We're done, free memory and return keys */
-	if(ctx) BN_CTX_free(ctx);
-	if(r1) BN_clear_free(r1);
-	if(r2) BN_clear_free(r2);
-	if(phi) BN_clear_free(phi);
-
-	return key;
-	
-cleanup:
-	if(key)	destroy_pdp_key(key);
-	if(r1) BN_clear_free(r1);
-	if(r2) BN_clear_free(r2);
-	if(phi) BN_clear_free(phi);
-	if(ctx) BN_CTX_free(ctx);
-
-	return NULL;
+	key.g = pick_pdp_generator(key.rsa.N)
+	if key.g == nil {
+		return nil
+	}
+
+	return key
+}
+
+/* GetKeypair: Exported form of pdp_get_keypair for the command line tool. */
+func GetKeypair(read_password PDP_password_func) *PDP_key {
+	return pdp_get_keypair(read_password)
+}
+
+/* GetPubkey: Exported form of pdp_get_pubkey for the command line tool. */
+func GetPubkey() *PDP_key {
+	return pdp_get_pubkey()
 }
@@ -0,0 +1,85 @@
+package gopdp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNISTKeyWrap(t *testing.T) {
+	/* RFC 3394, 4.1: wrap 128 bits of key data with a 128-bit KEK */
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	want, _ := hex.DecodeString("1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+
+	wrapped := nist_key_wrap(key, kek)
+	if !bytes.Equal(wrapped, want) {
+		t.Fatalf("wrapped %x, want %x", wrapped, want)
+	}
+	if !bytes.Equal(nist_key_unwrap(wrapped, kek), key) {
+		t.Fatal("unwrap did not return the key")
+	}
+
+	wrapped[3] ^= 1
+	if nist_key_unwrap(wrapped, kek) != nil {
+		t.Fatal("unwrapped a corrupted key")
+	}
+}
+
+func TestKeypairFiles(t *testing.T) {
+	var passphrase = "correct horse"
+	var read_password = func(string) string { return passphrase }
+
+	os.Setenv("HOME", t.TempDir())
+
+	key := new_test_key(t)
+	if write_pdp_keypair(key, "correct horse") != 1 {
+		t.Fatal("write_pdp_keypair failed")
+	}
+
+	loaded := pdp_get_keypair(read_password)
+	if loaded == nil {
+		t.Fatal("pdp_get_keypair failed")
+	}
+	if loaded.rsa.D.Cmp(key.rsa.D) != 0 || *loaded.v != *key.v || loaded.g.Cmp(key.g) != 0 {
+		t.Fatal("loaded key differs")
+	}
+	if crt_precomputed(loaded) != 1 {
+		t.Fatal("loaded key has no CRT values")
+	}
+
+	public := pdp_get_pubkey()
+	if public == nil || public.rsa.D != nil || public.v != nil || public.rsa.N.Cmp(key.rsa.N) != 0 {
+		t.Fatal("pdp_get_pubkey did not return the public key")
+	}
+
+	/* The private key is PKCS#8 under the key wrap, not legacy PEM encryption */
+	pri_path, pub_path, _ := pdp_key_paths()
+	pri_key, _ := ioutil.ReadFile(pri_path)
+	if block, _ := pem.Decode(pri_key); block == nil || block.Type != PDP_PRIVATE_KEY_PEM_TYPE || len(block.Headers) != 0 {
+		t.Fatal("private key is not a wrapped PKCS#8 key")
+	}
+
+	passphrase = "wrong"
+	if pdp_get_keypair(read_password) != nil {
+		t.Fatal("loaded the key with the wrong passphrase")
+	}
+
+	/* A key pair written with legacy PEM encryption is still read */
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key.rsa),
+		[]byte("correct horse"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rest := pem.Decode(pri_key)
+	pub_key, _ := ioutil.ReadFile(pub_path)
+	legacy := read_pdp_keypair(append(pem.EncodeToMemory(block), rest...), pub_key, "correct horse")
+	if legacy == nil || legacy.rsa.D.Cmp(key.rsa.D) != 0 || crt_precomputed(legacy) != 1 {
+		t.Fatal("could not read a legacy key pair")
+	}
+}
@@ -0,0 +1,329 @@
+package gopdp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	PDP_LIBP2P_PROTOCOL = protocol.ID("/pdp/1.0.0")
+
+	/* Request operations */
+	PDP_LIBP2P_FILE_INFO = 1
+	PDP_LIBP2P_PROVE     = 2
+
+	/* Response status codes */
+	PDP_LIBP2P_OK                = 0
+	PDP_LIBP2P_UNKNOWN_FILE      = 1
+	PDP_LIBP2P_PERMISSION_DENIED = 2
+	PDP_LIBP2P_BAD_REQUEST       = 3
+	PDP_LIBP2P_UNAVAILABLE       = 4
+	PDP_LIBP2P_TIMEOUT           = 5
+	PDP_LIBP2P_INTERNAL          = 6
+
+	/* Longest frame accepted, longest file ID and longest trace context */
+	PDP_LIBP2P_MAX_MESSAGE       = 64 << 10
+	PDP_LIBP2P_MAX_FILE_ID       = 1024
+	PDP_LIBP2P_MAX_TRACE_CONTEXT = 256
+	PDP_LIBP2P_MAX_FRAME         = PDP_LIBP2P_MAX_MESSAGE + PDP_LIBP2P_MAX_FILE_ID + PDP_LIBP2P_MAX_TRACE_CONTEXT
+
+	/* How long a server waits for the request after a stream is opened */
+	PDP_LIBP2P_READ_TIMEOUT = 30 * time.Second
+)
+
+/* Each /pdp/1.0.0 stream carries one request and one response, each a u32 length-prefixed frame in
+ * the wire format's encoding:
+ *
+ *   request:  op u8 | file ID | trace context | challenge (PROVE only, encode_pdp_challenge)
+ *   response: status u8 | payload
+ *
+ * The trace context is the caller's W3C traceparent, or empty, so the server's proof spans join the
+ * verifier's trace as they do over HTTP and gRPC.
+ * The payload is the encoded proof for PROVE, num_blocks u64 | block_size u32 | key fingerprint for
+ * FILE_INFO, and the error message for any status but OK.  The client closes its side of the stream
+ * after the request; the server abandons a proof only if the stream is reset.  The server names the
+ * client by its authenticated peer ID, so peer IDs can be listed in the server's ACL.
+ */
+
+/* PDPLibp2pServer serves a PDPServer as the /pdp/1.0.0 protocol of a libp2p host. */
+type PDPLibp2pServer struct {
+	host   host.Host
+	server *PDPServer
+}
+
+/* RegisterPDPLibp2pServer: Sets server as the handler for /pdp/1.0.0 streams on h. */
+func RegisterPDPLibp2pServer(h host.Host, server *PDPServer) *PDPLibp2pServer {
+	var service = &PDPLibp2pServer{host: h, server: server}
+
+	h.SetStreamHandler(PDP_LIBP2P_PROTOCOL, service.handle_stream)
+
+	return service
+}
+
+/* Close removes the stream handler. */
+func (service *PDPLibp2pServer) Close() {
+	service.host.RemoveStreamHandler(PDP_LIBP2P_PROTOCOL)
+}
+
+func (service *PDPLibp2pServer) handle_stream(stream network.Stream) {
+	var response []byte
+
+	defer stream.Close()
+
+	/* Cancel the proof if the client resets the stream, but not if it only closes its side */
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = with_client_names(ctx, []string{stream.Conn().RemotePeer().String()})
+
+	stream.SetReadDeadline(time.Now().Add(PDP_LIBP2P_READ_TIMEOUT))
+	request, err := read_libp2p_frame(stream)
+	if err != nil {
+		stream.Reset()
+		return
+	}
+	stream.SetReadDeadline(time.Time{})
+	go func() {
+		/* The client sends nothing more.  io.EOF is the client closing its side after the request;
+		 * anything else is a reset, an error or a protocol violation.
+		 */
+		var b [1]byte
+		if _, err := stream.Read(b[:]); err != io.EOF {
+			cancel()
+		}
+	}()
+
+	response, err = service.serve_request(ctx, request)
+	if err != nil {
+		response = append([]byte{libp2p_status(err)}, err.Error()...)
+	}
+	if write_libp2p_frame(stream, response) != nil {
+		stream.Reset()
+	}
+}
+
+/* serve_request: Decodes and answers one request.  Returns the OK response or the server's error. */
+func (service *PDPLibp2pServer) serve_request(ctx context.Context, request []byte) ([]byte, error) {
+	var reader = &pdp_wire_reader{data: request}
+	var response = []byte{PDP_LIBP2P_OK}
+
+	op := reader.u8()
+	fileID := reader.bytes(PDP_LIBP2P_MAX_FILE_ID)
+	ctx = extract_libp2p(ctx, reader.bytes(PDP_LIBP2P_MAX_TRACE_CONTEXT))
+
+	switch op {
+	case PDP_LIBP2P_FILE_INFO:
+		if !reader.done() {
+			return nil, ErrBadWireFormat
+		}
+		info, err := service.server.FileInfo(ctx, string(fileID))
+		if err != nil {
+			return nil, err
+		}
+		response = append_u64(response, info.NumBlocks)
+		response = append_u32(response, info.BlockSize)
+		response = append_bytes(response, info.KeyFingerprint)
+	case PDP_LIBP2P_PROVE:
+		if reader.bad {
+			return nil, ErrBadWireFormat
+		}
+		challenge := reader.data
+		proof, err := service.server.Prove(ctx, string(fileID), challenge)
+		if err != nil {
+			return nil, err
+		}
+		response = append(response, proof...)
+	default:
+		return nil, ErrBadWireFormat
+	}
+
+	return response, nil
+}
+
+/* libp2p_status: Maps the server's errors onto response status codes, as grpc_status does for gRPC. */
+func libp2p_status(err error) byte {
+	var aborted *PDP_prove_aborted
+
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		return PDP_LIBP2P_PERMISSION_DENIED
+	case errors.Is(err, ErrUnknownFile):
+		return PDP_LIBP2P_UNKNOWN_FILE
+	case errors.Is(err, ErrBadWireFormat), errors.Is(err, ErrBadChallenge):
+		return PDP_LIBP2P_BAD_REQUEST
+	case errors.Is(err, ErrMemoryBudget):
+		return PDP_LIBP2P_UNAVAILABLE
+	case errors.As(err, &aborted) && aborted.Timeout():
+		return PDP_LIBP2P_TIMEOUT
+	case errors.As(err, &aborted):
+		return PDP_LIBP2P_UNAVAILABLE
+	}
+
+	return PDP_LIBP2P_INTERNAL
+}
+
+/* PDPLibp2pClient audits files held by a libp2p peer.  It holds the verifier's private key. */
+type PDPLibp2pClient struct {
+	core *PDPCore
+	key  *PDP_key
+	host host.Host
+	peer peer.ID
+}
+
+/* NewPDPLibp2pClient: Returns a client auditing the peer p from h with the verifier's key.  h must
+ * already know an address for p, e.g. through h.Connect or its peerstore.
+ */
+func NewPDPLibp2pClient(h host.Host, p peer.ID, key *PDP_key) *PDPLibp2pClient {
+	return &PDPLibp2pClient{
+		core: NewPDPCore(),
+		key:  key,
+		host: h,
+		peer: p,
+	}
+}
+
+/* Audit challenges fileID, which the verifier tagged with numfileblocks blocks, and verifies the proof.
+ * Returns nil if it verified, ErrProofRejected if not.
+ */
+func (client *PDPLibp2pClient) Audit(ctx context.Context, fileID string, numfileblocks uint64) error {
+	return client.core.pdp_audit_remote(ctx, client.key, client, fileID, numfileblocks)
+}
+
+/* FileInfo implements PDP_remote. */
+func (client *PDPLibp2pClient) FileInfo(ctx context.Context, fileID string) (*PDP_file_info, error) {
+	var info = &PDP_file_info{}
+
+	response, err := client.round_trip(ctx, libp2p_request(ctx, PDP_LIBP2P_FILE_INFO, fileID))
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &pdp_wire_reader{data: response}
+	info.NumBlocks = reader.u64()
+	info.BlockSize = reader.u32()
+	info.KeyFingerprint = reader.bytes(sha256.Size)
+	if !reader.done() {
+		return nil, ErrBadWireFormat
+	}
+
+	return info, nil
+}
+
+/* Prove implements PDP_remote. */
+func (client *PDPLibp2pClient) Prove(ctx context.Context, fileID string, challenge *PDP_challenge) (*PDP_proof, error) {
+
+	encoded := encode_pdp_challenge(challenge)
+	if encoded == nil {
+		return nil, ErrBadWireFormat
+	}
+	request := libp2p_request(ctx, PDP_LIBP2P_PROVE, fileID)
+	response, err := client.round_trip(ctx, append(request, encoded...))
+	if err != nil {
+		return nil, err
+	}
+
+	return decode_pdp_proof(response, client.key)
+}
+
+/* libp2p_request: The start of a request frame, op | file ID | trace context of ctx. */
+func libp2p_request(ctx context.Context, op byte, fileID string) []byte {
+	var request = []byte{op}
+
+	request = append_bytes(request, []byte(fileID))
+	request = append_bytes(request, inject_libp2p(ctx))
+
+	return request
+}
+
+/* round_trip: Opens a stream to the peer, sends request and returns the payload of an OK response.
+ * Error statuses are turned back into the server's errors where the package has one.
+ */
+func (client *PDPLibp2pClient) round_trip(ctx context.Context, request []byte) ([]byte, error) {
+
+	if len(request) > PDP_LIBP2P_MAX_FRAME {
+		return nil, ErrBadWireFormat
+	}
+	stream, err := client.host.NewStream(ctx, client.peer, PDP_LIBP2P_PROTOCOL)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.Reset()
+		case <-done:
+		}
+	}()
+
+	if err = write_libp2p_frame(stream, request); err != nil {
+		return nil, context_error(ctx, err)
+	}
+	if err = stream.CloseWrite(); err != nil {
+		return nil, context_error(ctx, err)
+	}
+	response, err := read_libp2p_frame(stream)
+	if err != nil {
+		return nil, context_error(ctx, err)
+	}
+	if len(response) == 0 {
+		return nil, ErrBadWireFormat
+	}
+
+	switch response[0] {
+	case PDP_LIBP2P_OK:
+		return response[1:], nil
+	case PDP_LIBP2P_UNKNOWN_FILE:
+		return nil, ErrUnknownFile
+	case PDP_LIBP2P_PERMISSION_DENIED:
+		return nil, ErrPermissionDenied
+	}
+
+	return nil, fmt.Errorf("pdp: peer %s: status %d: %s", client.peer, response[0], response[1:])
+}
+
+/* context_error: Prefers ctx's error over the stream error it caused. */
+func context_error(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func write_libp2p_frame(w io.Writer, frame []byte) error {
+	_, err := w.Write(append_bytes(nil, frame))
+	return err
+}
+
+func read_libp2p_frame(r io.Reader) ([]byte, error) {
+	var length [4]byte
+
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n > PDP_LIBP2P_MAX_FRAME {
+		return nil, ErrBadWireFormat
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
@@ -0,0 +1,67 @@
+package gopdp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+/* libp2p_test_hosts: Two connected in-process hosts, the verifier's and the prover's. */
+func libp2p_test_hosts(t testing.TB) (host.Host, host.Host) {
+	t.Helper()
+
+	network, err := mocknet.FullMeshConnected(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { network.Close() })
+	hosts := network.Hosts()
+
+	return hosts[0], hosts[1]
+}
+
+func TestLibp2pAudit(t *testing.T) {
+	var ctx = context.Background()
+
+	key, server, path, data := serve_test_file(t, 500)
+	verifier, prover := libp2p_test_hosts(t)
+	service := RegisterPDPLibp2pServer(prover, server)
+	client := NewPDPLibp2pClient(verifier, prover.ID(), key)
+
+	/* The client closes its side of the stream after the request, which must not cancel the proof */
+	if err := client.Audit(ctx, "data", 500); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Audit(ctx, "data", 499); !errors.Is(err, ErrBlockCountMismatch) {
+		t.Fatalf("audit with another block count: %v", err)
+	}
+	if err := client.Audit(ctx, "other", 500); !errors.Is(err, ErrUnknownFile) {
+		t.Fatalf("audit of an unknown file: %v", err)
+	}
+
+	/* The server names the client by its peer ID */
+	acl := NewPDPACL()
+	acl.Allow(prover.ID().String(), "data")
+	server.SetACL(acl)
+	if err := client.Audit(ctx, "data", 500); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("audit by a peer not in the ACL: %v", err)
+	}
+	acl.Allow(verifier.ID().String(), "data")
+	if err := client.Audit(ctx, "data", 500); err != nil {
+		t.Fatalf("audit by a peer in the ACL: %v", err)
+	}
+
+	corrupt_test_file(t, path, data)
+	if err := client.Audit(ctx, "data", 500); !errors.Is(err, ErrProofRejected) {
+		t.Fatalf("audit of a corrupted file: %v", err)
+	}
+
+	service.Close()
+	if err := client.Audit(ctx, "data", 500); err == nil {
+		t.Fatal("audit succeeded after the handler was removed")
+	}
+}
@@ -0,0 +1,375 @@
+package gopdp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"github.com/cloudflare/circl/ecc/bls12381"
+)
+
+/* Private compact proofs, the PDP_SCHEME_MAC backend: the Shacham-Waters scheme with a homomorphic MAC
+ * in place of the signature, for an owner that audits its own files.  Blocks are split into the
+ * PDP_BLS_SECTORS sectors of pdp-bls.go and all arithmetic is in Z_p, p the BLS12-381 group order, but
+ * no curve operation is ever done.  The secret key is a random seed from which the sector keys
+ * alpha_j = F(seed, "sector" | j) and the block PRF f(name | i) = F(seed, "index" | name | i) are derived,
+ * F being 64 bytes of HMAC-SHA256 reduced mod p.  name is the file's tag-file name, as for BLS, so the
+ * tags of one file under a seed answer for no other.  The tag of block i is
+ *
+ *   sigma_i = f(name | i) + sum alpha_j m_ij mod p
+ *
+ * a PDP_MAC_TAG_SIZE-byte number stored in the tag file like any other tag.  A challenge is the public
+ * <c, k1, k2, name> with coefficients nu = f_k2(j).  The proof has the BLS layout, sigma = sum nu_i sigma_i
+ * carried in T and the sector sums mu_j = sum nu_i m_ij, all mod p, and verifies if
+ *
+ *   sigma == sum nu_i f(name | i) + sum alpha_j mu_j mod p
+ *
+ * Tagging a block costs PDP_BLS_SECTORS field multiplications instead of a modular exponentiation or a
+ * curve multiplication, and verifying a proof c + PDP_BLS_SECTORS.  Only the seed holder can tag or
+ * verify.  The prover needs no secret, only the key ID HMAC-SHA256(seed, "PDP MAC fingerprint") that
+ * stands in for the key's fingerprint; pdp_mac_prover_key is all a server holds.  Replicas, proof
+ * masking and signed Merkle roots are RSA only.
+ *
+ * A MAC key is stored as:
+ *
+ *   magic[4] | version u32 | ID | seed
+ *
+ * with each field u32 length-prefixed; the seed is empty in a prover key.
+ */
+
+const (
+	PDP_SCHEME_MAC = 2
+
+	PDP_MAC_TAG_SIZE  = bls12381.ScalarSize
+	PDP_MAC_SEED_SIZE = 32
+
+	PDP_MAC_KEY_MAGIC   = "PDPK"
+	PDP_MAC_KEY_VERSION = 1
+
+	pdp_mac_sector_label      = "PDP MAC sector"
+	pdp_mac_index_label       = "PDP MAC index"
+	pdp_mac_fingerprint_label = "PDP MAC fingerprint"
+)
+
+var ErrBadMACKey = errors.New("pdp: malformed MAC key")
+
+type PDP_mac_key struct {
+	/* The key ID, and the secret seed it is derived from, nil in a prover key */
+	id   []byte
+	seed []byte
+
+	/* The sector keys alpha_j, derived from seed */
+	alpha []*bls12381.Scalar
+}
+
+/* generate_mac_key: Generates a MAC key from a random seed. */
+func generate_mac_key() *PDP_key {
+
+	seed, err := GenerateRandomBytes(PDP_MAC_SEED_SIZE)
+	if err != nil {
+		return nil
+	}
+
+	return mac_key_from_seed(seed)
+}
+
+/* mac_key_from_seed: The MAC key of seed, with its sector keys derived. */
+func mac_key_from_seed(seed []byte) *PDP_key {
+	var mac = &PDP_mac_key{seed: seed, alpha: make([]*bls12381.Scalar, PDP_BLS_SECTORS)}
+	var id = hmac.New(sha256.New, seed)
+
+	id.Write([]byte(pdp_mac_fingerprint_label))
+	mac.id = id.Sum(nil)
+	for j := range mac.alpha {
+		mac.alpha[j] = mac_prf(seed, pdp_mac_sector_label, append_u32(nil, uint32(j)))
+	}
+
+	return &PDP_key{mac: mac}
+}
+
+/* pdp_mac_prover_key: The key a server proving files tagged under key holds, its ID without the
+ * seed.  Returns nil if key is not a MAC key.
+ */
+func pdp_mac_prover_key(key *PDP_key) *PDP_key {
+
+	if key == nil || key.mac == nil || key.mac.id == nil {
+		return nil
+	}
+
+	return &PDP_key{mac: &PDP_mac_key{id: key.mac.id}}
+}
+
+/* mac_prf: F(seed, label | input), 64 bytes of HMAC-SHA256 output reduced mod p so the bias is
+ * negligible.
+ */
+func mac_prf(seed []byte, label string, input []byte) *bls12381.Scalar {
+	var mac = hmac.New(sha256.New, seed)
+	var output = make([]byte, 0, 2*sha256.Size)
+	var scalar = new(bls12381.Scalar)
+
+	for counter := byte(0); counter < 2; counter++ {
+		mac.Reset()
+		mac.Write([]byte(label))
+		mac.Write(input)
+		mac.Write([]byte{counter})
+		output = mac.Sum(output)
+	}
+	scalar.SetBytes(output)
+
+	return scalar
+}
+
+/* mac_index_prf: f(name | i), the PRF of block index i of the file named name. */
+func mac_index_prf(key *PDP_key, name []byte, index uint) *bls12381.Scalar {
+	return mac_prf(key.mac.seed, pdp_mac_index_label, append_u64(append([]byte{}, name...), uint64(index)))
+}
+
+/* mac_block_tag: sigma_i = f(name | i) + sum alpha_j m_ij mod p, for the file key is named after. */
+func mac_block_tag(key *PDP_key, block []byte, index uint) *bls12381.Scalar {
+	var sigma = mac_index_prf(key, key.name, index)
+	var r0 = new(bls12381.Scalar)
+
+	for j, m := range block_to_sectors(block) {
+		r0.Mul(key.mac.alpha[j], m)
+		sigma.Add(sigma, r0)
+	}
+
+	return sigma
+}
+
+/* mac_tag_block: pdp_tag_block for a MAC key. */
+func (pdpCore *PDPCore) mac_tag_block(key *PDP_key, block *string, blocksize *uint64, index uint) *PDP_tag {
+	var tag *PDP_tag
+	var data = []byte(*block)
+
+	if key.mac.seed == nil || len(key.mac.alpha) != PDP_BLS_SECTORS || len(key.name) != PDP_TAG_FILE_NAME_SIZE {
+		return nil
+	}
+	if uint64(len(data)) > *blocksize {
+		data = data[:*blocksize]
+	}
+
+	tag = pdpCore.generate_pdp_tag()
+	tag.index = index
+	tag.Tim = scalar_to_bn(mac_block_tag(key, data, index))
+
+	return tag
+}
+
+/* mac_check_tag_block: check_pdp_tag_block for a MAC key.  Only the seed holder can check a tag; a
+ * prover key accepts any tag below p.
+ */
+func mac_check_tag_block(key *PDP_key, block []byte, tag *PDP_tag) int {
+
+	sigma := bn_to_scalar(tag.Tim)
+	if sigma == nil {
+		return 0
+	}
+	if key.mac.seed == nil {
+		return 1
+	}
+	if len(key.name) != PDP_TAG_FILE_NAME_SIZE {
+		return 0
+	}
+	if mac_block_tag(key, block, tag.index).IsEqual(sigma) != 1 {
+		return 0
+	}
+
+	return 1
+}
+
+/* mac_generate_proof_update: Folds challenged blocks into a MAC proof, creating it if nil.  tags[k]
+ * and blocks[k] are the tag and data of the js[k]-th challenged block.  T and mu hold unreduced sums
+ * until pdp_generate_proof_final.
+ */
+func (pdpCore *PDPCore) mac_generate_proof_update(challenge *PDP_challenge, proof *PDP_proof, js []uint,
+	tags []*PDP_tag, blocks [][]byte) *PDP_proof {
+
+	if proof == nil {
+		proof = pdpCore.generate_pdp_proof()
+		proof.T = new(big.Int)
+		proof.mu = make([]*big.Int, PDP_BLS_SECTORS)
+		for j := range proof.mu {
+			proof.mu[j] = new(big.Int)
+		}
+	}
+	if proof.T == nil || len(proof.mu) != PDP_BLS_SECTORS {
+		return nil
+	}
+
+	for k, j := range js {
+		if tags[k] == nil || bn_to_scalar(tags[k].Tim) == nil || blocks[k] == nil {
+			return nil
+		}
+		nu := pdpCore.bls_coefficient(challenge, j)
+		if nu == nil {
+			return nil
+		}
+		nu_bn := scalar_to_bn(nu)
+
+		/* sigma = sigma + nu * sigma_i */
+		proof.T.Add(proof.T, new(big.Int).Mul(nu_bn, tags[k].Tim))
+		add_sector_sums(proof.mu, nu_bn, blocks[k])
+	}
+
+	return proof
+}
+
+/* mac_combine_proofs: Adds the partial MAC proof into proof.  Returns proof or nil on failure. */
+func mac_combine_proofs(proof *PDP_proof, partial *PDP_proof) *PDP_proof {
+
+	if proof.T == nil || partial.T == nil || len(proof.mu) != PDP_BLS_SECTORS || len(partial.mu) != PDP_BLS_SECTORS {
+		return nil
+	}
+	proof.T.Add(proof.T, partial.T)
+	for j := range proof.mu {
+		proof.mu[j].Add(proof.mu[j], partial.mu[j])
+	}
+
+	return proof
+}
+
+/* mac_generate_proof_final: Reduces sigma and the sector sums mod p. */
+func mac_generate_proof_final(proof *PDP_proof) *PDP_proof {
+
+	if bls_generate_proof_final(proof) == nil {
+		return nil
+	}
+	proof.T.Mod(proof.T, new(big.Int).SetBytes(bls12381.Order()))
+
+	return proof
+}
+
+/* mac_verify_proof: verify_proof for a MAC key, sigma == sum nu_i f(name | i) + sum alpha_j mu_j mod p,
+ * name the file the challenge is for.
+ */
+func (pdpCore *PDPCore) mac_verify_proof(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof,
+	tag_index func(position uint) (uint, bool)) int {
+
+	var expected = new(bls12381.Scalar)
+	var r0 = new(bls12381.Scalar)
+
+	if key.mac.seed == nil || len(key.mac.alpha) != PDP_BLS_SECTORS || len(proof.mu) != PDP_BLS_SECTORS {
+		return 0
+	}
+	if challenge.replica != nil || len(challenge.name) != PDP_TAG_FILE_NAME_SIZE {
+		return 0
+	}
+	sigma := bn_to_scalar(proof.T)
+	if sigma == nil {
+		return 0
+	}
+
+	/* sum nu_i f(name | i) over the challenged blocks */
+	indices := pdpCore.generate_prp_pi(challenge)
+	if uint(len(indices)) < challenge.c {
+		return 0
+	}
+	for j := uint(0); j < challenge.c; j++ {
+		var index = indices[j]
+
+		if tag_index != nil {
+			var ok bool
+			if index, ok = tag_index(index); !ok {
+				return 0
+			}
+		}
+		nu := pdpCore.bls_coefficient(challenge, j)
+		if nu == nil {
+			return 0
+		}
+		r0.Mul(nu, mac_index_prf(key, challenge.name, index))
+		expected.Add(expected, r0)
+	}
+
+	/* plus sum alpha_j mu_j */
+	for j := range proof.mu {
+		mu := bn_to_scalar(proof.mu[j])
+		if mu == nil {
+			return 0
+		}
+		r0.Mul(key.mac.alpha[j], mu)
+		expected.Add(expected, r0)
+	}
+
+	return expected.IsEqual(sigma)
+}
+
+/* encode_mac_key: Encodes a MAC key, with its seed if private is set.  Returns nil on failure. */
+func encode_mac_key(key *PDP_key, private bool) []byte {
+	var buf []byte
+	var seed []byte
+
+	if key == nil || key.mac == nil || len(key.mac.id) != sha256.Size {
+		return nil
+	}
+	if private {
+		if len(key.mac.seed) != PDP_MAC_SEED_SIZE {
+			return nil
+		}
+		seed = key.mac.seed
+	}
+
+	buf = append([]byte(PDP_MAC_KEY_MAGIC), append_u32(nil, PDP_MAC_KEY_VERSION)...)
+	buf = append_bytes(buf, key.mac.id)
+	buf = append_bytes(buf, seed)
+
+	return buf
+}
+
+/* decode_mac_key: Decodes a MAC key.  The ID must be that of the seed, if present.  Returns the key or
+ * ErrBadMACKey.
+ */
+func decode_mac_key(data []byte) (*PDP_key, error) {
+	var reader = &pdp_wire_reader{data: data}
+
+	if string(reader.take(4)) != PDP_MAC_KEY_MAGIC || reader.u32() != PDP_MAC_KEY_VERSION {
+		return nil, ErrBadMACKey
+	}
+	id := reader.bytes(sha256.Size)
+	seed := reader.bytes(PDP_MAC_SEED_SIZE)
+	if !reader.done() || len(id) != sha256.Size || (len(seed) != 0 && len(seed) != PDP_MAC_SEED_SIZE) {
+		return nil, ErrBadMACKey
+	}
+	if len(seed) == 0 {
+		return &PDP_key{mac: &PDP_mac_key{id: id}}, nil
+	}
+
+	key := mac_key_from_seed(seed)
+	if !hmac.Equal(key.mac.id, id) {
+		return nil, ErrBadMACKey
+	}
+
+	return key, nil
+}
+
+/* WriteMACKey writes a MAC key to path, with its seed, readable only by its owner, if private is set,
+ * or as a prover key otherwise.
+ */
+func WriteMACKey(path string, key *PDP_key, private bool) error {
+	var mode os.FileMode = 0644
+
+	data := encode_mac_key(key, private)
+	if data == nil {
+		return ErrBadMACKey
+	}
+	if private {
+		mode = 0600
+	}
+
+	return ioutil.WriteFile(path, data, mode)
+}
+
+/* LoadMACKey reads a MAC key written by WriteMACKey. */
+func LoadMACKey(path string) (*PDP_key, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode_mac_key(data)
+}
@@ -0,0 +1,81 @@
+package gopdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMACFileCannotAnswerForAnother(t *testing.T) {
+	var core = NewPDPCore()
+	var ctx = context.Background()
+
+	key := generate_mac_key()
+	if key == nil {
+		t.Fatal("could not generate a MAC key")
+	}
+	first, first_name := bls_test_file(t, key, 4*PDP_BLOCKSIZE+100)
+	second, second_name := bls_test_file(t, key, 4*PDP_BLOCKSIZE+100)
+	if bytes.Equal(first_name, second_name) {
+		t.Fatal("two files were tagged under the same name")
+	}
+	server_key := pdp_mac_prover_key(key)
+	named := pdp_named_key(key, first_name)
+
+	challenge := core.pdp_challenge(named, 5)
+	proof, err := core.pdp_prove_file(ctx, first, "", challenge, server_key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.pdp_verify_proof(named, challenge, proof) != 1 {
+		t.Fatal("proof of the first file did not verify")
+	}
+
+	/* A server holding only the second file refuses the first file's challenge */
+	if _, err := core.pdp_prove_file(ctx, second, "", challenge, server_key, nil); !errors.Is(err, ErrBadChallenge) {
+		t.Fatalf("second file proved the first file's challenge: %v", err)
+	}
+
+	/* and if it answers anyway under the second file's name, the proof does not verify */
+	substitute := *challenge
+	substitute.name = second_name
+	proof, err = core.pdp_prove_file(ctx, second, "", &substitute, server_key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.pdp_verify_proof(named, challenge, proof) == 1 {
+		t.Error("second file answered for the first")
+	}
+	if core.pdp_verify_proof(pdp_named_key(key, second_name), &substitute, proof) != 1 {
+		t.Error("proof of the second file did not verify under its own name")
+	}
+	if pdp_scheme(key) != "mac" {
+		t.Errorf("MAC key is labelled %q", pdp_scheme(key))
+	}
+}
+
+func TestMACKeyFile(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "mac.key")
+
+	key := generate_mac_key()
+	if err := WriteMACKey(path, key, true); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadMACKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.mac.seed, key.mac.seed) || !bytes.Equal(loaded.mac.id, key.mac.id) {
+		t.Fatal("MAC key does not round-trip")
+	}
+
+	/* A MAC key file is never taken for a signed Merkle root */
+	if PDP_MAC_KEY_MAGIC == PDP_MERKLE_MAGIC {
+		t.Error("MAC keys and Merkle trees share a magic")
+	}
+	if _, err := LoadPDPMerkleTree(path); err == nil {
+		t.Error("a MAC key loaded as a Merkle tree")
+	}
+}
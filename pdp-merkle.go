@@ -0,0 +1,726 @@
+package gopdp
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+/* Merkle-tree dynamic PDP is the alternative to the index table of pdp-dynamic.go for a verifier that
+ * keeps only a root.  Blocks are tagged under fresh tag indices as in pdp-dynamic.go, and the server
+ * keeps a rank-based Merkle tree whose leaves are the tag indices in block order:
+ *
+ *   leaf     = SHA-256(0x00 | tag index u64)
+ *   internal = SHA-256(0x01 | leaf count u64 | left | right)
+ *
+ * The leaf counts make a path from a leaf to the root prove the leaf's position as well as its tag index,
+ * so a proof is the ordinary T and rho plus the path of every challenged block, and the verifier needs
+ * only the root, the block count and its next tag index, a PDP_merkle_state.
+ *
+ * An update is prepared and tagged by the client, applied by the server, which returns the old path of
+ * the block and its new root, and accepted by the client only if that root is the one the path and the
+ * update give.  The client then signs the new root for the server to keep, and the server sends the
+ * signature with every proof, so an auditor holding a state from anyone but the client accepts only a
+ * root the client signed.  Inserting a block replaces a leaf with a node over it and the new leaf, and
+ * deleting one replaces its parent with its sibling, so every update is checked from a single path.
+ * The tree is not rebalanced, and many inserts at one place make its paths longer.
+ */
+
+const (
+	PDP_MERKLE_MAGIC   = "PDPM"
+	PDP_MERKLE_VERSION = 1
+	PDP_MERKLE_SUFFIX  = ".mht"
+
+	PDP_MERKLE_HASH_SIZE = sha256.Size
+
+	/* Largest root signature accepted in a tree file */
+	PDP_MERKLE_MAX_SIGNATURE = 1024
+)
+
+const (
+	pdp_merkle_leaf     = 0
+	pdp_merkle_internal = 1
+)
+
+var ErrBadMerkleTree = errors.New("pdp: malformed Merkle tree")
+var ErrMerklePath = errors.New("pdp: authentication path does not lead to the root")
+var ErrUpdateRejected = errors.New("pdp: new root does not match the update")
+var ErrUnsignedRoot = errors.New("pdp: Merkle root has not been signed since the last update")
+
+type pdp_merkle_node struct {
+	hash  [PDP_MERKLE_HASH_SIZE]byte
+	count uint64
+
+	/* A leaf's tag index */
+	index uint64
+
+	left  *pdp_merkle_node
+	right *pdp_merkle_node
+}
+
+/* PDP_merkle_tree is the server's tree over a file's tag indices, with the client's signature of its
+ * root.  Nodes are never changed in place, so an update builds a new path and proofs already running
+ * keep the old tree.  On disk it is "PDPM" | version u32 | signature, u32 length-prefixed, followed by
+ * the nodes in preorder: 0x00 | tag index u64 for a leaf, 0x01 followed by both children otherwise.
+ */
+type PDP_merkle_tree struct {
+	mutex     sync.RWMutex
+	root      *pdp_merkle_node
+	signature []byte
+}
+
+/* PDP_merkle_sibling is one step of an authentication path: the node beside the path. */
+type PDP_merkle_sibling struct {
+	hash  [PDP_MERKLE_HASH_SIZE]byte
+	count uint64
+	left  bool
+}
+
+/* PDP_merkle_path authenticates the tag index of a leaf.  Siblings run from the leaf up. */
+type PDP_merkle_path struct {
+	index    uint64
+	siblings []PDP_merkle_sibling
+}
+
+/* PDP_merkle_proof is a proof with the paths of the challenged blocks, in challenge order, and the
+ * client's signature of the root they lead to.
+ */
+type PDP_merkle_proof struct {
+	proof     *PDP_proof
+	paths     []*PDP_merkle_path
+	signature []byte
+}
+
+/* PDP_merkle_state is everything the verifier keeps about a file. */
+type PDP_merkle_state struct {
+	Root      [PDP_MERKLE_HASH_SIZE]byte
+	NumBlocks uint64
+
+	/* The next unused tag index */
+	Next uint64
+}
+
+/* PDP_merkle_update_request is a block operation prepared by the client: the new block and its tag
+ * for a modify or insert.
+ */
+type PDP_merkle_update_request struct {
+	op       int
+	position uint64
+	block    []byte
+	tag      *PDP_tag
+}
+
+/* PDP_merkle_update is the server's answer to an update: the path of the block it changed, or of the
+ * last block when appending, as it was before the update, and the tree's new root.
+ */
+type PDP_merkle_update struct {
+	path *PDP_merkle_path
+	root [PDP_MERKLE_HASH_SIZE]byte
+}
+
+func merkle_leaf_hash(index uint64) [PDP_MERKLE_HASH_SIZE]byte {
+	var buf [9]byte
+
+	buf[0] = pdp_merkle_leaf
+	binary.BigEndian.PutUint64(buf[1:], index)
+
+	return sha256.Sum256(buf[:])
+}
+
+func merkle_node_hash(count uint64, left *[PDP_MERKLE_HASH_SIZE]byte, right *[PDP_MERKLE_HASH_SIZE]byte) [PDP_MERKLE_HASH_SIZE]byte {
+	var buf [9 + 2*PDP_MERKLE_HASH_SIZE]byte
+
+	buf[0] = pdp_merkle_internal
+	binary.BigEndian.PutUint64(buf[1:9], count)
+	copy(buf[9:], left[:])
+	copy(buf[9+PDP_MERKLE_HASH_SIZE:], right[:])
+
+	return sha256.Sum256(buf[:])
+}
+
+func new_merkle_leaf(index uint64) *pdp_merkle_node {
+	return &pdp_merkle_node{hash: merkle_leaf_hash(index), count: 1, index: index}
+}
+
+func new_merkle_internal(left *pdp_merkle_node, right *pdp_merkle_node) *pdp_merkle_node {
+	var count = left.count + right.count
+
+	return &pdp_merkle_node{hash: merkle_node_hash(count, &left.hash, &right.hash), count: count, left: left, right: right}
+}
+
+/* build_merkle_tree: The balanced tree over the identity tag indices [lo, hi), the left half taking
+ * the extra leaf.
+ */
+func build_merkle_tree(lo uint64, hi uint64) *pdp_merkle_node {
+	if hi-lo == 1 {
+		return new_merkle_leaf(lo)
+	}
+	mid := lo + (hi-lo+1)/2
+
+	return new_merkle_internal(build_merkle_tree(lo, mid), build_merkle_tree(mid, hi))
+}
+
+/* merkle_identity_root: The root hash of build_merkle_tree(lo, hi) without keeping the nodes. */
+func merkle_identity_root(lo uint64, hi uint64) [PDP_MERKLE_HASH_SIZE]byte {
+	if hi-lo == 1 {
+		return merkle_leaf_hash(lo)
+	}
+	mid := lo + (hi-lo+1)/2
+	left := merkle_identity_root(lo, mid)
+	right := merkle_identity_root(mid, hi)
+
+	return merkle_node_hash(hi-lo, &left, &right)
+}
+
+/* NewPDPMerkleTree: Returns the tree of a file of numfileblocks blocks tagged with pdp_tag_file. */
+func NewPDPMerkleTree(numfileblocks uint64) (*PDP_merkle_tree, error) {
+	if numfileblocks == 0 {
+		return nil, ErrBadPosition
+	}
+	return &PDP_merkle_tree{root: build_merkle_tree(0, numfileblocks)}, nil
+}
+
+/* pdp_merkle_init: Client-side function that returns the verifier's state for a file of numfileblocks
+ * blocks tagged with pdp_tag_file, the root of the server's NewPDPMerkleTree.  The client signs the root
+ * with pdp_merkle_sign_root for the server's SetSignature before the file can be proved.
+ */
+func (pdpCore *PDPCore) pdp_merkle_init(numfileblocks uint64) *PDP_merkle_state {
+	if numfileblocks == 0 {
+		return nil
+	}
+	return &PDP_merkle_state{Root: merkle_identity_root(0, numfileblocks), NumBlocks: numfileblocks, Next: numfileblocks}
+}
+
+/* LoadPDPMerkleTree: Reads the tree file at path. */
+func LoadPDPMerkleTree(path string) (*PDP_merkle_tree, error) {
+	var tree = &PDP_merkle_tree{}
+	var header [8]byte
+	var err error
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	if _, err = io.ReadFull(reader, header[:]); err != nil {
+		return nil, ErrBadMerkleTree
+	}
+	if string(header[0:4]) != PDP_MERKLE_MAGIC || binary.BigEndian.Uint32(header[4:8]) != PDP_MERKLE_VERSION {
+		return nil, ErrBadMerkleTree
+	}
+	if _, err = io.ReadFull(reader, header[:4]); err != nil {
+		return nil, ErrBadMerkleTree
+	}
+	if size := binary.BigEndian.Uint32(header[:4]); size > 0 {
+		if size > PDP_MERKLE_MAX_SIGNATURE {
+			return nil, ErrBadMerkleTree
+		}
+		tree.signature = make([]byte, size)
+		if _, err = io.ReadFull(reader, tree.signature); err != nil {
+			return nil, ErrBadMerkleTree
+		}
+	}
+
+	if tree.root, err = read_merkle_node(reader); err != nil {
+		return nil, err
+	}
+	if _, err = reader.ReadByte(); err != io.EOF {
+		return nil, ErrBadMerkleTree
+	}
+
+	return tree, nil
+}
+
+func read_merkle_node(reader *bufio.Reader) (*pdp_merkle_node, error) {
+	var index [8]byte
+
+	kind, err := reader.ReadByte()
+	if err != nil {
+		return nil, ErrBadMerkleTree
+	}
+	switch kind {
+	case pdp_merkle_leaf:
+		if _, err = io.ReadFull(reader, index[:]); err != nil {
+			return nil, ErrBadMerkleTree
+		}
+		return new_merkle_leaf(binary.BigEndian.Uint64(index[:])), nil
+	case pdp_merkle_internal:
+		left, err := read_merkle_node(reader)
+		if err != nil {
+			return nil, err
+		}
+		right, err := read_merkle_node(reader)
+		if err != nil {
+			return nil, err
+		}
+		return new_merkle_internal(left, right), nil
+	}
+
+	return nil, ErrBadMerkleTree
+}
+
+/* Save writes the tree to path, replacing it only once the new copy is on disk. */
+func (tree *PDP_merkle_tree) Save(path string) error {
+	tree.mutex.RLock()
+	root, signature := tree.root, tree.signature
+	tree.mutex.RUnlock()
+
+	return write_merkle_tree(path, root, signature)
+}
+
+func write_merkle_tree(path string, root *pdp_merkle_node, signature []byte) error {
+	var header [8]byte
+
+	temp := path + ".tmp"
+	file, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+
+	copy(header[0:4], PDP_MERKLE_MAGIC)
+	binary.BigEndian.PutUint32(header[4:8], PDP_MERKLE_VERSION)
+	writer.Write(header[:])
+	writer.Write(append_bytes(nil, signature))
+	write_merkle_node(writer, root)
+
+	if err = writer.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	return os.Rename(temp, path)
+}
+
+func write_merkle_node(writer *bufio.Writer, node *pdp_merkle_node) {
+	if node.left == nil {
+		writer.WriteByte(pdp_merkle_leaf)
+		writer.Write(append_u64(nil, node.index))
+		return
+	}
+	writer.WriteByte(pdp_merkle_internal)
+	write_merkle_node(writer, node.left)
+	write_merkle_node(writer, node.right)
+}
+
+/* NumBlocks returns the number of leaves. */
+func (tree *PDP_merkle_tree) NumBlocks() uint64 {
+	tree.mutex.RLock()
+	defer tree.mutex.RUnlock()
+
+	return tree.root.count
+}
+
+/* Signature returns the client's signature of the current root, or nil if it has not been set since
+ * the last update.
+ */
+func (tree *PDP_merkle_tree) Signature() []byte {
+	tree.mutex.RLock()
+	defer tree.mutex.RUnlock()
+
+	return tree.signature
+}
+
+/* SetSignature stores the client's signature of the current root after checking it with key. */
+func (tree *PDP_merkle_tree) SetSignature(key *PDP_key, signature []byte) error {
+	tree.mutex.Lock()
+	defer tree.mutex.Unlock()
+
+	if len(signature) > PDP_MERKLE_MAX_SIGNATURE ||
+		pdp_merkle_verify_root(key, tree.root.hash, tree.root.count, signature) != 1 {
+		return ErrUpdateRejected
+	}
+	tree.signature = append([]byte{}, signature...)
+
+	return nil
+}
+
+/* merkle_path: The path of the leaf at position in the tree under root. */
+func merkle_path(root *pdp_merkle_node, position uint64) *PDP_merkle_path {
+	var siblings []PDP_merkle_sibling
+	var node = root
+
+	for node.left != nil {
+		if position < node.left.count {
+			siblings = append(siblings, PDP_merkle_sibling{hash: node.right.hash, count: node.right.count})
+			node = node.left
+		} else {
+			siblings = append(siblings, PDP_merkle_sibling{hash: node.left.hash, count: node.left.count, left: true})
+			position -= node.left.count
+			node = node.right
+		}
+	}
+	for i, j := 0, len(siblings)-1; i < j; i, j = i+1, j-1 {
+		siblings[i], siblings[j] = siblings[j], siblings[i]
+	}
+
+	return &PDP_merkle_path{index: node.index, siblings: siblings}
+}
+
+/* merkle_update: Applies op at position under node and returns the new node, or nil if node was the
+ * leaf deleted.  An insert reaches a leaf at relative position 0, to go before it, or 1, to follow it.
+ */
+func merkle_update(node *pdp_merkle_node, op int, position uint64, index uint64) *pdp_merkle_node {
+
+	if node.left == nil {
+		switch {
+		case op == PDP_BLOCK_MODIFY:
+			return new_merkle_leaf(index)
+		case op == PDP_BLOCK_INSERT && position == 0:
+			return new_merkle_internal(new_merkle_leaf(index), node)
+		case op == PDP_BLOCK_INSERT:
+			return new_merkle_internal(node, new_merkle_leaf(index))
+		}
+		return nil
+	}
+
+	left, right := node.left, node.right
+	if position < left.count {
+		left = merkle_update(left, op, position, index)
+	} else {
+		right = merkle_update(right, op, position-left.count, index)
+	}
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+
+	return new_merkle_internal(left, right)
+}
+
+/* merkle_fold: Folds siblings into a node with hash and count.  Returns the root hash, the leaf count
+ * and the position of the node's first leaf.
+ */
+func merkle_fold(hash [PDP_MERKLE_HASH_SIZE]byte, count uint64, siblings []PDP_merkle_sibling) ([PDP_MERKLE_HASH_SIZE]byte, uint64, uint64) {
+	var position uint64 = 0
+
+	for i := range siblings {
+		sibling := &siblings[i]
+		count += sibling.count
+		if sibling.left {
+			position += sibling.count
+			hash = merkle_node_hash(count, &sibling.hash, &hash)
+		} else {
+			hash = merkle_node_hash(count, &hash, &sibling.hash)
+		}
+	}
+
+	return hash, count, position
+}
+
+/* merkle_check_path: Checks that path leads from the leaf at position to the root in state. */
+func merkle_check_path(state *PDP_merkle_state, path *PDP_merkle_path, position uint64) bool {
+
+	if path == nil {
+		return false
+	}
+	root, count, at := merkle_fold(merkle_leaf_hash(path.index), 1, path.siblings)
+
+	return root == state.Root && count == state.NumBlocks && at == position
+}
+
+/* pdp_merkle_sign_root: Signs a root and the block count it covers with RSA-PSS under the client's key. */
+func pdp_merkle_sign_root(key *PDP_key, root [PDP_MERKLE_HASH_SIZE]byte, numfileblocks uint64) []byte {
+
+	if key == nil || key.rsa == nil || key.rsa.D == nil {
+		return nil
+	}
+	digest := merkle_root_digest(root, numfileblocks)
+	signature, err := rsa.SignPSS(rand.Reader, key.rsa, crypto.SHA256, digest[:], nil)
+	if err != nil {
+		return nil
+	}
+
+	return signature
+}
+
+/* pdp_merkle_verify_root: Returns 1 if signature is the client's signature of root, 0 otherwise. */
+func pdp_merkle_verify_root(key *PDP_key, root [PDP_MERKLE_HASH_SIZE]byte, numfileblocks uint64, signature []byte) int {
+
+	if key == nil || key.rsa == nil || key.rsa.N == nil {
+		return 0
+	}
+	digest := merkle_root_digest(root, numfileblocks)
+	if rsa.VerifyPSS(&key.rsa.PublicKey, crypto.SHA256, digest[:], signature, nil) != nil {
+		return 0
+	}
+
+	return 1
+}
+
+func merkle_root_digest(root [PDP_MERKLE_HASH_SIZE]byte, numfileblocks uint64) [sha256.Size]byte {
+	var buf = make([]byte, 0, 4+PDP_MERKLE_HASH_SIZE+8)
+
+	buf = append(buf, PDP_MERKLE_MAGIC...)
+	buf = append(buf, root[:]...)
+	buf = append_u64(buf, numfileblocks)
+
+	return sha256.Sum256(buf)
+}
+
+/* pdp_merkle_prepare_update: Client-side function that prepares op at position, tagging a new block
+ * under the state's next tag index.  The index is used up even if the update is never accepted, so it
+ * is never given to two different blocks.
+ * Returns the request for the server, or ErrBadPosition or ErrBadBlockSize.
+ */
+func (pdpCore *PDPCore) pdp_merkle_prepare_update(key *PDP_key, state *PDP_merkle_state, op int, position uint64,
+	block []byte) (*PDP_merkle_update_request, error) {
+
+	var request = &PDP_merkle_update_request{op: op, position: position}
+
+	switch op {
+	case PDP_BLOCK_MODIFY, PDP_BLOCK_DELETE:
+		if position >= state.NumBlocks {
+			return nil, ErrBadPosition
+		}
+	case PDP_BLOCK_INSERT:
+		if position > state.NumBlocks {
+			return nil, ErrBadPosition
+		}
+	default:
+		return nil, fmt.Errorf("pdp: unknown block operation %d", op)
+	}
+	if op == PDP_BLOCK_DELETE {
+		if state.NumBlocks == 1 {
+			return nil, ErrBadPosition
+		}
+		return request, nil
+	}
+	if len(block) == 0 || len(block) > PDP_BLOCKSIZE {
+		return nil, ErrBadBlockSize
+	}
+
+	request.block = append([]byte{}, block...)
+	request.tag = pdpCore.tag_dynamic_block(key, block, state.Next)
+	if request.tag == nil {
+		return nil, errors.New("pdp: could not tag block")
+	}
+	state.Next++
+
+	return request, nil
+}
+
+/* pdp_merkle_apply_update: Server-side function that applies a client's update to a file, its tag file
+ * and its tree, and saves the tree to treePath, filepath + ".mht" by default.  A new block's tag is
+ * checked first if key can check it; an RSA public key cannot, and a bad tag then only fails the proofs.
+ * The root signature is cleared until the client sends the new one.
+ * Returns the old path and new root for the client to check.
+ */
+func (pdpCore *PDPCore) pdp_merkle_apply_update(key *PDP_key, tree *PDP_merkle_tree, filepath string, tagFilepath string,
+	treePath string, request *PDP_merkle_update_request) (*PDP_merkle_update, error) {
+
+	var update = &PDP_merkle_update{}
+	var path_position = request.position
+	var index uint64
+
+	if treePath == "" {
+		treePath = filepath + PDP_MERKLE_SUFFIX
+	}
+
+	dynamic, err := open_dynamic_file(key, filepath, tagFilepath)
+	if err != nil {
+		return nil, err
+	}
+	defer dynamic.close()
+	if err = dynamic.check_update(request.op, request.position, request.block); err != nil {
+		return nil, err
+	}
+	if request.op != PDP_BLOCK_DELETE {
+		if request.tag == nil {
+			return nil, ErrBadTagFile
+		}
+		/* An RSA tag is checked only with the secret v, which a server holding the public key lacks */
+		if (key.rsa == nil || key.v != nil) && pdpCore.check_pdp_tag_block(key, request.block, request.tag) != 1 {
+			return nil, ErrBadTagFile
+		}
+		index = uint64(request.tag.index)
+	}
+
+	tree.mutex.Lock()
+	defer tree.mutex.Unlock()
+
+	if tree.root.count != dynamic.header.numfileblocks {
+		return nil, ErrBadMerkleTree
+	}
+	if request.op == PDP_BLOCK_DELETE && tree.root.count == 1 {
+		return nil, ErrBadPosition
+	}
+	if request.op == PDP_BLOCK_INSERT && request.position == tree.root.count {
+		path_position--
+	}
+	update.path = merkle_path(tree.root, path_position)
+
+	if err = dynamic.apply_update(request.op, request.position, request.block, request.tag); err != nil {
+		return nil, err
+	}
+	root := merkle_update(tree.root, request.op, request.position, index)
+	if err = dynamic.commit(root.count); err != nil {
+		return nil, err
+	}
+	tree.root, tree.signature = root, nil
+	update.root = root.hash
+
+	if err = write_merkle_tree(treePath, root, nil); err != nil {
+		return nil, err
+	}
+
+	return update, nil
+}
+
+/* pdp_merkle_verify_update: Client-side function that checks the server's answer to request against
+ * state.  The old path must lead to the root the client holds, and the new root must be the one the
+ * path and the update give.  On success state moves to the new root.
+ * Returns the client's signature of the new root, for the server's SetSignature, or ErrMerklePath or
+ * ErrUpdateRejected.
+ */
+func (pdpCore *PDPCore) pdp_merkle_verify_update(key *PDP_key, state *PDP_merkle_state, request *PDP_merkle_update_request,
+	update *PDP_merkle_update) ([]byte, error) {
+
+	var root [PDP_MERKLE_HASH_SIZE]byte
+	var count uint64
+	var path_position = request.position
+	var appending = request.op == PDP_BLOCK_INSERT && request.position == state.NumBlocks
+
+	if update == nil || update.path == nil {
+		return nil, ErrMerklePath
+	}
+	if appending {
+		path_position--
+	}
+	if !merkle_check_path(state, update.path, path_position) {
+		return nil, ErrMerklePath
+	}
+
+	old_leaf := new_merkle_leaf(update.path.index)
+	switch request.op {
+	case PDP_BLOCK_MODIFY:
+		root, count, _ = merkle_fold(merkle_leaf_hash(uint64(request.tag.index)), 1, update.path.siblings)
+	case PDP_BLOCK_INSERT:
+		node := new_merkle_internal(new_merkle_leaf(uint64(request.tag.index)), old_leaf)
+		if appending {
+			node = new_merkle_internal(old_leaf, new_merkle_leaf(uint64(request.tag.index)))
+		}
+		root, count, _ = merkle_fold(node.hash, node.count, update.path.siblings)
+	case PDP_BLOCK_DELETE:
+		if len(update.path.siblings) == 0 {
+			return nil, ErrUpdateRejected
+		}
+		sibling := update.path.siblings[0]
+		root, count, _ = merkle_fold(sibling.hash, sibling.count, update.path.siblings[1:])
+	default:
+		return nil, ErrUpdateRejected
+	}
+	if root != update.root {
+		return nil, ErrUpdateRejected
+	}
+
+	signature := pdp_merkle_sign_root(key, root, count)
+	if signature == nil {
+		return nil, errors.New("pdp: could not sign root")
+	}
+	state.Root = root
+	state.NumBlocks = count
+
+	return signature, nil
+}
+
+/* pdp_merkle_prove_file: Server-side function that proves a challenge over a file with a tree, adding
+ * the path of every challenged block and the root signature to the proof from pdp_prove_file.
+ * Returns ErrUnsignedRoot if the client has not signed the tree's root since the last update.
+ */
+func (pdpCore *PDPCore) pdp_merkle_prove_file(ctx context.Context, tree *PDP_merkle_tree, filepath string, tagFilepath string,
+	challenge *PDP_challenge, key *PDP_key, opts *PDP_prove_options) (*PDP_merkle_proof, error) {
+
+	var merkle_proof = &PDP_merkle_proof{}
+	var err error
+
+	if challenge == nil || challenge.c == 0 {
+		return nil, ErrBadChallenge
+	}
+
+	/* Hold the tree so an update cannot move blocks while they are read */
+	tree.mutex.RLock()
+	defer tree.mutex.RUnlock()
+
+	root := tree.root
+	if uint64(challenge.numfileblocks) != root.count {
+		return nil, ErrBadChallenge
+	}
+	if tree.signature == nil {
+		return nil, ErrUnsignedRoot
+	}
+	merkle_proof.signature = tree.signature
+	indices := pdpCore.generate_prp_pi(challenge)
+	if uint(len(indices)) < challenge.c {
+		return nil, ErrBadChallenge
+	}
+
+	merkle_proof.proof, err = pdpCore.pdp_prove_file(ctx, filepath, tagFilepath, challenge, key, opts)
+	if err != nil {
+		return nil, err
+	}
+	merkle_proof.paths = make([]*PDP_merkle_path, challenge.c)
+	for j := uint(0); j < challenge.c; j++ {
+		merkle_proof.paths[j] = merkle_path(root, uint64(indices[j]))
+	}
+
+	return merkle_proof, nil
+}
+
+/* pdp_merkle_verify_proof: Client-side function that verifies a proof against the verifier's state.
+ * The proof's root signature must be the client's, under key, of the state's root and block count.
+ * Each challenged block's path must lead to that root from the challenged position, and the tag indices
+ * the paths prove are used to verify T and rho.
+ * Returns a 1 if verified, 0 otherwise.
+ */
+func (pdpCore *PDPCore) pdp_merkle_verify_proof(key *PDP_key, state *PDP_merkle_state, challenge *PDP_challenge,
+	merkle_proof *PDP_merkle_proof) int {
+
+	var tag_indices map[uint]uint
+
+	if state == nil || challenge == nil || merkle_proof == nil || merkle_proof.proof == nil {
+		return 0
+	}
+	if uint64(challenge.numfileblocks) != state.NumBlocks || uint(len(merkle_proof.paths)) != challenge.c {
+		return 0
+	}
+	if pdp_merkle_verify_root(key, state.Root, state.NumBlocks, merkle_proof.signature) != 1 {
+		return 0
+	}
+	indices := pdpCore.generate_prp_pi(challenge)
+	if uint(len(indices)) < challenge.c {
+		return 0
+	}
+
+	tag_indices = make(map[uint]uint, challenge.c)
+	for j := uint(0); j < challenge.c; j++ {
+		if !merkle_check_path(state, merkle_proof.paths[j], uint64(indices[j])) {
+			return 0
+		}
+		tag_indices[indices[j]] = uint(merkle_proof.paths[j].index)
+	}
+
+	return pdpCore.verify_proof(key, challenge, merkle_proof.proof, func(position uint) (uint, bool) {
+		index, ok := tag_indices[position]
+		return index, ok
+	})
+}
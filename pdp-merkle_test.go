@@ -0,0 +1,277 @@
+package gopdp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+/* merkle_test_file: Tags a random file of size bytes and builds its tree and the client's state, with
+ * the first root signed.  Returns the key pair, the file's path, the tree and state.
+ */
+func merkle_test_file(t *testing.T, size int) (*PDP_key, string, *PDP_merkle_tree, *PDP_merkle_state) {
+	t.Helper()
+
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	path, data := tag_test_file(t, key, size)
+	numfileblocks := uint64(len(dynamic_test_blocks(data)))
+	tree, err := NewPDPMerkleTree(numfileblocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := core.pdp_merkle_init(numfileblocks)
+	if err := tree.SetSignature(public_test_key(key), pdp_merkle_sign_root(key, state.Root, numfileblocks)); err != nil {
+		t.Fatal(err)
+	}
+
+	return key, path, tree, state
+}
+
+/* update_test_merkle: Prepares, applies and checks op at position, and stores the new root's signature.
+ * Returns the server's answer.
+ */
+func update_test_merkle(t *testing.T, key *PDP_key, path string, tree *PDP_merkle_tree, state *PDP_merkle_state,
+	op int, position uint64, block []byte) *PDP_merkle_update {
+	t.Helper()
+
+	var core = NewPDPCore()
+
+	request, err := core.pdp_merkle_prepare_update(key, state, op, position, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err := core.pdp_merkle_apply_update(public_test_key(key), tree, path, "", "", request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := core.pdp_merkle_verify_update(key, state, request, update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.SetSignature(public_test_key(key), signature); err != nil {
+		t.Fatal(err)
+	}
+
+	return update
+}
+
+/* prove_test_merkle: Challenges every block of the file at path, proved with the tag file given, and
+ * verifies the proof against state.  Returns pdp_merkle_verify_proof's result.
+ */
+func prove_test_merkle(t *testing.T, key *PDP_key, path string, tagpath string, tree *PDP_merkle_tree,
+	state *PDP_merkle_state) int {
+	t.Helper()
+
+	var core = NewPDPCore()
+
+	challenge := core.pdp_challenge(key, uint(state.NumBlocks))
+	proof, err := core.pdp_merkle_prove_file(context.Background(), tree, path, tagpath,
+		core.sanitize_pdp_challenge(challenge), public_test_key(key), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return core.pdp_merkle_verify_proof(key, state, challenge, proof)
+}
+
+func TestMerkleUpdates(t *testing.T) {
+	key, path, tree, state := merkle_test_file(t, 6*PDP_BLOCKSIZE)
+	if prove_test_merkle(t, key, path, "", tree, state) != 1 {
+		t.Fatal("proof of the tagged file did not verify")
+	}
+
+	block, _ := GenerateRandomBytes(PDP_BLOCKSIZE)
+	for _, test := range []struct {
+		name      string
+		op        int
+		position  uint64
+		block     []byte
+		numblocks uint64
+	}{
+		{"modify", PDP_BLOCK_MODIFY, 3, block, 6},
+		{"insert at the front", PDP_BLOCK_INSERT, 0, block, 7},
+		{"insert after the last block", PDP_BLOCK_INSERT, 7, block[:100], 8},
+		{"delete", PDP_BLOCK_DELETE, 2, nil, 7},
+		{"delete the last block", PDP_BLOCK_DELETE, 6, nil, 6},
+	} {
+		update := update_test_merkle(t, key, path, tree, state, test.op, test.position, test.block)
+
+		/* The root the client accepted is the server's, and every leaf's path folds up to it */
+		if state.Root != update.root || tree.root.hash != update.root || state.NumBlocks != test.numblocks {
+			t.Fatalf("%s: client holds a root of %d blocks, not the server's", test.name, state.NumBlocks)
+		}
+		for position := uint64(0); position < state.NumBlocks; position++ {
+			path := merkle_path(tree.root, position)
+			root, count, at := merkle_fold(merkle_leaf_hash(path.index), 1, path.siblings)
+			if root != update.root || count != test.numblocks || at != position {
+				t.Fatalf("%s: path of block %d folds to block %d of %d under another root", test.name, position, at, count)
+			}
+		}
+		if prove_test_merkle(t, key, path, "", tree, state) != 1 {
+			t.Fatalf("%s: proof of the updated file did not verify", test.name)
+		}
+	}
+
+	/* The tree reloads with its signature */
+	if err := tree.Save(path + PDP_MERKLE_SUFFIX); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPDPMerkleTree(path + PDP_MERKLE_SUFFIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.root.hash != state.Root || prove_test_merkle(t, key, path, "", loaded, state) != 1 {
+		t.Fatal("proof with the reloaded tree did not verify")
+	}
+
+	/* A server that kept a replaced block's tag cannot use it */
+	key, path, tree, state = merkle_test_file(t, 6*PDP_BLOCKSIZE)
+	stale_tags, _ := ioutil.ReadFile(path + PDP_TAG_FILE_SUFFIX)
+	ioutil.WriteFile(path+".stale"+PDP_TAG_FILE_SUFFIX, stale_tags, 0644)
+	update_test_merkle(t, key, path, tree, state, PDP_BLOCK_MODIFY, 3, block)
+	if prove_test_merkle(t, key, path, "", tree, state) != 1 {
+		t.Fatal("proof of the modified file did not verify")
+	}
+	if prove_test_merkle(t, key, path, path+".stale"+PDP_TAG_FILE_SUFFIX, tree, state) == 1 {
+		t.Error("proof with the replaced block's tag verified")
+	}
+}
+
+func TestMerkleForgedPath(t *testing.T) {
+	var core = NewPDPCore()
+
+	key, path, tree, state := merkle_test_file(t, 6*PDP_BLOCKSIZE)
+	block, _ := GenerateRandomBytes(PDP_BLOCKSIZE)
+	request, err := core.pdp_merkle_prepare_update(key, state, PDP_BLOCK_MODIFY, 4, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err := core.pdp_merkle_apply_update(public_test_key(key), tree, path, "", "", request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Paths whose sibling hash, rank or side was changed, or that claim another leaf, are refused */
+	for _, test := range []struct {
+		name   string
+		forge  func(path *PDP_merkle_path)
+		reject error
+	}{
+		{"sibling hash", func(path *PDP_merkle_path) { path.siblings[1].hash[0] ^= 1 }, ErrMerklePath},
+		{"sibling rank", func(path *PDP_merkle_path) { path.siblings[0].count++ }, ErrMerklePath},
+		{"sibling side", func(path *PDP_merkle_path) { path.siblings[0].left = !path.siblings[0].left }, ErrMerklePath},
+		{"leaf", func(path *PDP_merkle_path) { path.index = 5 }, ErrMerklePath},
+		{"no siblings", func(path *PDP_merkle_path) { path.siblings = nil }, ErrMerklePath},
+	} {
+		forged := &PDP_merkle_update{root: update.root, path: &PDP_merkle_path{index: update.path.index,
+			siblings: append([]PDP_merkle_sibling(nil), update.path.siblings...)}}
+		test.forge(forged.path)
+		if _, err := core.pdp_merkle_verify_update(key, state, request, forged); !errors.Is(err, test.reject) {
+			t.Errorf("forged %s: %v", test.name, err)
+		}
+	}
+
+	/* A path for another position is refused, and so is a root that does not follow from the path */
+	wrong := *request
+	wrong.position = 3
+	if _, err := core.pdp_merkle_verify_update(key, state, &wrong, update); !errors.Is(err, ErrMerklePath) {
+		t.Errorf("path of the wrong position: %v", err)
+	}
+	forged := *update
+	forged.root[0] ^= 1
+	if _, err := core.pdp_merkle_verify_update(key, state, request, &forged); !errors.Is(err, ErrUpdateRejected) {
+		t.Errorf("forged root: %v", err)
+	}
+
+	/* None of these moved the client's state, and the genuine answer is still accepted */
+	signature, err := core.pdp_merkle_verify_update(key, state, request, update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.SetSignature(public_test_key(key), signature); err != nil {
+		t.Fatal(err)
+	}
+
+	/* A proof whose paths were swapped does not verify */
+	challenge := core.pdp_challenge(key, uint(state.NumBlocks))
+	proof, err := core.pdp_merkle_prove_file(context.Background(), tree, path, "", core.sanitize_pdp_challenge(challenge),
+		public_test_key(key), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.pdp_merkle_verify_proof(key, state, challenge, proof) != 1 {
+		t.Fatal("proof did not verify")
+	}
+	proof.paths[0], proof.paths[1] = proof.paths[1], proof.paths[0]
+	if core.pdp_merkle_verify_proof(key, state, challenge, proof) == 1 {
+		t.Error("proof with swapped paths verified")
+	}
+}
+
+func TestMerkleRootSignature(t *testing.T) {
+	var core = NewPDPCore()
+
+	key, path, tree, state := merkle_test_file(t, 4*PDP_BLOCKSIZE)
+	other := new_test_key(t)
+	old := tree.Signature()
+
+	block, _ := GenerateRandomBytes(PDP_BLOCKSIZE)
+	request, _ := core.pdp_merkle_prepare_update(key, state, PDP_BLOCK_MODIFY, 1, block)
+	update, err := core.pdp_merkle_apply_update(public_test_key(key), tree, path, "", "", request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := core.pdp_merkle_verify_update(key, state, request, update)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Until the new root is signed, the server has nothing to prove with */
+	if tree.Signature() != nil {
+		t.Fatal("signature of the old root kept after an update")
+	}
+	challenge := core.sanitize_pdp_challenge(core.pdp_challenge(key, uint(state.NumBlocks)))
+	if _, err := core.pdp_merkle_prove_file(context.Background(), tree, path, "", challenge, public_test_key(key), nil); !errors.Is(err, ErrUnsignedRoot) {
+		t.Fatalf("proof with an unsigned root: %v", err)
+	}
+
+	/* A tampered signature, the old root's, or another key's is refused */
+	tampered := append([]byte(nil), signature...)
+	tampered[len(tampered)/2] ^= 1
+	for _, test := range []struct {
+		name      string
+		signature []byte
+	}{
+		{"tampered", tampered},
+		{"old root", old},
+		{"other key", pdp_merkle_sign_root(other, state.Root, state.NumBlocks)},
+		{"other block count", pdp_merkle_sign_root(key, state.Root, state.NumBlocks+1)},
+		{"empty", nil},
+	} {
+		if err := tree.SetSignature(public_test_key(key), test.signature); !errors.Is(err, ErrUpdateRejected) {
+			t.Errorf("%s signature: %v", test.name, err)
+		}
+		if tree.Signature() != nil {
+			t.Fatalf("%s signature was stored", test.name)
+		}
+	}
+
+	/* and an auditor refuses a proof under a root the client did not sign */
+	if err := tree.SetSignature(public_test_key(key), signature); err != nil {
+		t.Fatal(err)
+	}
+	if prove_test_merkle(t, key, path, "", tree, state) != 1 {
+		t.Fatal("proof under the signed root did not verify")
+	}
+	tree.signature = tampered
+	if prove_test_merkle(t, key, path, "", tree, state) == 1 {
+		t.Error("proof with a tampered root signature verified")
+	}
+	tree.signature = pdp_merkle_sign_root(other, state.Root, state.NumBlocks)
+	if prove_test_merkle(t, key, path, "", tree, state) == 1 {
+		t.Error("proof with another key's root signature verified")
+	}
+}
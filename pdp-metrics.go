@@ -0,0 +1,177 @@
+package gopdp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/* The metrics are always collected into pdp_metrics_registry.  Serve them with PDPMetricsHandler, or
+ * add them to an application's own registry with RegisterPDPMetrics.
+ */
+var pdp_metrics_registry = prometheus.NewRegistry()
+
+var (
+	pdp_audits_attempted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pdp",
+		Name:      "audits_attempted_total",
+		Help:      "Remote audits started, per file and scheme.",
+	}, []string{"file", "scheme"})
+
+	pdp_audits_passed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pdp",
+		Name:      "audits_passed_total",
+		Help:      "Remote audits whose proof verified, per file and scheme.",
+	}, []string{"file", "scheme"})
+
+	pdp_audits_failed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pdp",
+		Name:      "audits_failed_total",
+		Help:      "Remote audits that did not pass, per file, scheme and reason.",
+	}, []string{"file", "scheme", "reason"})
+
+	pdp_audit_seconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "pdp",
+		Name:      "audit_duration_seconds",
+		Help:      "Time for a remote audit, from file info to verification.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+	}, []string{"scheme"})
+
+	pdp_proof_seconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "pdp",
+		Name:      "proof_generation_seconds",
+		Help:      "Time to generate a proof for a tagged file.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+	}, []string{"scheme"})
+
+	pdp_proof_read_bytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "pdp",
+		Name:      "proof_read_bytes",
+		Help:      "Block and tag bytes read to generate a proof.",
+		Buckets:   prometheus.ExponentialBuckets(64<<10, 2, 14),
+	}, []string{"scheme"})
+
+	pdp_proof_errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pdp",
+		Name:      "proof_errors_total",
+		Help:      "Proofs that could not be generated, per reason.",
+	}, []string{"scheme", "reason"})
+
+	pdp_verify_seconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "pdp",
+		Name:      "verify_duration_seconds",
+		Help:      "Time to verify a proof.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+	}, []string{"scheme"})
+
+	pdp_tag_blocks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pdp",
+		Name:      "tag_blocks_total",
+		Help:      "Blocks tagged and written to tag files.",
+	}, []string{"scheme"})
+
+	pdp_tag_bytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "pdp",
+		Name:      "tag_bytes_total",
+		Help:      "File bytes tagged; its rate is the tagging throughput.",
+	}, []string{"scheme"})
+)
+
+func init() {
+	pdp_metrics_registry.MustRegister(pdp_metrics_collectors()...)
+}
+
+func pdp_metrics_collectors() []prometheus.Collector {
+	return []prometheus.Collector{pdp_audits_attempted, pdp_audits_passed, pdp_audits_failed,
+		pdp_audit_seconds, pdp_proof_seconds, pdp_proof_read_bytes, pdp_proof_errors, pdp_verify_seconds,
+		pdp_tag_blocks, pdp_tag_bytes}
+}
+
+/* PDPMetricsHandler: Returns an http.Handler serving the package's metrics, for a /metrics endpoint. */
+func PDPMetricsHandler() http.Handler {
+	return promhttp.HandlerFor(pdp_metrics_registry, promhttp.HandlerOpts{})
+}
+
+/* RegisterPDPMetrics: Adds the package's metrics to registerer as well, e.g. prometheus.DefaultRegisterer. */
+func RegisterPDPMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range pdp_metrics_collectors() {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* pdp_scheme: The scheme label for key, "bls" or "mac" for those keys and otherwise the RSA variant this build
+ * uses.
+ */
+func pdp_scheme(key *PDP_key) string {
+	if key != nil && key.bls != nil {
+		return "bls"
+	}
+	if key != nil && key.mac != nil {
+		return "mac"
+	}
+	if USE_E_PDP == 1 {
+		return "e-pdp"
+	}
+	return "s-pdp"
+}
+
+/* failure_reason: A low-cardinality label for why an audit or proof failed. */
+func failure_reason(err error) string {
+	var aborted *PDP_prove_aborted
+
+	switch {
+	case errors.Is(err, ErrProofRejected):
+		return "rejected"
+	case errors.Is(err, ErrKeyMismatch):
+		return "key_mismatch"
+	case errors.Is(err, ErrBlockCountMismatch):
+		return "block_count_mismatch"
+	case errors.Is(err, ErrNoBlockCount):
+		return "no_block_count"
+	case errors.Is(err, ErrUnknownFile):
+		return "unknown_file"
+	case errors.Is(err, ErrPermissionDenied):
+		return "permission_denied"
+	case errors.Is(err, ErrBadWireFormat), errors.Is(err, ErrBadChallenge):
+		return "bad_message"
+	case errors.Is(err, ErrMemoryBudget):
+		return "memory_budget"
+	case errors.As(err, &aborted) && aborted.Timeout(), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &aborted), errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+
+	return "error"
+}
+
+/* observe_audit: Records a finished remote audit of fileID under key started at start. */
+func observe_audit(key *PDP_key, fileID string, start time.Time, err error) {
+	var scheme = pdp_scheme(key)
+
+	pdp_audit_seconds.WithLabelValues(scheme).Observe(time.Since(start).Seconds())
+	if err == nil {
+		pdp_audits_passed.WithLabelValues(fileID, scheme).Inc()
+	} else {
+		pdp_audits_failed.WithLabelValues(fileID, scheme, failure_reason(err)).Inc()
+	}
+}
+
+/* observe_proof: Records a proof under key generated from read_bytes of blocks and tags, or its failure. */
+func observe_proof(key *PDP_key, start time.Time, read_bytes uint64, err error) {
+	var scheme = pdp_scheme(key)
+
+	if err != nil {
+		pdp_proof_errors.WithLabelValues(scheme, failure_reason(err)).Inc()
+		return
+	}
+	pdp_proof_seconds.WithLabelValues(scheme).Observe(time.Since(start).Seconds())
+	pdp_proof_read_bytes.WithLabelValues(scheme).Observe(float64(read_bytes))
+}
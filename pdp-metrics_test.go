@@ -0,0 +1,154 @@
+package gopdp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+/* reset_test_metrics: Clears every series of the package's metrics, so a test counts only its own. */
+func reset_test_metrics() {
+	for _, collector := range pdp_metrics_collectors() {
+		switch vec := collector.(type) {
+		case *prometheus.CounterVec:
+			vec.Reset()
+		case *prometheus.HistogramVec:
+			vec.Reset()
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	var ctx = context.Background()
+
+	reset_test_metrics()
+
+	/* An RSA file audited twice, the second time after it was corrupted */
+	key, server, path, data := serve_test_file(t, 5)
+	rsa := pdp_scheme(key)
+	if want := map[bool]string{false: "s-pdp", true: "e-pdp"}[USE_E_PDP == 1]; rsa != want {
+		t.Fatalf("RSA key is labelled %q, want %q", rsa, want)
+	}
+	client := grpc_test_client(t, server, key)
+	if err := client.Audit(ctx, "data", 5); err != nil {
+		t.Fatal(err)
+	}
+	corrupt_test_file(t, path, data)
+	if err := client.Audit(ctx, "data", 5); err == nil {
+		t.Fatal("audit of a corrupted file passed")
+	}
+
+	/* A BLS file audited once and a MAC file only tagged */
+	bls_key := generate_bls_key()
+	bls_path, name := bls_test_file(t, bls_key, 2*PDP_BLOCKSIZE+100)
+	bls_server := NewPDPServer(bls_public_key(bls_key), nil)
+	if err := bls_server.AddFile("bls-data", bls_path, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := grpc_test_client(t, bls_server, pdp_named_key(bls_key, name)).Audit(ctx, "bls-data", 3); err != nil {
+		t.Fatal(err)
+	}
+	bls_test_file(t, generate_mac_key(), PDP_BLOCKSIZE+100)
+
+	/* Audits are counted per file and scheme, and failures per reason */
+	expected := fmt.Sprintf(`
+# HELP pdp_audits_attempted_total Remote audits started, per file and scheme.
+# TYPE pdp_audits_attempted_total counter
+pdp_audits_attempted_total{file="bls-data",scheme="bls"} 1
+pdp_audits_attempted_total{file="data",scheme="%[1]s"} 2
+# HELP pdp_audits_failed_total Remote audits that did not pass, per file, scheme and reason.
+# TYPE pdp_audits_failed_total counter
+pdp_audits_failed_total{file="data",reason="rejected",scheme="%[1]s"} 1
+# HELP pdp_audits_passed_total Remote audits whose proof verified, per file and scheme.
+# TYPE pdp_audits_passed_total counter
+pdp_audits_passed_total{file="bls-data",scheme="bls"} 1
+pdp_audits_passed_total{file="data",scheme="%[1]s"} 1
+# HELP pdp_tag_blocks_total Blocks tagged and written to tag files.
+# TYPE pdp_tag_blocks_total counter
+pdp_tag_blocks_total{scheme="bls"} 3
+pdp_tag_blocks_total{scheme="mac"} 2
+pdp_tag_blocks_total{scheme="%[1]s"} 5
+# HELP pdp_tag_bytes_total File bytes tagged; its rate is the tagging throughput.
+# TYPE pdp_tag_bytes_total counter
+pdp_tag_bytes_total{scheme="bls"} %[2]d
+pdp_tag_bytes_total{scheme="mac"} %[3]d
+pdp_tag_bytes_total{scheme="%[1]s"} %[4]d
+`, rsa, 2*PDP_BLOCKSIZE+100, PDP_BLOCKSIZE+100, 4*PDP_BLOCKSIZE+100)
+	if err := testutil.GatherAndCompare(pdp_metrics_registry, strings.NewReader(expected),
+		"pdp_audits_attempted_total", "pdp_audits_failed_total", "pdp_audits_passed_total",
+		"pdp_tag_blocks_total", "pdp_tag_bytes_total"); err != nil {
+		t.Error(err)
+	}
+
+	/* Every proof was generated, and every audit that got one verified it, under its scheme's label */
+	for _, test := range []struct {
+		collector prometheus.Collector
+		series    int
+	}{
+		{pdp_audit_seconds, 2}, {pdp_proof_seconds, 2}, {pdp_proof_read_bytes, 2}, {pdp_verify_seconds, 2},
+		{pdp_proof_errors, 0},
+	} {
+		if n := testutil.CollectAndCount(test.collector); n != test.series {
+			t.Errorf("%d series of %T, want %d", n, test.collector, test.series)
+		}
+	}
+	for _, test := range []struct {
+		name   string
+		scheme string
+		count  uint64
+	}{
+		{"pdp_proof_generation_seconds", rsa, 2}, {"pdp_proof_generation_seconds", "bls", 1},
+		{"pdp_verify_duration_seconds", rsa, 2}, {"pdp_verify_duration_seconds", "bls", 1},
+		{"pdp_audit_duration_seconds", rsa, 2}, {"pdp_audit_duration_seconds", "bls", 1},
+	} {
+		if count := histogram_test_count(t, test.name, test.scheme); count != test.count {
+			t.Errorf("%s{scheme=%q} observed %d times, want %d", test.name, test.scheme, count, test.count)
+		}
+	}
+
+	/* Nothing lands in the default registry, but the metrics can be added to another */
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if strings.HasPrefix(family.GetName(), "pdp_") {
+			t.Errorf("%s is registered on the default registry", family.GetName())
+		}
+	}
+	registry := prometheus.NewRegistry()
+	if err := RegisterPDPMetrics(registry); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := testutil.GatherAndCount(registry, "pdp_audits_attempted_total"); err != nil || n != 2 {
+		t.Errorf("%d audit series in another registry: %v", n, err)
+	}
+}
+
+/* histogram_test_count: The number of observations of the histogram name with scheme label scheme. */
+func histogram_test_count(t *testing.T, name string, scheme string) uint64 {
+	t.Helper()
+
+	families, err := pdp_metrics_registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "scheme" && label.GetValue() == scheme {
+					return metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,216 @@
+package gopdp
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"math/big"
+)
+
+/* The number of Feistel rounds of the index permutation pi */
+const PDP_PRP_ROUNDS = 4
+
+/* generate_pdp_tag: Allocates an empty PDP tag structure. */
+func (pdpCore *PDPCore) generate_pdp_tag() *PDP_tag {
+	return &PDP_tag{index_prf_size: new(uint64)}
+}
+
+/* generate_pdp_challenge: Allocates an empty PDP challenge structure. */
+func (pdpCore *PDPCore) generate_pdp_challenge() *PDP_challenge {
+	return &PDP_challenge{}
+}
+
+/* generate_pdp_proof: Allocates a PDP proof structure with T and rho_temp zero, which
+ * pdp_generate_proof_update takes as an empty proof.
+ */
+func (pdpCore *PDPCore) generate_pdp_proof() *PDP_proof {
+	return &PDP_proof{T: new(big.Int), rho_temp: new(big.Int)}
+}
+
+/* sanitize_pdp_challenge: Returns a copy of challenge without the secret s, which is safe to send to the
+ * server.
+ */
+func (pdpCore *PDPCore) sanitize_pdp_challenge(challenge *PDP_challenge) *PDP_challenge {
+	var server_challenge PDP_challenge
+
+	if challenge == nil {
+		return nil
+	}
+	server_challenge = *challenge
+	server_challenge.s = nil
+	server_challenge.replica = nil
+
+	return &server_challenge
+}
+
+/* hmac_sha1_index: HMAC-SHA1 under key of the big-endian 64 bit index. */
+func hmac_sha1_index(key []byte, index uint64) []byte {
+	var buf [8]byte
+
+	mac := hmac.New(sha1.New, key)
+	binary.BigEndian.PutUint64(buf[:], index)
+	mac.Write(buf[:])
+
+	return mac.Sum(nil)
+}
+
+/* generate_prf_w: The pseudo-random function W_i = w_v(i), HMAC-SHA1 of the block index keyed with v.
+ * Stores the size of the result in prf_result_size and returns it, or nil on failure.
+ */
+func (pdpCore *PDPCore) generate_prf_w(key *PDP_key, index uint, prf_result_size *uint64) *string {
+
+	if key == nil || key.v == nil || prf_result_size == nil {
+		return nil
+	}
+
+	prf_result := string(hmac_sha1_index([]byte(*key.v), uint64(index)))
+	*prf_result_size = uint64(len(prf_result))
+
+	return &prf_result
+}
+
+/* generate_prf_f: The pseudo-random function f_k2(j) giving the coefficient of the jth challenged block,
+ * HMAC-SHA1 of j keyed with k2.  Stores the size of the result in prf_result_size and returns it, or nil
+ * on failure.
+ */
+func (pdpCore *PDPCore) generate_prf_f(challenge *PDP_challenge, j uint, prf_result_size *uint64) *string {
+
+	if challenge == nil || challenge.k2 == nil || prf_result_size == nil {
+		return nil
+	}
+
+	prf_result := string(hmac_sha1_index(*challenge.k2, uint64(j)))
+	*prf_result_size = uint64(len(prf_result))
+
+	return &prf_result
+}
+
+/* generate_fdh_h: The full-domain hash h of W_i into QR_N.  SHA-1 of W_i and a counter is concatenated
+ * until it is 64 bits longer than N, reduced mod N and squared.  Returns nil on failure.
+ */
+func (pdpCore *PDPCore) generate_fdh_h(key *PDP_key, index_prf *string, index_prf_size uint64) *big.Int {
+	var digest []byte
+	var counter [4]byte
+	var h *big.Int
+
+	if key == nil || key.rsa == nil || key.rsa.N == nil || index_prf == nil {
+		return nil
+	}
+	data := []byte(*index_prf)
+	if uint64(len(data)) > index_prf_size {
+		data = data[:index_prf_size]
+	}
+
+	size := (key.rsa.N.BitLen() + 64 + 7) / 8
+	for i := uint32(0); len(digest) < size; i++ {
+		binary.BigEndian.PutUint32(counter[:], i)
+		hash := sha1.New()
+		hash.Write(data)
+		hash.Write(counter[:])
+		digest = hash.Sum(digest)
+	}
+
+	h = new(big.Int).SetBytes(digest[:size])
+	h.Mod(h, key.rsa.N)
+
+	return h.Exp(h, big.NewInt(2), key.rsa.N)
+}
+
+/* generate_H: The hash H used on the proof, SHA-1 of the big-endian bytes of input.  Stores the size of
+ * the result in H_result_size if it isn't nil.
+ */
+func (pdpCore *PDPCore) generate_H(input *big.Int, H_result_size *uint64) *string {
+
+	if input == nil {
+		return nil
+	}
+
+	digest := sha1.Sum(input.Bytes())
+	H_result := string(digest[:])
+	if H_result_size != nil {
+		*H_result_size = uint64(len(H_result))
+	}
+
+	return &H_result
+}
+
+/* generate_prp_pi: The pseudo-random permutation pi_k1 over the block indices [0, numfileblocks).
+ * It is a Feistel network of PDP_PRP_ROUNDS rounds with AES under k1 as round function over the
+ * smallest even number of bits covering numfileblocks, cycle-walked back into range.
+ * Returns the c challenged indices pi(0) ... pi(c-1), or nil on failure.
+ */
+func (pdpCore *PDPCore) generate_prp_pi(challenge *PDP_challenge) []uint {
+	var indices []uint
+	var in [aes.BlockSize]byte
+	var out [aes.BlockSize]byte
+
+	if challenge == nil || challenge.k1 == nil || challenge.numfileblocks == 0 {
+		return nil
+	}
+	if challenge.c > challenge.numfileblocks {
+		return nil
+	}
+	cipher, err := aes.NewCipher(*challenge.k1)
+	if err != nil {
+		return nil
+	}
+
+	/* Each half of the Feistel network has half_bits bits */
+	n := uint64(challenge.numfileblocks)
+	half_bits := uint((new(big.Int).SetUint64(n-1).BitLen() + 1) / 2)
+	if half_bits == 0 {
+		half_bits = 1
+	}
+	mask := uint64(1)<<half_bits - 1
+
+	indices = make([]uint, challenge.c)
+	for j := uint64(0); j < uint64(challenge.c); j++ {
+		x := j
+		for {
+			left, right := x>>half_bits, x&mask
+			for round := 0; round < PDP_PRP_ROUNDS; round++ {
+				/* F_k1(round, right) is the first 64 bits of AES_k1(round | right) */
+				binary.BigEndian.PutUint64(in[:8], uint64(round))
+				binary.BigEndian.PutUint64(in[8:], right)
+				cipher.Encrypt(out[:], in[:])
+				left, right = right, left^(binary.BigEndian.Uint64(out[:8])&mask)
+			}
+			x = left<<half_bits | right
+			if x < n {
+				break
+			}
+		}
+		indices[j] = uint(x)
+	}
+
+	return indices
+}
+
+/* pick_pdp_generator: Picks a generator g of QR_N, the square of a random element of Z*_N which is not 1.
+ * Returns nil on failure.
+ */
+func pick_pdp_generator(n *big.Int) *big.Int {
+	var a *big.Int
+	var g *big.Int
+	var err error
+
+	if n == nil || n.Sign() <= 0 {
+		return nil
+	}
+
+	for {
+		a, err = rand.Int(rand.Reader, n)
+		if err != nil {
+			return nil
+		}
+		if new(big.Int).GCD(nil, nil, a, n).Cmp(bigOne) != 0 {
+			continue
+		}
+		g = new(big.Int).Exp(a, big.NewInt(2), n)
+		if g.Cmp(bigOne) != 0 {
+			return g
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package gopdp
+
+import (
+	"math/big"
+)
+
+const (
+	PDP_MULTIEXP_MAX_WINDOW = 16
+)
+
+/* pdp_generate_proof_batch: Creates a PDP proof over all challenged blocks at once.  tags[j], blocks[j]
+ * and blocksizes[j] must be the tag, data and size of the j-th challenged block, i.e. block pi_k1(j),
+ * exactly as pdp_generate_proof_update would be called with j.  T = T_1^a_1 * ... * T_c^a_c is computed
+ * with a single multi-exponentiation instead of c separate ones.  As with update, a call to
+ * pdp_generate_proof_final must follow.  Returns the new proof structure or NULL on failure.
+ */
+func (pdpCore *PDPCore) pdp_generate_proof_batch(key *PDP_key, challenge *PDP_challenge, tags []*PDP_tag,
+	blocks []*string, blocksizes []uint64) *PDP_proof {
+
+	var proof *PDP_proof
+	var bases []*big.Int
+	var coefficients []*big.Int
+	var prf_result *string
+	var prf_result_size uint64 = 0
+
+	if !valid_pdp_key(key) || challenge == nil {
+		return nil
+	}
+	if len(tags) == 0 || len(tags) != len(blocks) || len(tags) != len(blocksizes) {
+		return nil
+	}
+
+	if key.bls != nil || key.mac != nil {
+		var js = make([]uint, len(tags))
+		var data = make([][]byte, len(tags))
+		for j := range tags {
+			if blocks[j] == nil {
+				return nil
+			}
+			js[j] = uint(j)
+			data[j] = block_data(blocks[j], blocksizes[j])
+		}
+		if key.mac != nil {
+			return pdpCore.mac_generate_proof_update(challenge, nil, js, tags, data)
+		}
+		return pdpCore.bls_generate_proof_update(challenge, nil, js, tags, data)
+	}
+
+	proof = pdpCore.generate_pdp_proof()
+	proof.rho_temp = new(big.Int)
+	bases = make([]*big.Int, len(tags))
+	coefficients = make([]*big.Int, len(tags))
+
+	for j := range tags {
+		if tags[j] == nil || tags[j].Tim == nil || blocks[j] == nil {
+			return nil
+		}
+		bases[j] = tags[j].Tim
+
+		if USE_E_PDP == 1 { /* Use E-PDP */
+			coefficients[j] = bigOne
+		} else { /* Use S-PDP */
+			/* Compute the coefficient a_j = f_k2(j) */
+			prf_result = pdpCore.generate_prf_f(challenge, uint(j), &prf_result_size)
+			if prf_result == nil {
+				return nil
+			}
+			coefficients[j] = prf_to_bn(prf_result, prf_result_size)
+		}
+
+		/* rho_temp = sum of (coefficient_a_j * message_j) */
+		r0 := new(big.Int).Mul(coefficients[j], block_to_bn(blocks[j], blocksizes[j]))
+		proof.rho_temp.Add(proof.rho_temp, r0)
+	}
+
+	/* T = T_1^a_1 * ... * T_c^a_c mod N */
+	proof.T = multi_exp(bases, coefficients, key.rsa.N)
+
+	return proof
+}
+
+/* multi_exp: Computes bases[0]^exponents[0] * ... * bases[n-1]^exponents[n-1] mod m with Pippenger's
+ * bucket method.  The exponents are cut into windows of w bits; for each window every base is multiplied
+ * into the bucket for its digit, and the buckets are combined as prod_d bucket[d]^d with two running
+ * products.  The window is chosen to minimise ceil(bits/w) * (n + 2^(w+1)) multiplications.
+ */
+func multi_exp(bases []*big.Int, exponents []*big.Int, m *big.Int) *big.Int {
+	var bits int = 0
+	var window int
+	var windows int
+	var result *big.Int
+	var buckets []*big.Int
+
+	for _, e := range exponents {
+		if e.BitLen() > bits {
+			bits = e.BitLen()
+		}
+	}
+	if bits == 0 {
+		return new(big.Int).Mod(bigOne, m)
+	}
+
+	window = multi_exp_window(len(bases), bits)
+	windows = (bits + window - 1) / window
+	buckets = make([]*big.Int, 1<<uint(window))
+
+	for w := windows - 1; w >= 0; w-- {
+
+		/* result = result^(2^window) */
+		if result != nil {
+			for k := 0; k < window; k++ {
+				result = mul_mod(result, result, result, m)
+			}
+		}
+
+		for d := range buckets {
+			buckets[d] = nil
+		}
+		for i, base := range bases {
+			d := window_digit(exponents[i], w*window, window)
+			if d == 0 {
+				continue
+			}
+			if buckets[d] == nil {
+				buckets[d] = new(big.Int).Mod(base, m)
+			} else {
+				buckets[d] = mul_mod(buckets[d], buckets[d], base, m)
+			}
+		}
+
+		/* sum = prod_d bucket[d]^d, as the product of the running products from the top bucket down */
+		var running *big.Int
+		var sum *big.Int
+		for d := len(buckets) - 1; d > 0; d-- {
+			if buckets[d] != nil {
+				if running == nil {
+					running = new(big.Int).Set(buckets[d])
+				} else {
+					running = mul_mod(running, running, buckets[d], m)
+				}
+			}
+			if running != nil {
+				if sum == nil {
+					sum = new(big.Int).Set(running)
+				} else {
+					sum = mul_mod(sum, sum, running, m)
+				}
+			}
+		}
+
+		if sum != nil {
+			if result == nil {
+				result = sum
+			} else {
+				result = mul_mod(result, result, sum, m)
+			}
+		}
+	}
+
+	if result == nil {
+		return new(big.Int).Mod(bigOne, m)
+	}
+
+	return result
+}
+
+/* multi_exp_window: Picks the bucket window size for n bases and exponents of the given bit length. */
+func multi_exp_window(n int, bits int) int {
+	var best int = 1
+	var best_cost int = -1
+
+	for w := 1; w <= PDP_MULTIEXP_MAX_WINDOW; w++ {
+		cost := ((bits + w - 1) / w) * (n + (2 << uint(w)))
+		if best_cost < 0 || cost < best_cost {
+			best = w
+			best_cost = cost
+		}
+	}
+
+	return best
+}
+
+/* window_digit: Returns bits [offset, offset+window) of e. */
+func window_digit(e *big.Int, offset int, window int) int {
+	var digit uint = 0
+
+	for k := window - 1; k >= 0; k-- {
+		digit = (digit << 1) | e.Bit(offset+k)
+	}
+
+	return int(digit)
+}
+
+/* mul_mod: Sets z = a * b mod m and returns z. */
+func mul_mod(z *big.Int, a *big.Int, b *big.Int, m *big.Int) *big.Int {
+	z.Mul(a, b)
+	return z.Mod(z, m)
+}
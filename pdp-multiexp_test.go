@@ -0,0 +1,102 @@
+package gopdp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+/* random_multi_exp: n random bases in Z_m and n coefficients of the size f_k2 gives. */
+func random_multi_exp(m *big.Int, n int) ([]*big.Int, []*big.Int) {
+	var bases = make([]*big.Int, n)
+	var exponents = make([]*big.Int, n)
+	var coefficient = make([]byte, PRF_KEY_SIZE)
+
+	for j := 0; j < n; j++ {
+		bases[j], _ = rand.Int(rand.Reader, m)
+		rand.Read(coefficient)
+		exponents[j] = new(big.Int).SetBytes(coefficient)
+	}
+
+	return bases, exponents
+}
+
+/* naive_multi_exp: The product of the separate exponentiations, as pdp_generate_proof_update computes it. */
+func naive_multi_exp(bases []*big.Int, exponents []*big.Int, m *big.Int) *big.Int {
+	var result = big.NewInt(1)
+
+	for j := range bases {
+		result = ModMul(result, new(big.Int).Exp(bases[j], exponents[j], m), m)
+	}
+
+	return result
+}
+
+func TestMultiExp(t *testing.T) {
+	m, _ := rand.Prime(rand.Reader, 512)
+
+	for _, n := range []int{1, 2, 7, 300} {
+		bases, exponents := random_multi_exp(m, n)
+		if multi_exp(bases, exponents, m).Cmp(naive_multi_exp(bases, exponents, m)) != 0 {
+			t.Fatalf("n=%d: multi_exp differs from the product of Exp", n)
+		}
+	}
+
+	/* Zero and one-bit exponents */
+	bases, _ := random_multi_exp(m, 3)
+	exponents := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(0)}
+	if multi_exp(bases, exponents, m).Cmp(bases[1]) != 0 {
+		t.Fatal("multi_exp with trivial exponents")
+	}
+}
+
+func TestProofBatchMatchesUpdate(t *testing.T) {
+	var core = NewPDPCore()
+	var update *PDP_proof
+	var tags []*PDP_tag
+	var blocks []*string
+	var sizes []uint64
+
+	key := new_test_key(t)
+	_, data := write_test_file(t, 9*PDP_BLOCKSIZE+5)
+	challenge := core.pdp_challenge(key, 10)
+	for j, index := range core.generate_prp_pi(challenge) {
+		block := string(test_block(data, int(index)))
+		size := uint64(len(block))
+		tag := core.pdp_tag_block(key, &block, &size, index)
+
+		update = core.pdp_generate_proof_update(key, challenge, tag, update, &block, &size, uint(j))
+		tags = append(tags, tag)
+		blocks = append(blocks, &block)
+		sizes = append(sizes, size)
+	}
+	batch := core.pdp_generate_proof_batch(key, challenge, tags, blocks, sizes)
+
+	if batch.T.Cmp(update.T) != 0 || batch.rho_temp.Cmp(update.rho_temp) != 0 {
+		t.Fatal("batch proof differs from the updated proof")
+	}
+	if core.pdp_verify_proof(key, challenge, core.pdp_generate_proof_final(key, challenge, batch)) != 1 {
+		t.Fatal("batch proof did not verify")
+	}
+}
+
+func BenchmarkMultiExp(b *testing.B) {
+	for _, bits := range []int{1024, 2048} {
+		m, _ := rand.Prime(rand.Reader, bits)
+		for _, c := range []int{300, MAGIC_NUM_CHALLENGE_BLOCKS, 1000} {
+			bases, exponents := random_multi_exp(m, c)
+
+			b.Run(fmt.Sprintf("Exp/%d/c=%d", bits, c), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					naive_multi_exp(bases, exponents, m)
+				}
+			})
+			b.Run(fmt.Sprintf("multi_exp/%d/c=%d", bits, c), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					multi_exp(bases, exponents, m)
+				}
+			})
+		}
+	}
+}
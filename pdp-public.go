@@ -0,0 +1,326 @@
+package gopdp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	RSA "crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"math/big"
+)
+
+/* Public verifiability: a file tagged under a per-file key, whose PRF key v_f = HMAC-SHA256(v, "PDP file" |
+ * file ID) stands in for v, can be audited by anyone holding N, e_f, g and v_f.  Revealing v_f reveals
+ * nothing about v or any other file's key, and the tags still need d_f to forge.
+ *
+ * The file key's exponent is not the key pair's e but the Mersenne prime e_f = 2^44497 - 1, with
+ * d_f = e_f^-1 mod phi(N).  With a small e such as 65537 a public prover need not keep the blocks: it
+ * can keep m_i mod e and T_i * g^-(m_i div e) instead, answer every public challenge with them, and
+ * pass, since nothing but g^M ties M to the data.  e_f is larger than any block, plain or replica, so
+ * m_i div e_f is always 0 and m_i mod e_f is the whole block.  e_f is also larger than N and so prime
+ * to phi(N).  Tagging and the tag check reduce e_f by p-1 and q-1; only the verifier's T^e_f costs
+ * the full 44497 squarings.
+ *
+ * A public challenge is <c, k1, k2> with no secret s, so it has no g_s.  The prover answers it with T
+ * and M = a_1 m_1 + ... + a_c m_c itself rather than rho = H(g_s^M), and the verifier checks
+ * T^e / prod h(W_i)^a_i == g^M.  M is a linear combination of the challenged blocks, so enough public
+ * proofs of a file let the auditor solve for its blocks.
+ *
+ * A prover that must not reveal the data masks M instead, see pdp_mask_proof.  It picks a random r
+ * PDP_MASK_SECURITY bits longer than gamma * M could be, sends R = g^r and mu = r + gamma * M, where
+ * gamma = H(challenge | T | R), and the verifier checks g^mu == R * (T^e / prod h(W_i)^a_i)^gamma.
+ * mu is statistically independent of M and R of the data, so no number of audits reveals a block.
+ *
+ * A public file key is stored as:
+ *
+ *   magic[4] | version u32 | file ID | N | e u64 | g | v_f
+ *
+ * with the file ID and the BIGNUMs u32 length-prefixed, as on the wire.  e is the key pair's exponent,
+ * which names the key pair in key fingerprints; the file's exponent is always e_f and is not stored.
+ * Version 1 keys, whose files were tagged under e, are refused; such a file is tagged and published again.
+ */
+
+const (
+	PDP_PUBLIC_KEY_MAGIC   = "PDPF"
+	PDP_PUBLIC_KEY_VERSION = 2
+	PDP_PUBLIC_KEY_SUFFIX  = ".pub"
+
+	/* e_f = 2^PDP_FILE_EXPONENT_BITS - 1, a Mersenne prime longer than a replica block */
+	PDP_FILE_EXPONENT_BITS = 44497
+
+	/* Longest file ID and modulus, in bytes, a public file key may carry */
+	PDP_PUBLIC_KEY_MAX_ID      = 1024
+	PDP_PUBLIC_KEY_MAX_MODULUS = 1024
+
+	/* Statistical hiding of a masked M, and the length of gamma, in bits */
+	PDP_MASK_SECURITY   = 128
+	PDP_MASK_GAMMA_SIZE = 16
+
+	pdp_file_key_label = "PDP file"
+	pdp_mask_label     = "PDP mask"
+)
+
+var ErrBadPublicKey = errors.New("pdp: malformed public file key")
+
+/* The public exponent e_f of every file key */
+var pdp_file_exponent = new(big.Int).Sub(new(big.Int).Lsh(bigOne, PDP_FILE_EXPONENT_BITS), bigOne)
+
+/* pdp_key_exponent: The public exponent of an RSA key, e_f for a file key and e otherwise. */
+func pdp_key_exponent(key *PDP_key) *big.Int {
+	if key.e != nil {
+		return key.e
+	}
+	return big.NewInt(int64(key.rsa.E))
+}
+
+/* file_prf_key: The per-file PRF key v_f of fileID, PRF_KEY_SIZE bytes. */
+func file_prf_key(key *PDP_key, fileID string) *string {
+	var mac = hmac.New(sha256.New, []byte(*key.v))
+
+	mac.Write([]byte(pdp_file_key_label))
+	mac.Write([]byte(fileID))
+	v := string(mac.Sum(nil)[:PRF_KEY_SIZE])
+
+	return &v
+}
+
+/* pdp_file_key: Returns a copy of the key pair with v replaced by fileID's v_f and d by d_f.  Tagging a
+ * file with it makes the file publicly verifiable.  Returns nil if key has no v or is not a two-prime
+ * private key.
+ */
+func pdp_file_key(key *PDP_key, fileID string) *PDP_key {
+	var rsa *RSA.PrivateKey
+	var p, q *big.Int
+	var phi *big.Int
+	var d *big.Int
+
+	if key == nil || key.v == nil || key.rsa == nil || len(key.rsa.Primes) != 2 {
+		return nil
+	}
+	p, q = key.rsa.Primes[0], key.rsa.Primes[1]
+	if p == nil || q == nil {
+		return nil
+	}
+
+	/* d_f = e_f^-1 mod (p-1)(q-1) */
+	phi = new(big.Int).Mul(new(big.Int).Sub(p, bigOne), new(big.Int).Sub(q, bigOne))
+	d = new(big.Int).ModInverse(pdp_file_exponent, phi)
+	if d == nil {
+		return nil
+	}
+	rsa = &RSA.PrivateKey{PublicKey: key.rsa.PublicKey, D: d, Primes: key.rsa.Primes}
+	rsa.Precomputed.Dp = new(big.Int).Mod(d, new(big.Int).Sub(p, bigOne))
+	rsa.Precomputed.Dq = new(big.Int).Mod(d, new(big.Int).Sub(q, bigOne))
+	rsa.Precomputed.Qinv = new(big.Int).ModInverse(q, p)
+
+	file_key := *key
+	file_key.rsa = rsa
+	file_key.v = file_prf_key(key, fileID)
+	file_key.e = pdp_file_exponent
+
+	return &file_key
+}
+
+/* pdp_public_file_key: Returns the public key of fileID, N, e_f, g and v_f, which is all a third-party
+ * auditor needs.  Returns nil if key has no v.
+ */
+func pdp_public_file_key(key *PDP_key, fileID string) *PDP_key {
+
+	if key == nil || key.v == nil || key.rsa == nil || key.rsa.N == nil || key.g == nil {
+		return nil
+	}
+
+	return &PDP_key{
+		rsa: &RSA.PrivateKey{PublicKey: RSA.PublicKey{N: key.rsa.N, E: key.rsa.E}},
+		v:   file_prf_key(key, fileID),
+		g:   key.g,
+		e:   pdp_file_exponent,
+	}
+}
+
+/* pdp_challenge_public: A function like pdp_challenge for a third-party auditor.  The challenge has no
+ * secret s and no g_s, and can be made with a public file key.  A BLS or MAC key must be named after the file,
+ * see pdp_named_key, and the challenge carries the name.
+ * Returns an allocated pdp-challenge structure or nil on failure.
+ */
+func (pdpCore *PDPCore) pdp_challenge_public(key *PDP_key, numfileblocks uint) *PDP_challenge {
+	var challenge *PDP_challenge
+
+	if !valid_pdp_key(key) || numfileblocks == 0 {
+		return nil
+	}
+	if scheme_names_files(pdp_key_scheme(key)) && len(key.name) != PDP_TAG_FILE_NAME_SIZE {
+		return nil
+	}
+
+	/* Allocate memory */
+	challenge = pdpCore.generate_pdp_challenge()
+	challenge.name = key.name
+
+	/* Generate random bytes for symmetric challenge keys */
+	challengeK1, err := GenerateRandomBytes(PRP_KEY_SIZE)
+	if err != nil {
+		return nil
+	}
+	challenge.k1 = &challengeK1
+	challengeK2, err := GenerateRandomBytes(PRF_KEY_SIZE)
+	if err != nil {
+		return nil
+	}
+	challenge.k2 = &challengeK2
+
+	if numfileblocks < MAGIC_NUM_CHALLENGE_BLOCKS {
+		challenge.c = numfileblocks
+	} else {
+		challenge.c = MAGIC_NUM_CHALLENGE_BLOCKS
+	}
+	challenge.numfileblocks = numfileblocks
+
+	return challenge
+}
+
+/* pdp_mask_proof: Server-side function that masks the M of a final public proof, replacing it with
+ * mu = r + gamma * M and setting R = g^r.  The key only needs its public components.
+ * Returns the masked proof or nil on failure.
+ */
+func (pdpCore *PDPCore) pdp_mask_proof(key *PDP_key, challenge *PDP_challenge, proof *PDP_proof) *PDP_proof {
+	var r *big.Int
+	var bound *big.Int
+	var gamma *big.Int
+	var err error
+
+	if key == nil || key.rsa == nil || key.rsa.N == nil || key.g == nil || challenge == nil {
+		return nil
+	}
+	if proof == nil || proof.T == nil || proof.rho_temp == nil || proof.rho != nil || proof.R != nil {
+		return nil
+	}
+
+	/* r is uniform below 2^(|M| + |gamma| + PDP_MASK_SECURITY), |M| the longest M a proof may carry */
+	bound = new(big.Int).Lsh(bigOne, 8*PDP_WIRE_MAX_M_SIZE+8*PDP_MASK_GAMMA_SIZE+PDP_MASK_SECURITY)
+	if r, err = rand.Int(rand.Reader, bound); err != nil {
+		return nil
+	}
+	proof.R = new(big.Int).Exp(key.g, r, key.rsa.N)
+
+	gamma = mask_gamma(challenge, proof)
+	if gamma == nil {
+		return nil
+	}
+	/* mu = r + gamma * M */
+	proof.rho_temp = new(big.Int).Add(r, new(big.Int).Mul(gamma, proof.rho_temp))
+
+	return proof
+}
+
+/* mask_gamma: gamma = H("PDP mask" | challenge | T | R), the first PDP_MASK_GAMMA_SIZE bytes of SHA-256
+ * over the encoded challenge and the length-prefixed T and R.  Returns nil if the challenge cannot be
+ * encoded.
+ */
+func mask_gamma(challenge *PDP_challenge, proof *PDP_proof) *big.Int {
+	var hash = sha256.New()
+
+	encoded := encode_pdp_challenge(challenge)
+	if encoded == nil {
+		return nil
+	}
+	hash.Write([]byte(pdp_mask_label))
+	hash.Write(encoded)
+	hash.Write(append_bytes(nil, proof.T.Bytes()))
+	hash.Write(append_bytes(nil, proof.R.Bytes()))
+
+	return new(big.Int).SetBytes(hash.Sum(nil)[:PDP_MASK_GAMMA_SIZE])
+}
+
+/* encode_pdp_public_key: Encodes the public file key of fileID.  Returns nil on failure, or if key is not
+ * a file key: a file verified under any exponent but e_f is not safe to publish.
+ */
+func encode_pdp_public_key(key *PDP_key, fileID string) []byte {
+	var buf []byte
+
+	if key == nil || key.rsa == nil || key.rsa.N == nil || key.g == nil || key.v == nil {
+		return nil
+	}
+	if key.e == nil || key.e.Cmp(pdp_file_exponent) != 0 {
+		return nil
+	}
+
+	buf = append([]byte(PDP_PUBLIC_KEY_MAGIC), append_u32(nil, PDP_PUBLIC_KEY_VERSION)...)
+	buf = append_bytes(buf, []byte(fileID))
+	buf = append_bytes(buf, key.rsa.N.Bytes())
+	buf = append_u64(buf, uint64(key.rsa.E))
+	buf = append_bytes(buf, key.g.Bytes())
+	buf = append_bytes(buf, []byte(*key.v))
+
+	return buf
+}
+
+/* decode_pdp_public_key: Decodes a public file key.  g must be in Z*_N and v_f PRF_KEY_SIZE bytes.
+ * Returns the key, with the exponent e_f, and its file ID, or ErrBadPublicKey.
+ */
+func decode_pdp_public_key(data []byte) (*PDP_key, string, error) {
+	var reader = &pdp_wire_reader{data: data}
+
+	if string(reader.take(4)) != PDP_PUBLIC_KEY_MAGIC || reader.u32() != PDP_PUBLIC_KEY_VERSION {
+		return nil, "", ErrBadPublicKey
+	}
+	fileID := reader.bytes(PDP_PUBLIC_KEY_MAX_ID)
+	N := reader.bytes(PDP_PUBLIC_KEY_MAX_MODULUS)
+	e := reader.u64()
+	g := reader.bytes(PDP_PUBLIC_KEY_MAX_MODULUS)
+	v := reader.bytes(PRF_KEY_SIZE)
+	if !reader.done() || len(N) == 0 || !minimal_bn(N) || !minimal_bn(g) || len(v) != PRF_KEY_SIZE {
+		return nil, "", ErrBadPublicKey
+	}
+	if e < 3 || e%2 == 0 || e > 1<<31-1 {
+		return nil, "", ErrBadPublicKey
+	}
+
+	v_string := string(v)
+	key := &PDP_key{
+		rsa: &RSA.PrivateKey{PublicKey: RSA.PublicKey{N: new(big.Int).SetBytes(N), E: int(e)}},
+		v:   &v_string,
+		g:   new(big.Int).SetBytes(g),
+		e:   pdp_file_exponent,
+	}
+	if in_z_star_n(key, key.g) == 0 {
+		return nil, "", ErrBadPublicKey
+	}
+
+	return key, string(fileID), nil
+}
+
+/* FileKey: Exported form of pdp_file_key for the command line tool. */
+func FileKey(key *PDP_key, fileID string) *PDP_key {
+	return pdp_file_key(key, fileID)
+}
+
+/* WritePublicFileKey writes the public key of fileID, derived from the key pair, to path.  It refuses a
+ * key pair that cannot tag under the file key, one without its private primes.
+ */
+func WritePublicFileKey(path string, key *PDP_key, fileID string) error {
+
+	if pdp_file_key(key, fileID) == nil {
+		return errors.New("pdp: a public file key needs the private key pair")
+	}
+	data := encode_pdp_public_key(pdp_public_file_key(key, fileID), fileID)
+	if data == nil {
+		return errors.New("pdp: invalid key")
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+/* LoadPublicFileKey reads a public file key written by WritePublicFileKey.  Returns the key and the
+ * file ID it is for.
+ */
+func LoadPublicFileKey(path string) (*PDP_key, string, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return decode_pdp_public_key(data)
+}
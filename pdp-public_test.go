@@ -0,0 +1,235 @@
+package gopdp
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/* public_test_file: Tags a random file of size bytes under tagKey.  Returns its path, its blocks as
+ * BIGNUMs and its tags.
+ */
+func public_test_file(t *testing.T, tagKey *PDP_key, size int) (string, []*big.Int, []*big.Int) {
+	t.Helper()
+
+	var core = NewPDPCore()
+	var blocks []*big.Int
+	var tags []*big.Int
+
+	path, data := write_test_file(t, size)
+	if err := core.pdp_tag_file(context.Background(), tagKey, path, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	tagfile, err := os.Open(path + PDP_TAG_FILE_SUFFIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tagfile.Close()
+	header, err := read_pdp_tag_header(tagfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; uint64(i) < header.numfileblocks; i++ {
+		block := string(test_block(data, i))
+		blocks = append(blocks, block_to_bn(&block, uint64(len(block))))
+		tags = append(tags, core.read_pdp_tag_at(tagfile, header, uint(i)).Tim)
+	}
+
+	return path, blocks, tags
+}
+
+/* reduced_state_proof: The public proof of a prover that kept, for each block, only m_i mod e and
+ * T_i * g^-(m_i div e).  Returns T and M.
+ */
+func reduced_state_proof(key *PDP_key, e *big.Int, challenge *PDP_challenge, blocks []*big.Int,
+	tags []*big.Int) (*big.Int, *big.Int) {
+
+	var core = NewPDPCore()
+	var remainders = make([]*big.Int, len(blocks))
+	var reduced = make([]*big.Int, len(blocks))
+	var g_inv = new(big.Int).ModInverse(key.g, key.rsa.N)
+	var T = big.NewInt(1)
+	var M = new(big.Int)
+	var size uint64
+
+	for i := range blocks {
+		q, r := new(big.Int).QuoRem(blocks[i], e, new(big.Int))
+		remainders[i] = r
+		reduced[i] = ModMul(tags[i], new(big.Int).Exp(g_inv, q, key.rsa.N), key.rsa.N)
+	}
+
+	for j, i := range core.generate_prp_pi(challenge) {
+		a := prf_to_bn(core.generate_prf_f(challenge, uint(j), &size), size)
+		T = ModMul(T, new(big.Int).Exp(reduced[i], a, key.rsa.N), key.rsa.N)
+		M.Add(M, new(big.Int).Mul(a, remainders[i]))
+	}
+
+	return T, M
+}
+
+/* public_check: The public verifier's equation under exponent e, T^e / prod h(W_i)^a_i == g^M. */
+func public_check(key *PDP_key, e *big.Int, challenge *PDP_challenge, T *big.Int, M *big.Int) bool {
+	var core = NewPDPCore()
+	var denom = big.NewInt(1)
+	var size uint64
+
+	for j, i := range core.generate_prp_pi(challenge) {
+		W := core.generate_prf_w(key, i, &size)
+		h := core.generate_fdh_h(key, W, size)
+		a := prf_to_bn(core.generate_prf_f(challenge, uint(j), &size), size)
+		denom = ModMul(denom, new(big.Int).Exp(h, a, key.rsa.N), key.rsa.N)
+	}
+	tao := ModMul(new(big.Int).Exp(T, e, key.rsa.N), new(big.Int).ModInverse(denom, key.rsa.N), key.rsa.N)
+
+	return tao.Cmp(new(big.Int).Exp(key.g, M, key.rsa.N)) == 0
+}
+
+func TestPublicAudit(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	path, _, _ := public_test_file(t, pdp_file_key(key, "photos"), 30*PDP_BLOCKSIZE+7)
+
+	/* The auditor has only the published key */
+	keyPath := filepath.Join(t.TempDir(), "photos.pub")
+	if err := WritePublicFileKey(keyPath, key, "photos"); err != nil {
+		t.Fatal(err)
+	}
+	public, fileID, err := LoadPublicFileKey(keyPath)
+	if err != nil || fileID != "photos" {
+		t.Fatalf("LoadPublicFileKey: %q, %v", fileID, err)
+	}
+	if string(pdp_key_fingerprint(public)) != string(pdp_key_fingerprint(key)) {
+		t.Fatal("the public file key does not name the key pair")
+	}
+
+	for _, mask := range []bool{false, true} {
+		challenge := core.pdp_challenge_public(public, 31)
+		proof, err := core.pdp_prove_file(context.Background(), path, "", challenge, public_test_key(key),
+			&PDP_prove_options{Mask: mask})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (proof.R != nil) != mask || core.pdp_verify_proof(public, challenge, proof) != 1 {
+			t.Fatalf("mask %v: public proof did not verify", mask)
+		}
+		if core.pdp_verify_proof(pdp_public_file_key(key, "other"), challenge, proof) == 1 {
+			t.Fatalf("mask %v: proof verified under another file's key", mask)
+		}
+	}
+}
+
+func TestPublicKeyRefusesSmallExponent(t *testing.T) {
+	var core = NewPDPCore()
+
+	key := new_test_key(t)
+	if encode_pdp_public_key(&PDP_key{rsa: key.rsa, v: file_prf_key(key, "photos"), g: key.g}, "photos") != nil {
+		t.Fatal("encoded a public key with the key pair's exponent")
+	}
+	if WritePublicFileKey(filepath.Join(t.TempDir(), "photos.pub"), public_test_key(key), "photos") == nil {
+		t.Fatal("published a file key without the private key pair")
+	}
+
+	/* A version 1 key, whose file was tagged under e, is refused */
+	encoded := encode_pdp_public_key(pdp_public_file_key(key, "photos"), "photos")
+	copy(encoded[4:8], append_u32(nil, 1))
+	if _, _, err := decode_pdp_public_key(encoded); err != ErrBadPublicKey {
+		t.Fatalf("decoded a version 1 public key: %v", err)
+	}
+
+	/* Nor is a public proof verified under a key pair's exponent */
+	small := pdp_public_file_key(key, "photos")
+	small.e = nil
+	challenge := core.pdp_challenge_public(small, 1)
+	if core.pdp_verify_proof(small, challenge, &PDP_proof{T: big.NewInt(1), rho_temp: new(big.Int)}) == 1 {
+		t.Fatal("public proof verified under e")
+	}
+}
+
+func TestReducedStateProver(t *testing.T) {
+	var core = NewPDPCore()
+	var e = big.NewInt(65537)
+
+	key := new_test_key(t)
+
+	/* Under the key pair's e, keeping 17 bits and one group element per block passes every audit */
+	small := &PDP_key{rsa: key.rsa, v: file_prf_key(key, "photos"), g: key.g}
+	_, blocks, tags := public_test_file(t, small, 20*PDP_BLOCKSIZE)
+	challenge := core.pdp_challenge_public(small, 20)
+	T, M := reduced_state_proof(small, e, challenge, blocks, tags)
+	if !public_check(small, e, challenge, T, M) {
+		t.Fatal("the reduced-state prover failed under e; the attack is not what the test expects")
+	}
+
+	/* Under e_f it fails */
+	file_key := pdp_file_key(key, "photos")
+	public := pdp_public_file_key(key, "photos")
+	_, blocks, tags = public_test_file(t, file_key, 20*PDP_BLOCKSIZE)
+	for i := 0; i < 5; i++ {
+		challenge = core.pdp_challenge_public(public, 20)
+		T, M = reduced_state_proof(public, e, challenge, blocks, tags)
+		if core.pdp_verify_proof(public, challenge, &PDP_proof{T: T, rho_temp: M}) == 1 {
+			t.Fatal("the reduced-state prover passed under e_f")
+		}
+	}
+
+	/* and reducing by e_f itself keeps every block whole */
+	for i, block := range blocks {
+		if block.Cmp(pdp_file_exponent) >= 0 {
+			t.Fatalf("block %d is not below e_f", i)
+		}
+	}
+}
+
+func TestRepeatedMaskedAudits(t *testing.T) {
+	var core = NewPDPCore()
+	var Rs = make(map[string]bool)
+	var mus = make(map[string]bool)
+	var proofs []*PDP_proof
+
+	key := new_test_key(t)
+	public := pdp_public_file_key(key, "photos")
+	path, _, _ := public_test_file(t, pdp_file_key(key, "photos"), 10*PDP_BLOCKSIZE)
+
+	/* Unmasked, the same challenge always gives the auditor the same M */
+	challenge := core.pdp_challenge_public(public, 10)
+	prove := func(mask bool) *PDP_proof {
+		proof, err := core.pdp_prove_file(context.Background(), path, "", challenge, public_test_key(key),
+			&PDP_prove_options{Mask: mask})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return proof
+	}
+	M := prove(false).rho_temp
+	if prove(false).rho_temp.Cmp(M) != 0 {
+		t.Fatal("unmasked proofs of the same challenge differ")
+	}
+
+	/* Masked, every answer verifies but none repeats or reveals M */
+	for i := 0; i < 16; i++ {
+		proof := prove(true)
+		if core.pdp_verify_proof(public, challenge, proof) != 1 {
+			t.Fatalf("masked audit %d did not verify", i)
+		}
+		if Rs[proof.R.String()] || mus[proof.rho_temp.String()] {
+			t.Fatalf("masked audit %d repeated an R or mu", i)
+		}
+		if proof.rho_temp.Cmp(M) == 0 || proof.rho_temp.Cmp(new(big.Int).Mul(mask_gamma(challenge, proof), M)) == 0 {
+			t.Fatalf("masked audit %d revealed M", i)
+		}
+		Rs[proof.R.String()] = true
+		mus[proof.rho_temp.String()] = true
+		proofs = append(proofs, proof)
+	}
+
+	/* Nor can the answers be mixed: each mu only opens its own R */
+	for i := 1; i < len(proofs); i++ {
+		mixed := &PDP_proof{T: proofs[i].T, R: proofs[i-1].R, rho_temp: proofs[i].rho_temp}
+		if core.pdp_verify_proof(public, challenge, mixed) == 1 {
+			t.Fatalf("mu of audit %d verified with the R of audit %d", i, i-1)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package gopdp
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"os"
+)
+
+/* MR-PDP: a replica of a tagged file stores every block masked with a pseudo-random value of its own,
+ * m~_i = m_i + r_u,i where r_u,i is PDP_BLOCKSIZE bytes of HMAC-SHA256 output under the replica key
+ * K_u = HMAC-SHA256(v, "PDP replica" | u), so no two replicas hold the same data and one cannot be
+ * computed from another without v.  The tags are those of the unmasked file and shared by every replica.
+ *
+ * A replica is proved like any tagged file.  Its blocks are stored in records of PDP_REPLICA_BLOCKSIZE
+ * bytes, one more than a block to hold the carry, and its tag file is a copy of the file's with that
+ * block size in the header.  The verifier audits replica u with a challenge from pdp_challenge_replica
+ * and multiplies g^(sum a_j * r_u,i_j) back in, so a proof from any other replica, or from the file
+ * itself, does not verify.
+ */
+
+const (
+	PDP_REPLICA_BLOCKSIZE = PDP_BLOCKSIZE + 1
+
+	pdp_replica_label = "PDP replica"
+)
+
+/* replica_key: The PRF key K_u of replica u. */
+func replica_key(key *PDP_key, replica uint32) []byte {
+	var mac = hmac.New(sha256.New, []byte(*key.v))
+
+	mac.Write([]byte(pdp_replica_label))
+	mac.Write(append_u32(nil, replica))
+
+	return mac.Sum(nil)
+}
+
+/* replica_mask: r_u,i, PDP_BLOCKSIZE bytes of HMAC-SHA256(K_u, i u64 | counter u32) as a BIGNUM. */
+func replica_mask(replica_prf []byte, index uint) *big.Int {
+	var mask = make([]byte, 0, PDP_BLOCKSIZE+sha256.Size)
+	var input [12]byte
+	var mac = hmac.New(sha256.New, replica_prf)
+
+	binary.BigEndian.PutUint64(input[0:8], uint64(index))
+	for counter := uint32(0); len(mask) < PDP_BLOCKSIZE; counter++ {
+		binary.BigEndian.PutUint32(input[8:12], counter)
+		mac.Reset()
+		mac.Write(input[:])
+		mask = mac.Sum(mask)
+	}
+
+	return new(big.Int).SetBytes(mask[:PDP_BLOCKSIZE])
+}
+
+/* pdp_challenge_replica: A client-side function like pdp_challenge that audits replica u of a file.
+ * The challenge sent is the ordinary one; only pdp_verify_proof treats it differently.  Returns nil
+ * under a BLS or MAC key, which has no replicas.
+ */
+func (pdpCore *PDPCore) pdp_challenge_replica(key *PDP_key, numfileblocks uint, replica uint32) *PDP_challenge {
+
+	if key != nil && (key.bls != nil || key.mac != nil) {
+		return nil
+	}
+	challenge := pdpCore.pdp_challenge(key, numfileblocks)
+	if challenge == nil {
+		return nil
+	}
+	challenge.replica = &replica
+
+	return challenge
+}
+
+/* pdp_replicate_file: Client-side function that writes replica u of a file tagged with pdp_tag_file to
+ * replicaPath and its tag file to replicaTagPath, replicaPath + ".tag" if empty.  tagFilepath defaults
+ * to filepath + ".tag".  Nothing is tagged; the replica shares the file's tags.
+ * Returns nil on success or ctx.Err() if the context is cancelled.
+ */
+func (pdpCore *PDPCore) pdp_replicate_file(ctx context.Context, key *PDP_key, filepath string, tagFilepath string,
+	replicaPath string, replicaTagPath string, replica uint32) error {
+
+	var block = make([]byte, PDP_BLOCKSIZE)
+	var record = make([]byte, PDP_REPLICA_BLOCKSIZE)
+	var replica_header PDP_tag_header
+
+	if key == nil || key.v == nil || key.rsa == nil || key.rsa.N == nil {
+		return ErrBadTagFile
+	}
+	if tagFilepath == "" {
+		tagFilepath = filepath + PDP_TAG_FILE_SUFFIX
+	}
+	if replicaTagPath == "" {
+		replicaTagPath = replicaPath + PDP_TAG_FILE_SUFFIX
+	}
+
+	file, err := os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	tagfile, err := os.Open(tagFilepath)
+	if err != nil {
+		return err
+	}
+	defer tagfile.Close()
+
+	header, err := read_pdp_tag_header(tagfile)
+	if err != nil {
+		return err
+	}
+	if header.block_size != PDP_BLOCKSIZE || header.tagged != header.numfileblocks ||
+		!tag_header_matches(header, key) {
+		return ErrBadTagFile
+	}
+
+	/* The masked blocks */
+	out, err := os.OpenFile(replicaPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	writer := bufio.NewWriter(out)
+	replica_prf := replica_key(key, replica)
+
+	for i := uint64(0); i < header.numfileblocks; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n, err := file.ReadAt(block, int64(i)*PDP_BLOCKSIZE)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		masked := new(big.Int).SetBytes(block[:n])
+		masked.Add(masked, replica_mask(replica_prf, uint(i)))
+		masked.FillBytes(record)
+		if _, err = writer.Write(record); err != nil {
+			return err
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		return err
+	}
+
+	/* The shared tags, under a header giving the replica's record size */
+	tagout, err := os.OpenFile(replicaTagPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer tagout.Close()
+	replica_header = *header
+	replica_header.block_size = PDP_REPLICA_BLOCKSIZE
+	replica_header.tagged = 0
+	if err = write_pdp_tag_header(tagout, &replica_header); err != nil {
+		return err
+	}
+	records := io.NewSectionReader(tagfile, tag_offset(header, 0), int64(header.numfileblocks)*int64(header.tag_size))
+	if _, err = tagout.Seek(tag_offset(&replica_header, 0), io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = io.Copy(tagout, records); err != nil {
+		return err
+	}
+
+	return checkpoint_pdp_tag_file(tagout, &replica_header, replica_header.numfileblocks)
+}
+
+/* ReplicateFile: Exported form of pdp_replicate_file for the command line tool. */
+func (pdpCore *PDPCore) ReplicateFile(ctx context.Context, key *PDP_key, filepath string, tagFilepath string,
+	replicaPath string, replicaTagPath string, replica uint32) error {
+	return pdpCore.pdp_replicate_file(ctx, key, filepath, tagFilepath, replicaPath, replicaTagPath, replica)
+}
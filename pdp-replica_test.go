@@ -0,0 +1,101 @@
+package gopdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+/* replicate_test_file: Writes replica u of the tagged file at path next to it.  Returns its path. */
+func replicate_test_file(t testing.TB, key *PDP_key, path string, replica uint32) string {
+	t.Helper()
+
+	replica_path := filepath.Join(filepath.Dir(path), fmt.Sprintf("replica-%d", replica))
+	if err := NewPDPCore().pdp_replicate_file(context.Background(), key, path, "", replica_path, "", replica); err != nil {
+		t.Fatal(err)
+	}
+
+	return replica_path
+}
+
+func TestReplicaCannotAnswerForAnother(t *testing.T) {
+	var core = NewPDPCore()
+	var ctx = context.Background()
+
+	key := new_test_key(t)
+	path, _ := tag_test_file(t, key, 12*PDP_BLOCKSIZE+100)
+	paths := map[string]string{
+		"file":      path,
+		"replica-1": replicate_test_file(t, key, path, 1),
+		"replica-2": replicate_test_file(t, key, path, 2),
+	}
+
+	/* No replica holds the file's data or another replica's */
+	first, _ := ioutil.ReadFile(paths["replica-1"])
+	second, _ := ioutil.ReadFile(paths["replica-2"])
+	if bytes.Equal(first, second) {
+		t.Fatal("replicas 1 and 2 hold the same data")
+	}
+
+	for _, test := range []struct {
+		replica uint32
+		holder  string
+		ok      bool
+	}{
+		{1, "replica-1", true},
+		{2, "replica-2", true},
+		{1, "replica-2", false},
+		{2, "replica-1", false},
+		{1, "file", false},
+	} {
+		challenge := core.pdp_challenge_replica(key, 13, test.replica)
+		proof, err := core.pdp_prove_file(ctx, paths[test.holder], "", core.sanitize_pdp_challenge(challenge),
+			public_test_key(key), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (core.pdp_verify_proof(key, challenge, proof) == 1) != test.ok {
+			t.Errorf("replica %d answered by %s: verified %v, want %v", test.replica, test.holder, !test.ok, test.ok)
+		}
+	}
+
+	/* Nor does the file's challenge verify against a replica */
+	challenge := core.pdp_challenge(key, 13)
+	proof, err := core.pdp_prove_file(ctx, paths["replica-1"], "", core.sanitize_pdp_challenge(challenge),
+		public_test_key(key), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.pdp_verify_proof(key, challenge, proof) == 1 {
+		t.Error("replica 1 answered for the file")
+	}
+}
+
+func TestRemoteReplicaAudit(t *testing.T) {
+	var core = NewPDPCore()
+	var ctx = context.Background()
+	var first, second uint32 = 1, 2
+
+	key := new_test_key(t)
+	path, _ := tag_test_file(t, key, 12*PDP_BLOCKSIZE+100)
+	server := NewPDPServer(public_test_key(key), nil)
+	client := grpc_test_client(t, server, key)
+
+	/* A server that kept only replica 2 serves it for both */
+	if err := server.AddFile("data", replicate_test_file(t, key, path, second), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := core.pdp_audit_remote_file(ctx, key, &PDP_audit_file{FileID: "data", Remote: client,
+		NumBlocks: 13, Replica: &second}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := core.pdp_audit_remote_file(ctx, key, &PDP_audit_file{FileID: "data", Remote: client,
+		NumBlocks: 13, Replica: &first})
+	if !errors.Is(err, ErrProofRejected) {
+		t.Fatalf("replica 2 audited as replica 1: %v", err)
+	}
+}
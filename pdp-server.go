@@ -0,0 +1,320 @@
+package gopdp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var ErrUnknownFile = errors.New("pdp: unknown file")
+var ErrKeyMismatch = errors.New("pdp: file was tagged under a different key")
+var ErrProofRejected = errors.New("pdp: proof did not verify")
+var ErrBlockCountMismatch = errors.New("pdp: remote file has a different block count")
+var ErrNoBlockCount = errors.New("pdp: the verifier's block count of the file is unknown")
+
+/* PDP_file_info is what a verifier learns about a remote file before challenging it. */
+type PDP_file_info struct {
+	NumBlocks      uint64
+	BlockSize      uint32
+	KeyFingerprint []byte
+}
+
+/* PDP_remote is a connection to a prover, whatever the transport.  Prove sends only the public part
+ * of the challenge and returns the decoded, range-checked proof.
+ */
+type PDP_remote interface {
+	FileInfo(ctx context.Context, fileID string) (*PDP_file_info, error)
+	Prove(ctx context.Context, fileID string, challenge *PDP_challenge) (*PDP_proof, error)
+}
+
+type pdp_server_file struct {
+	path     string
+	tag_path string
+	header   *PDP_tag_header
+}
+
+/* PDPServer answers remote challenges for a set of tagged files.  It holds only the public key and
+ * is wrapped by the transport servers (gRPC, HTTP, ...).
+ */
+type PDPServer struct {
+	core  *PDPCore
+	key   *PDP_key
+	opts  *PDP_prove_options
+	acl   *PDP_acl
+	mutex sync.RWMutex
+	files map[string]*pdp_server_file
+}
+
+/* NewPDPServer: Returns a server proving files tagged under key.  key should only contain the public
+ * components; opts is passed to pdp_prove_file and may be nil.
+ */
+func NewPDPServer(key *PDP_key, opts *PDP_prove_options) *PDPServer {
+	return &PDPServer{
+		core:  NewPDPCore(),
+		key:   key,
+		opts:  opts,
+		files: make(map[string]*pdp_server_file),
+	}
+}
+
+/* AddFile registers a tagged file under fileID.  tagPath defaults to path + ".tag".  The tag file must
+ * be complete and its records the size of the server's modulus.  Adding fileID again replaces it, which
+ * is how the server picks up a block count extended by pdp_append_file.
+ */
+func (server *PDPServer) AddFile(fileID string, path string, tagPath string) error {
+	var file = &pdp_server_file{path: path, tag_path: tagPath}
+
+	if file.tag_path == "" {
+		file.tag_path = path + PDP_TAG_FILE_SUFFIX
+	}
+
+	tagfile, err := os.Open(file.tag_path)
+	if err != nil {
+		return err
+	}
+	defer tagfile.Close()
+	file.header, err = read_pdp_tag_header(tagfile)
+	if err != nil {
+		return err
+	}
+	if file.header.tagged != file.header.numfileblocks || !tag_header_matches(file.header, server.key) {
+		return ErrBadTagFile
+	}
+
+	server.mutex.Lock()
+	server.files[fileID] = file
+	server.mutex.Unlock()
+
+	return nil
+}
+
+/* SetACL restricts each file to the clients acl lists.  Requests must then come over an authenticated
+ * transport, mutual TLS or libp2p; with no ACL, the default, any client may audit any file.
+ */
+func (server *PDPServer) SetACL(acl *PDP_acl) {
+	server.mutex.Lock()
+	server.acl = acl
+	server.mutex.Unlock()
+}
+
+/* RemoveFile stops serving fileID. */
+func (server *PDPServer) RemoveFile(fileID string) {
+	server.mutex.Lock()
+	delete(server.files, fileID)
+	server.mutex.Unlock()
+}
+
+/* lookup: Returns fileID if the client whose names the transport attached to ctx may audit it.
+ * Authorization is checked first, so an unauthorized client cannot probe which files exist.
+ */
+func (server *PDPServer) lookup(ctx context.Context, fileID string) (*pdp_server_file, error) {
+	server.mutex.RLock()
+	acl := server.acl
+	file, ok := server.files[fileID]
+	server.mutex.RUnlock()
+
+	if acl != nil {
+		if !acl.allowed(client_names(ctx), fileID) {
+			return nil, ErrPermissionDenied
+		}
+	}
+	if !ok {
+		return nil, ErrUnknownFile
+	}
+	return file, nil
+}
+
+/* FileInfo returns the block count and key fingerprint of fileID. */
+func (server *PDPServer) FileInfo(ctx context.Context, fileID string) (*PDP_file_info, error) {
+
+	file, err := server.lookup(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PDP_file_info{
+		NumBlocks:      file.header.numfileblocks,
+		BlockSize:      file.header.block_size,
+		KeyFingerprint: pdp_key_fingerprint(server.key),
+	}, nil
+}
+
+/* Prove decodes a sanitized challenge for fileID, runs the file prover and returns the encoded proof. */
+func (server *PDPServer) Prove(ctx context.Context, fileID string, challenge []byte) ([]byte, error) {
+
+	proof, err := server.prove(ctx, fileID, func(numfileblocks uint64) (*PDP_challenge, error) {
+		return decode_pdp_challenge(challenge, server.key, numfileblocks)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return encode_pdp_proof(proof), nil
+}
+
+/* prove: Looks up fileID, decodes its challenge with decode, which is given the file's block count,
+ * and runs the file prover.
+ */
+func (server *PDPServer) prove(ctx context.Context, fileID string,
+	decode func(numfileblocks uint64) (*PDP_challenge, error)) (*PDP_proof, error) {
+
+	file, err := server.lookup(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := decode(file.header.numfileblocks)
+	if err != nil {
+		return nil, err
+	}
+
+	return server.core.pdp_prove_file(ctx, file.path, file.tag_path, challenge, server.key, server.opts)
+}
+
+/* pdp_audit_remote: Client-side function that audits fileID, tagged with numfileblocks blocks, on a
+ * remote prover.  It checks the file was tagged under key and has that many blocks, challenges it with
+ * pdp_challenge, sends only the sanitized challenge and verifies the returned proof with
+ * pdp_verify_proof.
+ * Returns nil if the proof verified, ErrProofRejected if it did not, or the transport's error.
+ */
+func (pdpCore *PDPCore) pdp_audit_remote(ctx context.Context, key *PDP_key, remote PDP_remote, fileID string,
+	numfileblocks uint64) error {
+	_, err := pdpCore.pdp_audit_remote_file(ctx, key,
+		&PDP_audit_file{FileID: fileID, Remote: remote, NumBlocks: numfileblocks})
+	return err
+}
+
+/* pdp_audit_remote_file: pdp_audit_remote for a registered file.  The verifier must know the file has
+ * file.NumBlocks blocks: a remote reporting any other count fails with ErrBlockCountMismatch rather than
+ * being challenged over fewer blocks, and a file with no count fails with ErrNoBlockCount, since the
+ * remote's own count cannot be trusted.  A file changed with the dynamic
+ * block operations is verified against its index table, which also gives the block count, a replica
+ * is challenged with pdp_challenge_replica, a file with a PublicKey is challenged with
+ * pdp_challenge_public and verified under that key, and a BLS or MAC file is challenged and verified
+ * under key named after file.Name.
+ * Returns the challenge sent, if one was, and the audit's error.
+ */
+func (pdpCore *PDPCore) pdp_audit_remote_file(ctx context.Context, key *PDP_key,
+	file *PDP_audit_file) (challenge *PDP_challenge, err error) {
+
+	var proof *PDP_proof
+	var info *PDP_file_info
+	var start = time.Now()
+	var remote = file.Remote
+	var fileID = file.FileID
+	var numfileblocks = file.NumBlocks
+	var table = file.Table
+
+	if file.PublicKey != nil {
+		key = file.PublicKey
+	}
+	if file.Name != nil {
+		key = pdp_named_key(key, file.Name)
+	}
+
+	pdp_audits_attempted.WithLabelValues(fileID, pdp_scheme(key)).Inc()
+	ctx, span := start_span(ctx, "pdp.audit", attribute.String("pdp.file_id", fileID))
+	defer func() {
+		observe_audit(key, fileID, start, err)
+		end_span(span, err)
+	}()
+
+	if table != nil {
+		numfileblocks = table.NumBlocks()
+	}
+	if numfileblocks == 0 {
+		return nil, ErrNoBlockCount
+	}
+	if file.Name != nil && key == nil {
+		return nil, errors.New("pdp: only a BLS or MAC file is audited under a name")
+	}
+	if key != nil && scheme_names_files(pdp_key_scheme(key)) && key.name == nil {
+		return nil, errors.New("pdp: a BLS or MAC file is audited under the name it was tagged with")
+	}
+	info, err = remote.FileInfo(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(info.KeyFingerprint, pdp_key_fingerprint(key)) != 1 {
+		return nil, ErrKeyMismatch
+	}
+	if info.NumBlocks != numfileblocks {
+		return nil, ErrBlockCountMismatch
+	}
+	if info.NumBlocks == 0 || info.NumBlocks > uint64(^uint(0)) {
+		return nil, fmt.Errorf("pdp: remote file has %d blocks", info.NumBlocks)
+	}
+
+	_, challenge_span := start_span(ctx, "pdp.challenge", attribute.Int64("pdp.numfileblocks", int64(info.NumBlocks)))
+	if file.PublicKey != nil {
+		challenge = pdpCore.pdp_challenge_public(key, uint(info.NumBlocks))
+	} else if file.Replica != nil {
+		challenge = pdpCore.pdp_challenge_replica(key, uint(info.NumBlocks), *file.Replica)
+	} else {
+		challenge = pdpCore.pdp_challenge(key, uint(info.NumBlocks))
+	}
+	if challenge == nil {
+		err = errors.New("pdp: could not create challenge")
+		end_span(challenge_span, err)
+		return nil, err
+	}
+	challenge_span.SetAttributes(attribute.Int64("pdp.challenged", int64(challenge.c)))
+	challenge_span.End()
+
+	proof, err = remote.Prove(ctx, fileID, challenge)
+	if err != nil {
+		return challenge, err
+	}
+
+	_, verify_span := start_span(ctx, "pdp.verify_proof")
+	verify_start := time.Now()
+	var verified int
+	if table != nil {
+		verified = pdpCore.pdp_verify_proof_table(key, challenge, proof, table)
+	} else {
+		verified = pdpCore.pdp_verify_proof(key, challenge, proof)
+	}
+	pdp_verify_seconds.WithLabelValues(pdp_scheme(key)).Observe(time.Since(verify_start).Seconds())
+	verify_span.SetAttributes(attribute.Bool("pdp.verified", verified == 1))
+	verify_span.End()
+	if verified != 1 {
+		return challenge, ErrProofRejected
+	}
+
+	return challenge, nil
+}
+
+/* pdp_key_fingerprint: SHA-256 over the public key, N | e | g, each length-prefixed, or v | seed for a
+ * BLS key.  A MAC key has no public part, and its ID stands in.
+ */
+func pdp_key_fingerprint(key *PDP_key) []byte {
+	var hash = sha256.New()
+	var e [8]byte
+
+	if key != nil && key.mac != nil {
+		return key.mac.id
+	}
+	if key != nil && key.bls != nil {
+		hash.Write(append_bytes(nil, key.bls.v.BytesCompressed()))
+		hash.Write(append_bytes(nil, key.bls.seed))
+		return hash.Sum(nil)
+	}
+	if key == nil || key.rsa == nil || key.rsa.N == nil {
+		return nil
+	}
+	hash.Write(append_bytes(nil, key.rsa.N.Bytes()))
+	binary.BigEndian.PutUint64(e[:], uint64(key.rsa.E))
+	hash.Write(append_bytes(nil, e[:]))
+	if key.g != nil {
+		hash.Write(append_bytes(nil, key.g.Bytes()))
+	}
+
+	return hash.Sum(nil)
+}
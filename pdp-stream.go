@@ -0,0 +1,176 @@
+package gopdp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+/* PDP_tag_sink receives tags from a TagWriter, one per block and in index order. */
+type PDP_tag_sink interface {
+	WriteTag(tag *PDP_tag) error
+}
+
+/* TagWriter tags data as it is written through it.  Every PDP_BLOCKSIZE bytes written are passed on
+ * to the underlying writer, tagged with pdp_tag_block and the tag handed to the sink, so data can be
+ * uploaded and tagged in a single pass without a temporary file.  Close must be called to tag the
+ * final partial block.
+ */
+type TagWriter struct {
+	core   *PDPCore
+	key    *PDP_key
+	w      io.Writer
+	sink   PDP_tag_sink
+	block  []byte
+	fill   int
+	index  uint
+	err    error
+	closed bool
+}
+
+/* NewTagWriter: Returns a TagWriter that writes data to w and its tags to sink. */
+func NewTagWriter(key *PDP_key, w io.Writer, sink PDP_tag_sink) *TagWriter {
+	return &TagWriter{
+		core:  NewPDPCore(),
+		key:   key,
+		w:     w,
+		sink:  sink,
+		block: make([]byte, PDP_BLOCKSIZE),
+	}
+}
+
+/* Write passes p to the underlying writer and tags every block it completes. */
+func (tw *TagWriter) Write(p []byte) (int, error) {
+	var written int = 0
+
+	if tw.err != nil {
+		return 0, tw.err
+	}
+	if tw.closed {
+		return 0, errors.New("pdp: write to closed TagWriter")
+	}
+
+	for len(p) > 0 {
+		n := copy(tw.block[tw.fill:], p)
+
+		/* Pass the data through before tagging so the upload isn't held up by a failing tag */
+		m, err := tw.w.Write(p[:n])
+		written += m
+		if err == nil && m != n {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			tw.err = err
+			return written, err
+		}
+
+		tw.fill += n
+		p = p[n:]
+		if tw.fill == len(tw.block) {
+			if err = tw.tag_block(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+/* Close tags the final partial block, if any.  It does not close the underlying writer or the sink. */
+func (tw *TagWriter) Close() error {
+
+	if tw.closed {
+		return tw.err
+	}
+	tw.closed = true
+	if tw.err == nil && tw.fill > 0 {
+		return tw.tag_block()
+	}
+
+	return tw.err
+}
+
+/* Blocks returns the number of blocks tagged so far. */
+func (tw *TagWriter) Blocks() uint {
+	return tw.index
+}
+
+func (tw *TagWriter) tag_block() error {
+	var blocksize = uint64(tw.fill)
+	var data = string(tw.block[:tw.fill])
+
+	tag := tw.core.pdp_tag_block(tw.key, &data, &blocksize, tw.index)
+	if tag == nil {
+		tw.err = fmt.Errorf("pdp: could not tag block %d", tw.index)
+		return tw.err
+	}
+	if err := tw.sink.WriteTag(tag); err != nil {
+		tw.err = err
+		return err
+	}
+	tw.index++
+	tw.fill = 0
+
+	return nil
+}
+
+/* PDP_tag_file_sink writes tags into a tag file in the format used by pdp_tag_file.  The header
+ * is written as incomplete and only filled in with the block count by Close.
+ */
+type PDP_tag_file_sink struct {
+	tagfile *os.File
+	header  *PDP_tag_header
+}
+
+/* NewTagFileSink: Returns a sink that writes a tag file for key to tagfile, truncating it.  A BLS or MAC
+ * key must be named after the file, see pdp_named_key, and the TagWriter given the same key.
+ */
+func NewTagFileSink(key *PDP_key, tagfile *os.File) (*PDP_tag_file_sink, error) {
+	var sink *PDP_tag_file_sink
+
+	if !valid_pdp_key(key) {
+		return nil, errors.New("pdp: invalid key")
+	}
+	if scheme_names_files(pdp_key_scheme(key)) && len(key.name) != PDP_TAG_FILE_NAME_SIZE {
+		return nil, errors.New("pdp: a BLS or MAC key must be named after the file it tags")
+	}
+	if err := tagfile.Truncate(0); err != nil {
+		return nil, err
+	}
+
+	sink = &PDP_tag_file_sink{
+		tagfile: tagfile,
+		header: &PDP_tag_header{
+			version:    PDP_TAG_FILE_VERSION,
+			scheme:     pdp_key_scheme(key),
+			block_size: PDP_BLOCKSIZE,
+			tag_size:   uint32(modulus_size(key)),
+			name:       key.name,
+		},
+	}
+	if err := write_pdp_tag_header(tagfile, sink.header); err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+/* WriteTag writes tag into its record. */
+func (sink *PDP_tag_file_sink) WriteTag(tag *PDP_tag) error {
+
+	if uint64(tag.index) != sink.header.numfileblocks {
+		return fmt.Errorf("pdp: tag %d written out of order", tag.index)
+	}
+	if err := write_pdp_tag(sink.tagfile, sink.header, tag); err != nil {
+		return err
+	}
+	sink.header.numfileblocks++
+
+	return nil
+}
+
+/* Close syncs the records and marks the tag file complete.  It does not close the file. */
+func (sink *PDP_tag_file_sink) Close() error {
+	return checkpoint_pdp_tag_file(sink.tagfile, sink.header, sink.header.numfileblocks)
+}
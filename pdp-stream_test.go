@@ -0,0 +1,156 @@
+package gopdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+/* short_test_writer accepts one byte less than it is given. */
+type short_test_writer struct{}
+
+func (short_test_writer) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return len(p) - 1, nil
+}
+
+/* failing_test_sink accepts limit tags and refuses the rest. */
+type failing_test_sink struct {
+	limit int
+	tags  int
+}
+
+var errTestSink = errors.New("sink refused the tag")
+
+func (sink *failing_test_sink) WriteTag(tag *PDP_tag) error {
+	if sink.tags == sink.limit {
+		return errTestSink
+	}
+	sink.tags++
+	return nil
+}
+
+func TestTagWriterMatchesTagFile(t *testing.T) {
+	var core = NewPDPCore()
+	var ctx = context.Background()
+	var uploaded bytes.Buffer
+
+	key := new_test_key(t)
+	path, data := tag_test_file(t, key, 10*PDP_BLOCKSIZE+100)
+
+	tagfile, err := os.Create(path + ".streamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tagfile.Close()
+	sink, err := NewTagFileSink(key, tagfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := NewTagWriter(key, &uploaded, sink)
+
+	/* Writes that straddle, fill and overrun block boundaries */
+	sizes := []int{1, PDP_BLOCKSIZE, PDP_BLOCKSIZE + 1, 1, PDP_BLOCKSIZE - 2}
+	for offset, i := 0, 0; offset < len(data); i++ {
+		end := offset + sizes[i%len(sizes)]
+		if end > len(data) {
+			end = len(data)
+		}
+		if n, err := tw.Write(data[offset:end]); err != nil || n != end-offset {
+			t.Fatalf("wrote %d of %d bytes: %v", n, end-offset, err)
+		}
+		offset = end
+	}
+	if tw.Blocks() != 10 {
+		t.Fatalf("%d blocks tagged before Close, want 10", tw.Blocks())
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if tw.Blocks() != 11 {
+		t.Fatalf("%d blocks tagged, want 11", tw.Blocks())
+	}
+
+	/* The data is passed through and the tag file is the one pdp_tag_file writes */
+	if !bytes.Equal(uploaded.Bytes(), data) {
+		t.Fatal("data was not passed through unchanged")
+	}
+	want, _ := ioutil.ReadFile(path + PDP_TAG_FILE_SUFFIX)
+	got, _ := ioutil.ReadFile(path + ".streamed")
+	if !bytes.Equal(got, want) {
+		t.Fatal("streamed tag file differs from pdp_tag_file's")
+	}
+
+	challenge := core.pdp_challenge(key, 11)
+	proof, err := core.pdp_prove_file(ctx, path, path+".streamed", core.sanitize_pdp_challenge(challenge),
+		public_test_key(key), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core.pdp_verify_proof(key, challenge, proof) != 1 {
+		t.Fatal("proof over the streamed tag file did not verify")
+	}
+}
+
+func TestTagWriterErrors(t *testing.T) {
+	key := new_test_key(t)
+	block := make([]byte, PDP_BLOCKSIZE)
+
+	/* A short write from the underlying writer stops the TagWriter for good */
+	tw := NewTagWriter(key, short_test_writer{}, &failing_test_sink{limit: 10})
+	if n, err := tw.Write(block); err != io.ErrShortWrite || n != len(block)-1 {
+		t.Fatalf("short write: wrote %d, %v", n, err)
+	}
+	if _, err := tw.Write(block); err != io.ErrShortWrite {
+		t.Fatalf("write after a short write: %v", err)
+	}
+	if err := tw.Close(); err != io.ErrShortWrite {
+		t.Fatalf("close after a short write: %v", err)
+	}
+	if tw.Blocks() != 0 {
+		t.Fatalf("%d blocks tagged after a short write", tw.Blocks())
+	}
+
+	/* A sink that refuses a tag stops it too, after the data was passed through */
+	var uploaded bytes.Buffer
+	tw = NewTagWriter(key, &uploaded, &failing_test_sink{limit: 1})
+	if _, err := tw.Write(append(block, block...)); !errors.Is(err, errTestSink) {
+		t.Fatalf("refused tag: %v", err)
+	}
+	if tw.Blocks() != 1 || uploaded.Len() != 2*PDP_BLOCKSIZE {
+		t.Fatalf("%d blocks tagged and %d bytes passed through", tw.Blocks(), uploaded.Len())
+	}
+	if _, err := tw.Write(block); !errors.Is(err, errTestSink) {
+		t.Fatalf("write after a refused tag: %v", err)
+	}
+
+	/* ... and so does a refused final partial block, reported by Close */
+	tw = NewTagWriter(key, ioutil.Discard, &failing_test_sink{limit: 0})
+	if _, err := tw.Write(block[:100]); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); !errors.Is(err, errTestSink) {
+		t.Fatalf("close with a refused tag: %v", err)
+	}
+
+	/* Nothing may be written after Close */
+	tw = NewTagWriter(key, ioutil.Discard, &failing_test_sink{limit: 10})
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(block); err == nil {
+		t.Fatal("wrote to a closed TagWriter")
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package gopdp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	/* ACL entry granting a client every file the server holds */
+	PDP_ACL_ALL_FILES = "*"
+)
+
+var ErrPermissionDenied = errors.New("pdp: client may not audit this file")
+
+/* NewPDPServerTLSConfig: Returns a TLS config for an audit server presenting certFile/keyFile and
+ * requiring client certificates signed by a CA in the PEM bundle clientCAFile.
+ */
+func NewPDPServerTLSConfig(certFile string, keyFile string, clientCAFile string) (*tls.Config, error) {
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := load_ca_bundle(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}, nil
+}
+
+/* NewPDPClientTLSConfig: Returns a TLS config for a verifier presenting certFile/keyFile and accepting
+ * only servers whose certificate is signed by a CA in serverCAFile and names serverName (if serverName
+ * is empty, the host dialled is used).
+ */
+func NewPDPClientTLSConfig(certFile string, keyFile string, serverCAFile string, serverName string) (*tls.Config, error) {
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := load_ca_bundle(serverCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   serverName,
+	}, nil
+}
+
+/* load_ca_bundle: Reads a PEM file of one or more CA certificates. */
+func load_ca_bundle(path string) (*x509.CertPool, error) {
+	var pool = x509.NewCertPool()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("pdp: no certificates in CA bundle %s", path)
+	}
+
+	return pool, nil
+}
+
+/* PDP_acl says which clients may audit which files.  Clients are named by the transport that
+ * authenticated them: for TLS, the certificate's subject common name or any DNS, email or URI subject
+ * alternative name; for libp2p, the peer ID.
+ */
+type PDP_acl struct {
+	mutex   sync.RWMutex
+	clients map[string]map[string]bool
+}
+
+func NewPDPACL() *PDP_acl {
+	return &PDP_acl{clients: make(map[string]map[string]bool)}
+}
+
+/* LoadPDPACL: Reads an ACL file.  Each line names a client followed by the file IDs it may audit, or *
+ * for all files; blank lines and lines starting with # are ignored:
+ *
+ *   auditor.example.com  photos-2021 backups
+ *   ops-auditor          *
+ */
+func LoadPDPACL(path string) (*PDP_acl, error) {
+	var acl = NewPDPACL()
+	var line_number int = 0
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line_number++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("pdp: %s:%d: client %q has no files", path, line_number, fields[0])
+		}
+		acl.Allow(fields[0], fields[1:]...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return acl, nil
+}
+
+/* Allow lets client audit fileIDs. */
+func (acl *PDP_acl) Allow(client string, fileIDs ...string) {
+	acl.mutex.Lock()
+	defer acl.mutex.Unlock()
+
+	files := acl.clients[client]
+	if files == nil {
+		files = make(map[string]bool)
+		acl.clients[client] = files
+	}
+	for _, fileID := range fileIDs {
+		files[fileID] = true
+	}
+}
+
+/* allowed: Returns whether a client known by any of names may audit fileID. */
+func (acl *PDP_acl) allowed(names []string, fileID string) bool {
+	acl.mutex.RLock()
+	defer acl.mutex.RUnlock()
+
+	for _, name := range names {
+		files := acl.clients[name]
+		if files[fileID] || files[PDP_ACL_ALL_FILES] {
+			return true
+		}
+	}
+
+	return false
+}
+
+/* certificate_names: The names a client certificate can be listed under in an ACL. */
+func certificate_names(cert *x509.Certificate) []string {
+	var names []string
+
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+
+	return names
+}
+
+type pdp_client_names_key struct{}
+
+/* with_client_names: Attaches the authenticated names of the requesting client to ctx. */
+func with_client_names(ctx context.Context, names []string) context.Context {
+	return context.WithValue(ctx, pdp_client_names_key{}, names)
+}
+
+/* client_names: The client names attached to ctx, or nil for an unauthenticated client. */
+func client_names(ctx context.Context) []string {
+	names, _ := ctx.Value(pdp_client_names_key{}).([]string)
+	return names
+}
+
+/* with_client_cert: Attaches the names in the verified client certificate of a connection to ctx.
+ * state may be nil for plaintext connections.
+ */
+func with_client_cert(ctx context.Context, state *tls.ConnectionState) context.Context {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ctx
+	}
+	return with_client_names(ctx, certificate_names(state.VerifiedChains[0][0]))
+}
+
+/* grpc_client_cert: with_client_cert for a gRPC request context. */
+func grpc_client_cert(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	return with_client_cert(ctx, &info.State)
+}
@@ -0,0 +1,230 @@
+package gopdp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+/* The name the test server certificate is issued to */
+const tls_test_server_name = "pdp-server.test"
+
+type tls_test_ca struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	dir    string
+	serial int64
+}
+
+/* new_test_ca: Generates a self-signed CA and writes its certificate to ca.pem in a temporary directory. */
+func new_test_ca(t testing.TB) *tls_test_ca {
+	t.Helper()
+
+	var ca = &tls_test_ca{dir: t.TempDir(), serial: 1}
+	var err error
+
+	ca.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(ca.serial),
+		Subject:               pkix.Name{CommonName: "pdp test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &ca.key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca.cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	write_test_pem(t, ca.path("ca.pem"), "CERTIFICATE", der)
+
+	return ca
+}
+
+func (ca *tls_test_ca) path(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+/* issue: Issues a certificate for commonName, and for dnsName if it isn't empty, usable by a server or
+ * a client.  Writes it and its key to <commonName>.pem and <commonName>.key and returns their paths.
+ */
+func (ca *tls_test_ca) issue(t testing.TB, commonName string, dnsName string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if dnsName != "" {
+		template.DNSNames = []string{dnsName}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key_der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert_path, key_path := ca.path(commonName+".pem"), ca.path(commonName+".key")
+	write_test_pem(t, cert_path, "CERTIFICATE", der)
+	write_test_pem(t, key_path, "EC PRIVATE KEY", key_der)
+
+	return cert_path, key_path
+}
+
+func write_test_pem(t testing.TB, path string, kind string, der []byte) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/* tls_test_setup: A CA issuing the server's certificate and those of the clients "alice", which may
+ * audit "data", and "bob", which may audit only "other".  Returns the CA and the server's TLS config.
+ */
+func tls_test_setup(t testing.TB, server *PDPServer) (*tls_test_ca, *tls.Config) {
+	t.Helper()
+
+	ca := new_test_ca(t)
+	cert, key := ca.issue(t, "server", tls_test_server_name)
+	config, err := NewPDPServerTLSConfig(cert, key, ca.path("ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acl := NewPDPACL()
+	acl.Allow("alice", "data")
+	acl.Allow("bob", "other")
+	server.SetACL(acl)
+
+	return ca, config
+}
+
+/* tls_test_client_config: The TLS config of client, issued by ca, trusting servers issued by serverCA. */
+func tls_test_client_config(t testing.TB, ca *tls_test_ca, client string, serverCA *tls_test_ca) *tls.Config {
+	t.Helper()
+
+	cert, key := ca.issue(t, client, "")
+	config, err := NewPDPClientTLSConfig(cert, key, serverCA.path("ca.pem"), tls_test_server_name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func TestHTTPMutualTLS(t *testing.T) {
+	var ctx = context.Background()
+
+	key, server, _, _ := serve_test_file(t, 5)
+	ca, config := tls_test_setup(t, server)
+	ts := httptest.NewUnstartedServer(NewPDPHTTPServer(server))
+	ts.TLS = config
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	audit := func(config *tls.Config) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		return NewPDPHTTPClient(ts.URL, client, key).Audit(ctx, "data", 5)
+	}
+
+	if err := audit(tls_test_client_config(t, ca, "alice", ca)); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit(tls_test_client_config(t, ca, "bob", ca)); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("audit by a client not in the ACL for the file: %v", err)
+	}
+
+	/* A certificate from another CA, or none, does not complete the handshake */
+	if err := audit(tls_test_client_config(t, new_test_ca(t), "alice", ca)); err == nil {
+		t.Fatal("audit with a certificate from another CA succeeded")
+	}
+	no_cert := tls_test_client_config(t, ca, "alice", ca)
+	no_cert.Certificates = nil
+	if err := audit(no_cert); err == nil {
+		t.Fatal("audit without a client certificate succeeded")
+	}
+
+	/* The client does not accept a server certificate from another CA */
+	if err := audit(tls_test_client_config(t, ca, "alice", new_test_ca(t))); err == nil {
+		t.Fatal("audit of a server with a certificate from another CA succeeded")
+	}
+}
+
+func TestGRPCMutualTLS(t *testing.T) {
+	var ctx = context.Background()
+
+	key, server, _, _ := serve_test_file(t, 5)
+	ca, config := tls_test_setup(t, server)
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(grpc.Creds(credentials.NewTLS(config)))
+	RegisterPDPGRPCServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	audit := func(config *tls.Config) error {
+		conn, err := grpc.DialContext(ctx, "bufconn",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+				return listener.Dial()
+			}), grpc.WithTransportCredentials(credentials.NewTLS(config)))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		return NewPDPGRPCClient(conn, key).Audit(ctx, "data", 5)
+	}
+
+	if err := audit(tls_test_client_config(t, ca, "alice", ca)); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit(tls_test_client_config(t, ca, "bob", ca)); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("audit by a client not in the ACL for the file: %v", err)
+	}
+	if err := audit(tls_test_client_config(t, new_test_ca(t), "alice", ca)); err == nil {
+		t.Fatal("audit with a certificate from another CA succeeded")
+	}
+}
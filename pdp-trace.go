@@ -0,0 +1,145 @@
+package gopdp
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+/* Spans go to the global tracer provider and trace context crosses the HTTP, gRPC and libp2p transports
+ * with the global propagator, so an application enables tracing with otel.SetTracerProvider and
+ * otel.SetTextMapPropagator(propagation.TraceContext{}).  Until then both are no-ops.
+ *
+ *   pdp.audit                     verifier: one remote audit
+ *     pdp.challenge               pdp_challenge
+ *     pdp.verify_proof            pdp_verify_proof
+ *   pdp.prove_file                prover: one proof, a child of the verifier's span across transports
+ *     pdp.read_blocks             the block and tag reads, with their summed time
+ *     pdp.generate_proof_update   folding blocks into partial proofs, with its summed time
+ *     pdp.generate_proof_final    pdp_generate_proof_final
+ */
+
+const (
+	PDP_TRACER_NAME = "github.com/kebohan1/go-pdp"
+
+	/* The W3C trace context field carried by libp2p requests */
+	PDP_TRACEPARENT_HEADER = "traceparent"
+)
+
+/* start_span: Starts a span named name under ctx's span. */
+func start_span(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(PDP_TRACER_NAME).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+/* end_span: Ends span, marking it failed if err is set. */
+func end_span(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+/* inject_http: Adds ctx's trace context to the headers of an outgoing request. */
+func inject_http(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+/* extract_http: Returns ctx carrying the trace context of an incoming request. */
+func extract_http(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+/* grpc_metadata_carrier adapts gRPC metadata to the propagator. */
+type grpc_metadata_carrier metadata.MD
+
+func (carrier grpc_metadata_carrier) Get(key string) string {
+	values := metadata.MD(carrier).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (carrier grpc_metadata_carrier) Set(key string, value string) {
+	metadata.MD(carrier).Set(key, value)
+}
+
+func (carrier grpc_metadata_carrier) Keys() []string {
+	var keys = make([]string, 0, len(carrier))
+
+	for key := range carrier {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+/* inject_grpc: Returns ctx with its trace context added to the outgoing gRPC metadata. */
+func inject_grpc(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, grpc_metadata_carrier(md))
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+/* extract_grpc: Returns ctx carrying the trace context of an incoming gRPC call. */
+func extract_grpc(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, grpc_metadata_carrier(md))
+}
+
+/* libp2p_trace_carrier holds the fields the propagator reads and writes for a libp2p request. */
+type libp2p_trace_carrier map[string]string
+
+func (carrier libp2p_trace_carrier) Get(key string) string {
+	return carrier[key]
+}
+
+func (carrier libp2p_trace_carrier) Set(key string, value string) {
+	carrier[key] = value
+}
+
+func (carrier libp2p_trace_carrier) Keys() []string {
+	var keys = make([]string, 0, len(carrier))
+
+	for key := range carrier {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+/* inject_libp2p: The W3C traceparent of ctx, as the global propagator writes it, for the trace context
+ * field of a libp2p request frame.  Empty if ctx has no span or no propagator is set.  Other fields the
+ * propagator writes, such as tracestate and baggage, are not carried.
+ */
+func inject_libp2p(ctx context.Context) []byte {
+	var carrier = libp2p_trace_carrier{}
+
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	return []byte(carrier.Get(PDP_TRACEPARENT_HEADER))
+}
+
+/* extract_libp2p: Returns ctx carrying the trace context of a libp2p request's traceparent. */
+func extract_libp2p(ctx context.Context, traceparent []byte) context.Context {
+	if len(traceparent) == 0 {
+		return ctx
+	}
+	carrier := libp2p_trace_carrier{PDP_TRACEPARENT_HEADER: string(traceparent)}
+
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
@@ -0,0 +1,115 @@
+package gopdp
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+/* trace_test_exporter: Installs a tracer provider exporting every span to memory as it ends, and the W3C
+ * trace context propagator, for the rest of the test.
+ */
+func trace_test_exporter(t testing.TB) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	old_provider, old_propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(old_provider)
+		otel.SetTextMapPropagator(old_propagator)
+	})
+
+	return exporter
+}
+
+/* find_test_span: The one span named name, or fails the test. */
+func find_test_span(t testing.TB, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+
+	var found []tracetest.SpanStub
+
+	for _, span := range spans {
+		if span.Name == name {
+			found = append(found, span)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("%d %s spans", len(found), name)
+	}
+
+	return found[0]
+}
+
+/* check_test_child: Fails the test unless child is a span directly under parent. */
+func check_test_child(t testing.TB, parent tracetest.SpanStub, child tracetest.SpanStub) {
+	t.Helper()
+
+	if child.SpanContext.TraceID() != parent.SpanContext.TraceID() || child.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Fatalf("%s is not a child of %s", child.Name, parent.Name)
+	}
+}
+
+func TestTraceAcrossTransports(t *testing.T) {
+	var ctx = context.Background()
+
+	exporter := trace_test_exporter(t)
+	key, server, path, data := serve_test_file(t, 30)
+	ts := httptest.NewServer(NewPDPHTTPServer(server))
+	defer ts.Close()
+	verifier, prover := libp2p_test_hosts(t)
+	RegisterPDPLibp2pServer(prover, server)
+
+	for _, test := range []struct {
+		name   string
+		client interface {
+			Audit(ctx context.Context, fileID string, numfileblocks uint64) error
+		}
+	}{
+		{"grpc", grpc_test_client(t, server, key)},
+		{"http", NewPDPHTTPClient(ts.URL, ts.Client(), key)},
+		{"libp2p", NewPDPLibp2pClient(verifier, prover.ID(), key)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			exporter.Reset()
+			if err := test.client.Audit(ctx, "data", 30); err != nil {
+				t.Fatal(err)
+			}
+			spans := exporter.GetSpans()
+
+			audit := find_test_span(t, spans, "pdp.audit")
+			check_test_child(t, audit, find_test_span(t, spans, "pdp.challenge"))
+			check_test_child(t, audit, find_test_span(t, spans, "pdp.verify_proof"))
+
+			/* The prover's spans join the verifier's trace across the transport */
+			prove := find_test_span(t, spans, "pdp.prove_file")
+			check_test_child(t, audit, prove)
+			if !prove.Parent.IsRemote() {
+				t.Fatal("pdp.prove_file's parent is not remote")
+			}
+			check_test_child(t, prove, find_test_span(t, spans, "pdp.read_blocks"))
+			check_test_child(t, prove, find_test_span(t, spans, "pdp.generate_proof_update"))
+			check_test_child(t, prove, find_test_span(t, spans, "pdp.generate_proof_final"))
+		})
+	}
+
+	/* A failed audit marks its span */
+	exporter.Reset()
+	corrupt_test_file(t, path, data)
+	if err := NewPDPHTTPClient(ts.URL, ts.Client(), key).Audit(ctx, "data", 30); !errors.Is(err, ErrProofRejected) {
+		t.Fatalf("audit of a corrupted file: %v", err)
+	}
+	if audit := find_test_span(t, exporter.GetSpans(), "pdp.audit"); audit.Status.Code != codes.Error {
+		t.Fatalf("failed audit's span has status %v", audit.Status)
+	}
+}
@@ -0,0 +1,592 @@
+package gopdp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/circl/ecc/bls12381"
+)
+
+const (
+	PDP_WIRE_VERSION = 1
+
+	PDP_WIRE_CHALLENGE    = 1
+	PDP_WIRE_PROOF        = 2
+	PDP_WIRE_PUBLIC_PROOF = 3
+	PDP_WIRE_MASKED_PROOF = 4
+	PDP_WIRE_BLS_PROOF    = 5
+
+	/* Longest H() output accepted in a proof (the largest OpenSSL digest) */
+	PDP_WIRE_MAX_RHO_SIZE = 64
+
+	/* Longest M accepted in a public proof: a sum of fewer than 2^32 products of a replica block and a
+	 * coefficient no longer than the largest digest
+	 */
+	PDP_WIRE_MAX_M_SIZE = PDP_REPLICA_BLOCKSIZE + PDP_WIRE_MAX_RHO_SIZE + 4
+
+	/* Longest mu accepted in a masked proof, r + gamma * M with r as long as gamma * M and the mask */
+	PDP_WIRE_MAX_MU_SIZE = PDP_WIRE_MAX_M_SIZE + PDP_MASK_GAMMA_SIZE + PDP_MASK_SECURITY/8 + 1
+)
+
+/* Challenges and proofs travel as a two byte preamble, message type and version, followed by
+ * fixed-size integers and u32 length-prefixed byte strings, all big-endian.  BIGNUMs are minimal
+ * big-endian byte strings, so a leading zero byte is rejected:
+ *
+ *   challenge:    type | version | c u32 | numfileblocks u64 | g_s | k1 | k2 [| name]
+ *   proof:        type | version | T | rho
+ *   public proof: type | version | T | M
+ *   masked proof: type | version | T | R | mu
+ *   BLS proof:    type | version | T | sectors u32 | mu_1 | ... | mu_s
+ *
+ * Only sanitized challenges are encoded; s never leaves the verifier.  A public challenge, see
+ * pdp_challenge_public, has an empty g_s and is answered with a public proof.  Under a BLS key every
+ * challenge is public and ends with the name of the file it is for, T is sigma, a compressed G1 point,
+ * and the proof carries the sector sums.  A MAC challenge, see pdp-mac.go, is the same, and its proof
+ * has the same layout with sigma in Z_p.
+ * Decoding is strict: every field is range-checked against the key and trailing bytes are rejected.
+ */
+
+var ErrBadWireFormat = errors.New("pdp: malformed message")
+
+/* The JSON forms are for debugging and carry the same fields, numbers in hex. */
+type pdp_challenge_json struct {
+	Version       int    `json:"version"`
+	C             uint   `json:"c"`
+	NumFileBlocks uint64 `json:"numfileblocks"`
+	Gs            string `json:"g_s"`
+	K1            string `json:"k1"`
+	K2            string `json:"k2"`
+	Name          string `json:"name,omitempty"`
+}
+
+type pdp_proof_json struct {
+	Version int      `json:"version"`
+	T       string   `json:"T"`
+	Rho     string   `json:"rho,omitempty"`
+	M       *string  `json:"M,omitempty"`
+	R       string   `json:"R,omitempty"`
+	Mu      []string `json:"mu,omitempty"`
+}
+
+/* encode_pdp_challenge: Encodes the public part of a challenge.  Returns nil on failure. */
+func encode_pdp_challenge(challenge *PDP_challenge) []byte {
+	var buf []byte
+	var g_s []byte
+
+	if challenge == nil || challenge.k1 == nil || challenge.k2 == nil {
+		return nil
+	}
+	if challenge.g_s != nil {
+		g_s = challenge.g_s.Bytes()
+	}
+
+	buf = []byte{PDP_WIRE_CHALLENGE, PDP_WIRE_VERSION}
+	buf = append_u32(buf, uint32(challenge.c))
+	buf = append_u64(buf, uint64(challenge.numfileblocks))
+	buf = append_bytes(buf, g_s)
+	buf = append_bytes(buf, *challenge.k1)
+	buf = append_bytes(buf, *challenge.k2)
+	if challenge.name != nil {
+		buf = append_bytes(buf, challenge.name)
+	}
+
+	return buf
+}
+
+/* decode_pdp_challenge: Decodes a challenge for a file of numfileblocks blocks under key.  c must be
+ * between 1 and the challenge's block count, which may not exceed numfileblocks, g_s must be in Z*_N
+ * or empty for a public challenge, and a BLS or MAC challenge must name a file.
+ * Returns a sanitized challenge (s is nil) or ErrBadWireFormat.
+ */
+func decode_pdp_challenge(data []byte, key *PDP_key, numfileblocks uint64) (*PDP_challenge, error) {
+	var reader = &pdp_wire_reader{data: data}
+	var challenge = &PDP_challenge{}
+
+	if !valid_pdp_key(key) {
+		return nil, errors.New("pdp: invalid key")
+	}
+	if reader.u8() != PDP_WIRE_CHALLENGE || reader.u8() != PDP_WIRE_VERSION {
+		return nil, ErrBadWireFormat
+	}
+	c := reader.u32()
+	n := reader.u64()
+	g_s := reader.bytes(modulus_size(key))
+	k1 := reader.bytes(PRP_KEY_SIZE)
+	k2 := reader.bytes(PRF_KEY_SIZE)
+	if scheme_names_files(pdp_key_scheme(key)) {
+		challenge.name = reader.bytes(PDP_TAG_FILE_NAME_SIZE)
+	}
+	if !reader.done() {
+		return nil, ErrBadWireFormat
+	}
+
+	if err := check_pdp_challenge(key, uint64(c), n, g_s, k1, k2, challenge.name, numfileblocks); err != nil {
+		return nil, err
+	}
+	challenge.c = uint(c)
+	challenge.numfileblocks = uint(n)
+	if len(g_s) > 0 {
+		challenge.g_s = new(big.Int).SetBytes(g_s)
+	}
+	challenge.k1 = &k1
+	challenge.k2 = &k2
+
+	return challenge, nil
+}
+
+/* encode_pdp_proof: Encodes a final proof, a public proof if it has no rho and a masked one if it also
+ * has R, or a BLS proof if it has sector sums.  Returns nil on failure.
+ */
+func encode_pdp_proof(proof *PDP_proof) []byte {
+	var buf []byte
+
+	if proof != nil && proof.T != nil && proof.mu != nil {
+		buf = []byte{PDP_WIRE_BLS_PROOF, PDP_WIRE_VERSION}
+		buf = append_bytes(buf, proof.T.Bytes())
+		buf = append_u32(buf, uint32(len(proof.mu)))
+		for _, mu := range proof.mu {
+			buf = append_bytes(buf, mu.Bytes())
+		}
+		return buf
+	}
+	if proof == nil || proof.T == nil || (proof.rho == nil && proof.rho_temp == nil) {
+		return nil
+	}
+
+	if proof.rho == nil && proof.R != nil {
+		buf = []byte{PDP_WIRE_MASKED_PROOF, PDP_WIRE_VERSION}
+		buf = append_bytes(buf, proof.T.Bytes())
+		buf = append_bytes(buf, proof.R.Bytes())
+		return append_bytes(buf, proof.rho_temp.Bytes())
+	}
+	if proof.rho == nil {
+		buf = []byte{PDP_WIRE_PUBLIC_PROOF, PDP_WIRE_VERSION}
+		buf = append_bytes(buf, proof.T.Bytes())
+		return append_bytes(buf, proof.rho_temp.Bytes())
+	}
+	buf = []byte{PDP_WIRE_PROOF, PDP_WIRE_VERSION}
+	buf = append_bytes(buf, proof.T.Bytes())
+	buf = append_bytes(buf, []byte(*proof.rho))
+
+	return buf
+}
+
+/* decode_pdp_proof: Decodes a proof under key.  T must be in Z*_N and rho a non-empty digest, or M
+ * no longer than PDP_WIRE_MAX_M_SIZE in a public proof, or R in Z*_N and mu no longer than
+ * PDP_WIRE_MAX_MU_SIZE in a masked proof.  Under a BLS or MAC key only a BLS proof is accepted, with T
+ * in G1 or below p and PDP_BLS_SECTORS sums below p.
+ * Returns the proof or ErrBadWireFormat.
+ */
+func decode_pdp_proof(data []byte, key *PDP_key) (*PDP_proof, error) {
+	var reader = &pdp_wire_reader{data: data}
+
+	if !valid_pdp_key(key) {
+		return nil, errors.New("pdp: invalid key")
+	}
+	message_type := reader.u8()
+	if message_type < PDP_WIRE_PROOF || message_type > PDP_WIRE_BLS_PROOF || reader.u8() != PDP_WIRE_VERSION {
+		return nil, ErrBadWireFormat
+	}
+	if (key.bls != nil || key.mac != nil) != (message_type == PDP_WIRE_BLS_PROOF) {
+		return nil, ErrBadWireFormat
+	}
+	T := reader.bytes(modulus_size(key))
+	switch message_type {
+	case PDP_WIRE_BLS_PROOF:
+		if reader.u32() != PDP_BLS_SECTORS {
+			return nil, ErrBadWireFormat
+		}
+		mu := make([][]byte, PDP_BLS_SECTORS)
+		for j := range mu {
+			mu[j] = reader.bytes(bls12381.ScalarSize)
+		}
+		if !reader.done() {
+			return nil, ErrBadWireFormat
+		}
+		return check_pdp_sector_proof(key, T, mu)
+	case PDP_WIRE_PUBLIC_PROOF:
+		M := reader.bytes(PDP_WIRE_MAX_M_SIZE)
+		if !reader.done() {
+			return nil, ErrBadWireFormat
+		}
+		return check_pdp_public_proof(key, T, nil, M)
+	case PDP_WIRE_MASKED_PROOF:
+		R := reader.bytes(modulus_size(key))
+		mu := reader.bytes(PDP_WIRE_MAX_MU_SIZE)
+		if !reader.done() || len(R) == 0 {
+			return nil, ErrBadWireFormat
+		}
+		return check_pdp_public_proof(key, T, R, mu)
+	}
+	rho := reader.bytes(PDP_WIRE_MAX_RHO_SIZE)
+	if !reader.done() {
+		return nil, ErrBadWireFormat
+	}
+
+	return check_pdp_proof(key, T, rho)
+}
+
+/* encode_pdp_challenge_json: The JSON form of encode_pdp_challenge. */
+func encode_pdp_challenge_json(challenge *PDP_challenge) ([]byte, error) {
+
+	var g_s []byte
+
+	if challenge == nil || challenge.k1 == nil || challenge.k2 == nil {
+		return nil, ErrBadWireFormat
+	}
+	if challenge.g_s != nil {
+		g_s = challenge.g_s.Bytes()
+	}
+
+	return json.Marshal(&pdp_challenge_json{
+		Version:       PDP_WIRE_VERSION,
+		C:             challenge.c,
+		NumFileBlocks: uint64(challenge.numfileblocks),
+		Gs:            hex.EncodeToString(g_s),
+		K1:            hex.EncodeToString(*challenge.k1),
+		K2:            hex.EncodeToString(*challenge.k2),
+		Name:          hex.EncodeToString(challenge.name),
+	})
+}
+
+/* decode_pdp_challenge_json: The JSON form of decode_pdp_challenge, with the same checks. */
+func decode_pdp_challenge_json(data []byte, key *PDP_key, numfileblocks uint64) (*PDP_challenge, error) {
+	var message pdp_challenge_json
+	var challenge = &PDP_challenge{}
+
+	if !valid_pdp_key(key) {
+		return nil, errors.New("pdp: invalid key")
+	}
+	if err := json.Unmarshal(data, &message); err != nil || message.Version != PDP_WIRE_VERSION {
+		return nil, ErrBadWireFormat
+	}
+	g_s, err1 := hex.DecodeString(message.Gs)
+	k1, err2 := hex.DecodeString(message.K1)
+	k2, err3 := hex.DecodeString(message.K2)
+	name, err4 := hex.DecodeString(message.Name)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || len(g_s) > modulus_size(key) {
+		return nil, ErrBadWireFormat
+	}
+	if uint64(message.C) > 0xffffffff {
+		return nil, ErrBadWireFormat
+	}
+	if len(name) > 0 {
+		challenge.name = name
+	}
+
+	if err := check_pdp_challenge(key, uint64(message.C), message.NumFileBlocks, g_s, k1, k2, challenge.name,
+		numfileblocks); err != nil {
+		return nil, err
+	}
+	challenge.c = message.C
+	challenge.numfileblocks = uint(message.NumFileBlocks)
+	if len(g_s) > 0 {
+		challenge.g_s = new(big.Int).SetBytes(g_s)
+	}
+	challenge.k1 = &k1
+	challenge.k2 = &k2
+
+	return challenge, nil
+}
+
+/* encode_pdp_proof_json: The JSON form of encode_pdp_proof. */
+func encode_pdp_proof_json(proof *PDP_proof) ([]byte, error) {
+
+	if proof != nil && proof.T != nil && proof.mu != nil {
+		var mu = make([]string, len(proof.mu))
+		for j := range proof.mu {
+			mu[j] = hex.EncodeToString(proof.mu[j].Bytes())
+		}
+		return json.Marshal(&pdp_proof_json{
+			Version: PDP_WIRE_VERSION,
+			T:       hex.EncodeToString(proof.T.Bytes()),
+			Mu:      mu,
+		})
+	}
+	if proof == nil || proof.T == nil || (proof.rho == nil && proof.rho_temp == nil) {
+		return nil, ErrBadWireFormat
+	}
+
+	if proof.rho == nil {
+		var R []byte
+		if proof.R != nil {
+			R = proof.R.Bytes()
+		}
+		M := hex.EncodeToString(proof.rho_temp.Bytes())
+		return json.Marshal(&pdp_proof_json{
+			Version: PDP_WIRE_VERSION,
+			T:       hex.EncodeToString(proof.T.Bytes()),
+			M:       &M,
+			R:       hex.EncodeToString(R),
+		})
+	}
+	return json.Marshal(&pdp_proof_json{
+		Version: PDP_WIRE_VERSION,
+		T:       hex.EncodeToString(proof.T.Bytes()),
+		Rho:     hex.EncodeToString([]byte(*proof.rho)),
+	})
+}
+
+/* decode_pdp_proof_json: The JSON form of decode_pdp_proof, with the same checks. */
+func decode_pdp_proof_json(data []byte, key *PDP_key) (*PDP_proof, error) {
+	var message pdp_proof_json
+
+	if !valid_pdp_key(key) {
+		return nil, errors.New("pdp: invalid key")
+	}
+	if err := json.Unmarshal(data, &message); err != nil || message.Version != PDP_WIRE_VERSION {
+		return nil, ErrBadWireFormat
+	}
+	T, err1 := hex.DecodeString(message.T)
+	if key.bls != nil || key.mac != nil {
+		if err1 != nil || message.Rho != "" || message.M != nil || message.R != "" ||
+			len(T) > modulus_size(key) || len(message.Mu) != PDP_BLS_SECTORS {
+			return nil, ErrBadWireFormat
+		}
+		mu := make([][]byte, PDP_BLS_SECTORS)
+		for j := range mu {
+			var err error
+			if mu[j], err = hex.DecodeString(message.Mu[j]); err != nil || len(mu[j]) > bls12381.ScalarSize {
+				return nil, ErrBadWireFormat
+			}
+		}
+		return check_pdp_sector_proof(key, T, mu)
+	}
+	if message.Mu != nil {
+		return nil, ErrBadWireFormat
+	}
+	if message.M != nil {
+		M, err2 := hex.DecodeString(*message.M)
+		R, err3 := hex.DecodeString(message.R)
+		if err1 != nil || err2 != nil || err3 != nil || message.Rho != "" || len(T) > modulus_size(key) ||
+			len(R) > modulus_size(key) || len(M) > PDP_WIRE_MAX_MU_SIZE {
+			return nil, ErrBadWireFormat
+		}
+		if len(R) == 0 {
+			return check_pdp_public_proof(key, T, nil, M)
+		}
+		return check_pdp_public_proof(key, T, R, M)
+	}
+	rho, err2 := hex.DecodeString(message.Rho)
+	if err1 != nil || err2 != nil || len(T) > modulus_size(key) || len(rho) > PDP_WIRE_MAX_RHO_SIZE {
+		return nil, ErrBadWireFormat
+	}
+
+	return check_pdp_proof(key, T, rho)
+}
+
+/* check_pdp_challenge: The range checks shared by the binary and JSON challenge decoders. */
+func check_pdp_challenge(key *PDP_key, c uint64, n uint64, g_s []byte, k1 []byte, k2 []byte, name []byte,
+	numfileblocks uint64) error {
+
+	if c == 0 || c > n || n > numfileblocks {
+		return fmt.Errorf("%w: c=%d of %d blocks, file has %d", ErrBadWireFormat, c, n, numfileblocks)
+	}
+	if len(k1) != PRP_KEY_SIZE || len(k2) != PRF_KEY_SIZE || !minimal_bn(g_s) {
+		return ErrBadWireFormat
+	}
+	if len(g_s) > 0 && (key.bls != nil || key.mac != nil) {
+		return fmt.Errorf("%w: g_s in a BLS or MAC challenge", ErrBadWireFormat)
+	}
+	if len(g_s) > 0 && in_z_star_n(key, new(big.Int).SetBytes(g_s)) == 0 {
+		return fmt.Errorf("%w: g_s is not in Z*_N", ErrBadWireFormat)
+	}
+	if scheme_names_files(pdp_key_scheme(key)) {
+		if len(name) != PDP_TAG_FILE_NAME_SIZE {
+			return fmt.Errorf("%w: a BLS or MAC challenge must name the file", ErrBadWireFormat)
+		}
+	} else if name != nil {
+		return fmt.Errorf("%w: name in an RSA challenge", ErrBadWireFormat)
+	}
+
+	return nil
+}
+
+/* check_pdp_proof: The range checks shared by the binary and JSON proof decoders. */
+func check_pdp_proof(key *PDP_key, T []byte, rho []byte) (*PDP_proof, error) {
+	var proof = &PDP_proof{}
+
+	if len(rho) == 0 || len(rho) > PDP_WIRE_MAX_RHO_SIZE || !minimal_bn(T) {
+		return nil, ErrBadWireFormat
+	}
+	proof.T = new(big.Int).SetBytes(T)
+	if in_z_star_n(key, proof.T) == 0 {
+		return nil, fmt.Errorf("%w: T is not in Z*_N", ErrBadWireFormat)
+	}
+	rho_string := string(rho)
+	proof.rho = &rho_string
+	proof.rho_size = uint64(len(rho))
+
+	return proof, nil
+}
+
+/* check_pdp_public_proof: The range checks shared by the binary and JSON public proof decoders.  R is
+ * nil for an unmasked proof, whose M is then limited to PDP_WIRE_MAX_M_SIZE.
+ */
+func check_pdp_public_proof(key *PDP_key, T []byte, R []byte, M []byte) (*PDP_proof, error) {
+	var proof = &PDP_proof{}
+
+	if (R == nil && len(M) > PDP_WIRE_MAX_M_SIZE) || len(M) > PDP_WIRE_MAX_MU_SIZE {
+		return nil, ErrBadWireFormat
+	}
+	if !minimal_bn(T) || !minimal_bn(R) || !minimal_bn(M) {
+		return nil, ErrBadWireFormat
+	}
+	proof.T = new(big.Int).SetBytes(T)
+	if in_z_star_n(key, proof.T) == 0 {
+		return nil, fmt.Errorf("%w: T is not in Z*_N", ErrBadWireFormat)
+	}
+	if R != nil {
+		proof.R = new(big.Int).SetBytes(R)
+		if in_z_star_n(key, proof.R) == 0 {
+			return nil, fmt.Errorf("%w: R is not in Z*_N", ErrBadWireFormat)
+		}
+	}
+	proof.rho_temp = new(big.Int).SetBytes(M)
+
+	return proof, nil
+}
+
+/* check_pdp_sector_proof: The range checks shared by the binary and JSON BLS and MAC proof decoders. */
+func check_pdp_sector_proof(key *PDP_key, T []byte, mu [][]byte) (*PDP_proof, error) {
+	var proof = &PDP_proof{mu: make([]*big.Int, len(mu))}
+	var order = new(big.Int).SetBytes(bls12381.Order())
+
+	if !minimal_bn(T) {
+		return nil, ErrBadWireFormat
+	}
+	proof.T = new(big.Int).SetBytes(T)
+	if key.bls != nil && bn_to_g1(proof.T) == nil {
+		return nil, fmt.Errorf("%w: T is not in G1", ErrBadWireFormat)
+	}
+	if key.mac != nil && bn_to_scalar(proof.T) == nil {
+		return nil, fmt.Errorf("%w: T is not in Z_p", ErrBadWireFormat)
+	}
+	for j := range mu {
+		if !minimal_bn(mu[j]) {
+			return nil, ErrBadWireFormat
+		}
+		proof.mu[j] = new(big.Int).SetBytes(mu[j])
+		if proof.mu[j].Cmp(order) >= 0 {
+			return nil, ErrBadWireFormat
+		}
+	}
+
+	return proof, nil
+}
+
+/* in_z_star_n: Returns 1 if 0 < x < N and gcd(x, N) = 1, 0 otherwise. */
+func in_z_star_n(key *PDP_key, x *big.Int) int {
+
+	if x.Sign() <= 0 || x.Cmp(key.rsa.N) >= 0 {
+		return 0
+	}
+	if new(big.Int).GCD(nil, nil, x, key.rsa.N).Cmp(bigOne) != 0 {
+		return 0
+	}
+
+	return 1
+}
+
+func minimal_bn(b []byte) bool {
+	return len(b) == 0 || b[0] != 0
+}
+
+/* modulus_size: The size in bytes of N, and so of tags, T and g_s; PDP_BLS_TAG_SIZE for a BLS key and
+ * PDP_MAC_TAG_SIZE for a MAC key.
+ */
+func modulus_size(key *PDP_key) int {
+	if key.bls != nil {
+		return PDP_BLS_TAG_SIZE
+	}
+	if key.mac != nil {
+		return PDP_MAC_TAG_SIZE
+	}
+	return (key.rsa.N.BitLen() + 7) / 8
+}
+
+/* valid_pdp_key: Returns whether key has the public components of either scheme. */
+func valid_pdp_key(key *PDP_key) bool {
+	return key != nil && ((key.bls != nil && key.bls.v != nil) || key.mac != nil || (key.rsa != nil && key.rsa.N != nil))
+}
+
+func append_u32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func append_u64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func append_bytes(buf []byte, data []byte) []byte {
+	buf = append_u32(buf, uint32(len(data)))
+	return append(buf, data...)
+}
+
+/* pdp_wire_reader reads fields off a message.  Any short read or oversized field sets bad, after
+ * which every read returns zero values, so decoders only need to check done() at the end.
+ */
+type pdp_wire_reader struct {
+	data []byte
+	bad  bool
+}
+
+func (reader *pdp_wire_reader) take(n int) []byte {
+	if reader.bad || n < 0 || len(reader.data) < n {
+		reader.bad = true
+		return nil
+	}
+	b := reader.data[:n]
+	reader.data = reader.data[n:]
+	return b
+}
+
+func (reader *pdp_wire_reader) u8() byte {
+	b := reader.take(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (reader *pdp_wire_reader) u32() uint32 {
+	b := reader.take(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (reader *pdp_wire_reader) u64() uint64 {
+	b := reader.take(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+/* bytes reads a length-prefixed field of at most max bytes and returns a copy of it. */
+func (reader *pdp_wire_reader) bytes(max int) []byte {
+	n := reader.u32()
+	if uint64(n) > uint64(max) {
+		reader.bad = true
+		return nil
+	}
+	b := reader.take(int(n))
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (reader *pdp_wire_reader) done() bool {
+	return !reader.bad && len(reader.data) == 0
+}
@@ -0,0 +1,309 @@
+package gopdp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+/* prove_test_blocks: Tags the first numfileblocks blocks of data under key in memory and answers
+ * challenge with them.  Returns the final proof.
+ */
+func prove_test_blocks(t testing.TB, key *PDP_key, challenge *PDP_challenge, data []byte) *PDP_proof {
+	t.Helper()
+
+	var core = NewPDPCore()
+	var proof *PDP_proof
+
+	for j, index := range core.generate_prp_pi(challenge) {
+		block := string(test_block(data, int(index)))
+		size := uint64(len(block))
+		tag := core.pdp_tag_block(key, &block, &size, index)
+		if tag == nil {
+			t.Fatalf("could not tag block %d", index)
+		}
+		proof = core.pdp_generate_proof_update(key, challenge, tag, proof, &block, &size, uint(j))
+		if proof == nil {
+			t.Fatalf("could not prove block %d", index)
+		}
+	}
+	proof = core.pdp_generate_proof_final(key, challenge, proof)
+	if proof == nil {
+		t.Fatal("pdp_generate_proof_final failed")
+	}
+
+	return proof
+}
+
+/* wire_test_keys: A key of each scheme, public for RSA and BLS and the prover's key for MAC, as a server
+ * decodes with them, and their secret forms, the BLS and MAC keys named after a file, for making proofs.
+ */
+func wire_test_keys(t testing.TB) ([]*PDP_key, []*PDP_key) {
+	t.Helper()
+
+	rsa := new_test_key(t)
+	bls := pdp_named_key(generate_bls_key(), []byte("wire test file 1"))
+	mac := pdp_named_key(generate_mac_key(), []byte("wire test file 2"))
+	if bls == nil || mac == nil {
+		t.Fatal("could not generate keys")
+	}
+
+	return []*PDP_key{public_test_key(rsa), bls_public_key(bls), pdp_mac_prover_key(mac)}, []*PDP_key{rsa, bls, mac}
+}
+
+/* wire_test_message: A challenge or proof, the key it is decoded under and its expected message type. */
+type wire_test_message struct {
+	name         string
+	key          *PDP_key
+	challenge    *PDP_challenge
+	proof        *PDP_proof
+	message_type byte
+}
+
+/* wire_test_messages: One message of every type under the keys of wire_test_keys. */
+func wire_test_messages(t *testing.T) []wire_test_message {
+	t.Helper()
+
+	var core = NewPDPCore()
+
+	_, data := write_test_file(t, 3*PDP_BLOCKSIZE-10)
+	public, secret := wire_test_keys(t)
+	rsa := core.pdp_challenge(secret[0], 3)
+	rsa_public := core.pdp_challenge_public(secret[0], 3)
+	bls := core.pdp_challenge(secret[1], 3)
+	mac := core.pdp_challenge(secret[2], 3)
+	public_proof := prove_test_blocks(t, secret[0], rsa_public, data)
+	masked := prove_test_blocks(t, secret[0], rsa_public, data)
+
+	return []wire_test_message{
+		{"challenge", public[0], core.sanitize_pdp_challenge(rsa), nil, PDP_WIRE_CHALLENGE},
+		{"public challenge", public[0], rsa_public, nil, PDP_WIRE_CHALLENGE},
+		{"BLS challenge", public[1], bls, nil, PDP_WIRE_CHALLENGE},
+		{"MAC challenge", public[2], mac, nil, PDP_WIRE_CHALLENGE},
+		{"proof", public[0], nil, prove_test_blocks(t, secret[0], rsa, data), PDP_WIRE_PROOF},
+		{"public proof", public[0], nil, public_proof, PDP_WIRE_PUBLIC_PROOF},
+		{"masked proof", public[0], nil, core.pdp_mask_proof(public[0], rsa_public, masked), PDP_WIRE_MASKED_PROOF},
+		{"BLS proof", public[1], nil, prove_test_blocks(t, secret[1], bls, data), PDP_WIRE_BLS_PROOF},
+		{"MAC proof", public[2], nil, prove_test_blocks(t, secret[2], mac, data), PDP_WIRE_BLS_PROOF},
+	}
+}
+
+/* encode_test_message: The binary and JSON encodings of message. */
+func encode_test_message(t *testing.T, message wire_test_message) ([]byte, []byte) {
+	t.Helper()
+
+	var data []byte
+	var json []byte
+	var err error
+
+	if message.challenge != nil {
+		data = encode_pdp_challenge(message.challenge)
+		json, err = encode_pdp_challenge_json(message.challenge)
+	} else {
+		data = encode_pdp_proof(message.proof)
+		json, err = encode_pdp_proof_json(message.proof)
+	}
+	if data == nil || err != nil {
+		t.Fatalf("%s: could not encode: %v", message.name, err)
+	}
+
+	return data, json
+}
+
+/* decode_test_message: Decodes the binary form of message, or its JSON form if json is set, and
+ * re-encodes the result in binary.
+ */
+func decode_test_message(message wire_test_message, data []byte, json bool) ([]byte, error) {
+
+	if message.challenge != nil {
+		var challenge *PDP_challenge
+		var err error
+		if json {
+			challenge, err = decode_pdp_challenge_json(data, message.key, 3)
+		} else {
+			challenge, err = decode_pdp_challenge(data, message.key, 3)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return encode_pdp_challenge(challenge), nil
+	}
+
+	var proof *PDP_proof
+	var err error
+	if json {
+		proof, err = decode_pdp_proof_json(data, message.key)
+	} else {
+		proof, err = decode_pdp_proof(data, message.key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return encode_pdp_proof(proof), nil
+}
+
+func TestWireRoundTrip(t *testing.T) {
+	for _, message := range wire_test_messages(t) {
+		data, json := encode_test_message(t, message)
+		if data[0] != message.message_type || data[1] != PDP_WIRE_VERSION {
+			t.Errorf("%s: encoded as type %d version %d", message.name, data[0], data[1])
+		}
+
+		decoded, err := decode_test_message(message, data, false)
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("%s: binary form does not round-trip: %v", message.name, err)
+		}
+		decoded, err = decode_test_message(message, json, true)
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("%s: JSON form does not round-trip: %v", message.name, err)
+		}
+	}
+}
+
+func TestWireRejectsMalformed(t *testing.T) {
+	for _, message := range wire_test_messages(t) {
+		data, json := encode_test_message(t, message)
+		version := append([]byte{}, data...)
+		version[1]++
+
+		for _, test := range []struct {
+			name string
+			data []byte
+			json bool
+		}{
+			{"truncated", data[:len(data)-1], false},
+			{"preamble only", data[:2], false},
+			{"wrong version", version, false},
+			{"trailing byte", append(append([]byte{}, data...), 0), false},
+			{"truncated JSON", json[:len(json)-1], true},
+			{"wrong JSON version", bytes.Replace(json, []byte(`"version":1`), []byte(`"version":2`), 1), true},
+		} {
+			if _, err := decode_test_message(message, test.data, test.json); !errors.Is(err, ErrBadWireFormat) {
+				t.Errorf("%s, %s: got %v, want ErrBadWireFormat", message.name, test.name, err)
+			}
+		}
+	}
+}
+
+func FuzzDecodeChallenge(f *testing.F) {
+	var core = NewPDPCore()
+	var numfileblocks uint64 = 1000
+
+	public, secret := wire_test_keys(f)
+	for k := range secret {
+		f.Add(uint8(k), encode_pdp_challenge(core.pdp_challenge(secret[k], 1000)))
+		f.Add(uint8(k), encode_pdp_challenge(core.pdp_challenge(secret[k], 7)))
+	}
+	f.Add(uint8(0), encode_pdp_challenge(core.pdp_challenge_public(secret[0], 1000)))
+
+	f.Fuzz(func(t *testing.T, k uint8, data []byte) {
+		key := public[int(k)%len(public)]
+
+		challenge, err := decode_pdp_challenge(data, key, numfileblocks)
+		if err == nil {
+			if challenge.s != nil || challenge.c == 0 || uint64(challenge.c) > numfileblocks {
+				t.Fatalf("decoded a bad challenge %+v", challenge)
+			}
+			/* Decoding is strict, so an accepted encoding is the canonical one */
+			if !bytes.Equal(encode_pdp_challenge(challenge), data) {
+				t.Fatal("challenge does not round-trip")
+			}
+		}
+		decode_pdp_challenge_json(data, key, numfileblocks)
+	})
+}
+
+func FuzzDecodeProof(f *testing.F) {
+	var core = NewPDPCore()
+
+	_, data := write_test_file(f, 3*PDP_BLOCKSIZE-10)
+	public, secret := wire_test_keys(f)
+	for k := range secret {
+		challenge := core.pdp_challenge(secret[k], 3)
+		f.Add(uint8(k), encode_pdp_proof(prove_test_blocks(f, secret[k], challenge, data)))
+	}
+	challenge := core.pdp_challenge_public(secret[0], 3)
+	proof := prove_test_blocks(f, secret[0], challenge, data)
+	f.Add(uint8(0), encode_pdp_proof(proof))
+	f.Add(uint8(0), encode_pdp_proof(core.pdp_mask_proof(public[0], challenge, proof)))
+
+	f.Fuzz(func(t *testing.T, k uint8, data []byte) {
+		key := public[int(k)%len(public)]
+
+		proof, err := decode_pdp_proof(data, key)
+		if err == nil {
+			if !bytes.Equal(encode_pdp_proof(proof), data) {
+				t.Fatal("proof does not round-trip")
+			}
+			/* A decoded proof can be checked without panicking */
+			core.pdp_verify_proof(secret[int(k)%len(secret)], core.pdp_challenge(secret[int(k)%len(secret)], 3), proof)
+		}
+		decode_pdp_proof_json(data, key)
+	})
+}
+
+/* pdp_test_buffer: An in-memory io.WriterAt. */
+type pdp_test_buffer struct {
+	data []byte
+}
+
+func (buffer *pdp_test_buffer) WriteAt(p []byte, offset int64) (int, error) {
+	if end := int(offset) + len(p); end > len(buffer.data) {
+		buffer.data = append(buffer.data, make([]byte, end-len(buffer.data))...)
+	}
+	return copy(buffer.data[offset:], p), nil
+}
+
+func FuzzDecodeTag(f *testing.F) {
+	var core = NewPDPCore()
+
+	for _, header := range []*PDP_tag_header{
+		{version: PDP_TAG_FILE_VERSION, scheme: PDP_SCHEME_RSA, block_size: PDP_BLOCKSIZE, tag_size: 128, numfileblocks: 2, tagged: 2},
+		{version: PDP_TAG_FILE_VERSION, scheme: PDP_SCHEME_BLS, block_size: PDP_BLOCKSIZE, tag_size: 48, numfileblocks: 3, tagged: 1,
+			name: []byte("wire test file 1")},
+		{version: PDP_TAG_FILE_VERSION, scheme: PDP_SCHEME_MAC, block_size: PDP_BLOCKSIZE, tag_size: 32, numfileblocks: 2, tagged: 2,
+			name: []byte("wire test file 2")},
+		{version: PDP_TAG_FILE_VERSION_2, scheme: PDP_SCHEME_RSA, block_size: PDP_BLOCKSIZE, tag_size: 128, numfileblocks: 2, tagged: 2},
+		{version: PDP_TAG_FILE_VERSION_1, scheme: PDP_SCHEME_RSA, block_size: PDP_BLOCKSIZE, tag_size: 256, numfileblocks: 1, tagged: 1},
+	} {
+		var buffer pdp_test_buffer
+		var record = make([]byte, header.tag_size)
+
+		write_pdp_tag_header(&buffer, header)
+		for i := uint64(0); i < header.tagged; i++ {
+			rand.Read(record)
+			buffer.WriteAt(record, tag_offset(header, uint(i)))
+		}
+		f.Add(buffer.data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, err := read_pdp_tag_header(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		/* The header re-encodes to the bytes it was read from */
+		var buffer pdp_test_buffer
+		if err := write_pdp_tag_header(&buffer, header); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buffer.data, data[:len(buffer.data)]) {
+			t.Fatal("tag header does not round-trip")
+		}
+
+		for i := uint64(0); i < header.tagged && i < 64; i++ {
+			tag := core.read_pdp_tag_at(bytes.NewReader(data), header, uint(i))
+			if tag == nil {
+				if tag_offset(header, uint(i))+int64(header.tag_size) <= int64(len(data)) {
+					t.Fatalf("record %d is in the data but was not read", i)
+				}
+				break
+			}
+			if tag.index != uint(i) || (tag.Tim.BitLen()+7)/8 > int(header.tag_size) {
+				t.Fatalf("bad tag %d", i)
+			}
+		}
+	})
+}
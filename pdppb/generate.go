@@ -0,0 +1,4 @@
+// Package pdppb holds the protocol buffer messages and gRPC stubs for remote PDP auditing.
+package pdppb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pdp.proto
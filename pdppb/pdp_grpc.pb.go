@@ -0,0 +1,141 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pdppb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// PDPAuditClient is the client API for PDPAudit service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PDPAuditClient interface {
+	// Challenge asks the server to prove possession of a file.
+	Challenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ProofResponse, error)
+	// GetFileInfo returns what a verifier needs to build a challenge.
+	GetFileInfo(ctx context.Context, in *FileInfoRequest, opts ...grpc.CallOption) (*FileInfoResponse, error)
+}
+
+type pDPAuditClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPDPAuditClient(cc grpc.ClientConnInterface) PDPAuditClient {
+	return &pDPAuditClient{cc}
+}
+
+func (c *pDPAuditClient) Challenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ProofResponse, error) {
+	out := new(ProofResponse)
+	err := c.cc.Invoke(ctx, "/pdp.v1.PDPAudit/Challenge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pDPAuditClient) GetFileInfo(ctx context.Context, in *FileInfoRequest, opts ...grpc.CallOption) (*FileInfoResponse, error) {
+	out := new(FileInfoResponse)
+	err := c.cc.Invoke(ctx, "/pdp.v1.PDPAudit/GetFileInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PDPAuditServer is the server API for PDPAudit service.
+// All implementations must embed UnimplementedPDPAuditServer
+// for forward compatibility
+type PDPAuditServer interface {
+	// Challenge asks the server to prove possession of a file.
+	Challenge(context.Context, *ChallengeRequest) (*ProofResponse, error)
+	// GetFileInfo returns what a verifier needs to build a challenge.
+	GetFileInfo(context.Context, *FileInfoRequest) (*FileInfoResponse, error)
+	mustEmbedUnimplementedPDPAuditServer()
+}
+
+// UnimplementedPDPAuditServer must be embedded to have forward compatible implementations.
+type UnimplementedPDPAuditServer struct {
+}
+
+func (UnimplementedPDPAuditServer) Challenge(context.Context, *ChallengeRequest) (*ProofResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Challenge not implemented")
+}
+func (UnimplementedPDPAuditServer) GetFileInfo(context.Context, *FileInfoRequest) (*FileInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFileInfo not implemented")
+}
+func (UnimplementedPDPAuditServer) mustEmbedUnimplementedPDPAuditServer() {}
+
+// UnsafePDPAuditServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PDPAuditServer will
+// result in compilation errors.
+type UnsafePDPAuditServer interface {
+	mustEmbedUnimplementedPDPAuditServer()
+}
+
+func RegisterPDPAuditServer(s grpc.ServiceRegistrar, srv PDPAuditServer) {
+	s.RegisterService(&PDPAudit_ServiceDesc, srv)
+}
+
+func _PDPAudit_Challenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PDPAuditServer).Challenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pdp.v1.PDPAudit/Challenge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PDPAuditServer).Challenge(ctx, req.(*ChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PDPAudit_GetFileInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PDPAuditServer).GetFileInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pdp.v1.PDPAudit/GetFileInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PDPAuditServer).GetFileInfo(ctx, req.(*FileInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PDPAudit_ServiceDesc is the grpc.ServiceDesc for PDPAudit service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PDPAudit_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pdp.v1.PDPAudit",
+	HandlerType: (*PDPAuditServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Challenge",
+			Handler:    _PDPAudit_Challenge_Handler,
+		},
+		{
+			MethodName: "GetFileInfo",
+			Handler:    _PDPAudit_GetFileInfo_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pdp.proto",
+}